@@ -0,0 +1,460 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// change data capture transfers load the source table, then keep applying the source's
+// changes to the target in batches. each batch deletes the primary keys that changed from
+// the target and inserts their latest rows, so applying a change more than once is harmless
+
+const defaultCdcBatchSeconds = 60
+
+type ChangeCapture interface {
+	// start begins capturing changes. it's called before the initial load, so changes made
+	// while the table loads are captured, and applied again after it. when resuming, it starts
+	// from the transfer's cdc position instead
+	start() (err error)
+	// nextBatch collects committed changes until the deadline
+	nextBatch(deadline time.Time) (batch *ChangeBatch, err error)
+	// confirm tells the source that changes up to a batch's position have been applied
+	confirm(position string) (err error)
+	// close stops capturing. sources that can't resume remove whatever start created, rather
+	// than leave it holding on to the source's change history
+	close()
+}
+
+type RowChange struct {
+	Deleted bool
+	// values are in the order of the table's column infos, and hold the values a driver would
+	// scan. deletes only need primary key values
+	Values []interface{}
+}
+
+type ChangeBatch struct {
+	// position is where the source should resume after this batch, empty if nothing was committed
+	Position  string
+	Truncated bool
+	// reload is set when the source can no longer say what changed since the last position, so
+	// the table is loaded again instead
+	Reload  bool
+	Changes int
+	rows    map[string]RowChange
+}
+
+// sources that can't send a value that didn't change, like a large postgresql value, put this in its place
+type unchangedValue struct{}
+
+func newChangeBatch() *ChangeBatch {
+	return &ChangeBatch{rows: map[string]RowChange{}}
+}
+
+func (batch *ChangeBatch) add(change RowChange, columnInfos []ColumnInfo) {
+	// only the latest change to each row matters, since rows are applied whole
+
+	key := getPkKey(change.Values, columnInfos)
+
+	previous, ok := batch.rows[key]
+	if ok && !previous.Deleted && !change.Deleted {
+		for i := range change.Values {
+			if _, unchanged := change.Values[i].(unchangedValue); unchanged {
+				change.Values[i] = previous.Values[i]
+			}
+		}
+	}
+
+	batch.rows[key] = change
+}
+
+func (batch *ChangeBatch) truncate() {
+	batch.rows = map[string]RowChange{}
+	batch.Truncated = true
+}
+
+func (batch *ChangeBatch) merge(other *ChangeBatch, columnInfos []ColumnInfo) {
+	// other's changes happened after this batch's, like a transaction that just committed
+
+	if other.Truncated {
+		batch.truncate()
+	}
+
+	for _, change := range other.rows {
+		batch.add(change, columnInfos)
+	}
+
+	batch.Changes += other.Changes
+	if other.Position != "" {
+		batch.Position = other.Position
+	}
+}
+
+func (batch *ChangeBatch) resolveUnchanged(fetch func(values []interface{}) (found bool, err error)) (err error) {
+	// rows that still hold values the source didn't send are read again. rows deleted since
+	// are deleted from the target, and a later change will bring them back if needed
+
+	for key, change := range batch.rows {
+		if change.Deleted {
+			continue
+		}
+
+		for i := range change.Values {
+			if _, unchanged := change.Values[i].(unchangedValue); !unchanged {
+				continue
+			}
+
+			found, err := fetch(change.Values)
+			if err != nil {
+				return err
+			}
+
+			change.Deleted = !found
+			batch.rows[key] = change
+			break
+		}
+	}
+
+	return nil
+}
+
+func (batch *ChangeBatch) empty() bool {
+	return len(batch.rows) == 0 && !batch.Truncated
+}
+
+func getPkKey(values []interface{}, columnInfos []ColumnInfo) string {
+	keyBuilder := strings.Builder{}
+	for i := range columnInfos {
+		if columnInfos[i].IsPrimaryKey {
+			keyBuilder.WriteString(fmt.Sprintf("%v\x00", values[i]))
+		}
+	}
+	return keyBuilder.String()
+}
+
+func runCdcTransfer(transfer Transfer) (err error) {
+
+	transfer = transferMap.SetStatus(transfer.Id, StatusRunning, transfer)
+
+	source, err := newSystem(transfer.SourceConnectionInfo)
+	if err != nil {
+		return fmt.Errorf("error creating source system :: %v", err)
+	}
+	defer source.closeConnectionPool(true)
+
+	target, err := newSystem(transfer.TargetConnectionInfo)
+	if err != nil {
+		return fmt.Errorf("error creating target system :: %v", err)
+	}
+	defer target.closeConnectionPool(true)
+
+	columnInfos, err := getTableColumnInfos(transfer.SourceSchema, transfer.SourceTable, source)
+	if err != nil {
+		return fmt.Errorf("error getting source table column infos :: %v", err)
+	}
+
+	if !hasPrimaryKey(columnInfos) {
+		return errors.New("source table must have a primary key to run a cdc transfer")
+	}
+
+	// a transfer given the position another transfer stopped at picks up from there, and
+	// skips the initial load
+	resuming := transfer.CdcPosition != ""
+
+	capture, err := source.newChangeCapture(transfer, columnInfos)
+	if err != nil {
+		return fmt.Errorf("error creating change capture :: %v", err)
+	}
+
+	err = capture.start()
+	if err != nil {
+		capture.close()
+		return fmt.Errorf("error starting change capture :: %v", err)
+	}
+	defer capture.close()
+
+	if resuming {
+		infoLog.Printf("transfer %v resuming from %v, applying changes every %v seconds", transfer.Id, transfer.CdcPosition, transfer.CdcBatchSeconds)
+	} else {
+		infoLog.Printf("transfer %v started capturing changes, running initial load", transfer.Id)
+
+		verificationResult, err := loadTable(transfer)
+		if err != nil {
+			return fmt.Errorf("error running initial load :: %v", err)
+		}
+
+		// pipeline stages that fail cancel the transfer and set its status themselves
+		if transfer.Context.Err() != nil {
+			return nil
+		}
+
+		transferMap.Update(transfer.Id, func(current *Transfer) {
+			current.VerificationResult = verificationResult
+		})
+
+		infoLog.Printf("transfer %v initial load complete, applying changes every %v seconds", transfer.Id, transfer.CdcBatchSeconds)
+	}
+
+	transfer.Progress.setStage(StageCdc)
+
+	batchInterval := time.Duration(transfer.CdcBatchSeconds) * time.Second
+
+	for batchNum := 0; ; batchNum++ {
+
+		batch, err := capture.nextBatch(time.Now().Add(batchInterval))
+		if transfer.Context.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error getting changes :: %v", err)
+		}
+
+		if batch.Reload {
+			err = reloadTable(transfer)
+			if err != nil {
+				return fmt.Errorf("error reloading table :: %v", err)
+			}
+
+			if transfer.Context.Err() != nil {
+				return nil
+			}
+		} else if !batch.empty() {
+			err = applyChangeBatch(batch, batchNum, transfer, columnInfos, source, target)
+			if err != nil {
+				return fmt.Errorf("error applying changes :: %v", err)
+			}
+
+			if transfer.Context.Err() != nil {
+				return nil
+			}
+
+			infoLog.Printf("transfer %v applied %v changes to %v rows", transfer.Id, batch.Changes, len(batch.rows))
+		}
+
+		if batch.Position == "" {
+			continue
+		}
+
+		err = capture.confirm(batch.Position)
+		if err != nil {
+			return fmt.Errorf("error confirming changes :: %v", err)
+		}
+
+		transferMap.Update(transfer.Id, func(current *Transfer) {
+			current.CdcPosition = batch.Position
+			current.CdcAppliedAt = time.Now().Format(time.RFC3339Nano)
+		})
+	}
+}
+
+func reloadTable(transfer Transfer) (err error) {
+	// the target is truncated before the load, unless the transfer swaps in a new table
+
+	warningLog.Printf("transfer %v source no longer has the changes since the last applied batch, loading the table again", transfer.Id)
+
+	if transfer.WriteMode != WriteModeSwap {
+		transfer.WriteMode = WriteModeTruncate
+	}
+
+	verificationResult, err := loadTable(transfer)
+	if err != nil {
+		return err
+	}
+
+	if transfer.Context.Err() != nil {
+		return nil
+	}
+
+	transferMap.Update(transfer.Id, func(current *Transfer) {
+		current.VerificationResult = verificationResult
+	})
+
+	transfer.Progress.setStage(StageCdc)
+
+	infoLog.Printf("transfer %v reload complete", transfer.Id)
+
+	return nil
+}
+
+func applyChangeBatch(batch *ChangeBatch, batchNum int, transfer Transfer, columnInfos []ColumnInfo, source, target System) (err error) {
+	// writes the batch as a pipe file, with every changed primary key in its pk file and only
+	// rows that still exist in the pipe file, then runs it through the incremental pipeline
+
+	if batch.Truncated {
+		err = truncateTable(transfer.TargetSchema, transfer.TargetTable, target)
+		if err != nil {
+			return fmt.Errorf("error truncating target table :: %v", err)
+		}
+	}
+
+	if len(batch.rows) == 0 {
+		return nil
+	}
+
+	batchDir := filepath.Join(transfer.TmpDir, fmt.Sprintf("cdc-batch-%v", batchNum))
+	transfer.PipeFileDir = filepath.Join(batchDir, "pipe-files")
+	transfer.FinalCsvDir = filepath.Join(batchDir, "final-csv")
+
+	for _, dir := range []string{transfer.PipeFileDir, transfer.FinalCsvDir} {
+		err = os.MkdirAll(dir, 0600)
+		if err != nil {
+			return fmt.Errorf("error creating batch dir :: %v", err)
+		}
+	}
+
+	if !transfer.KeepFiles {
+		defer func() {
+			err := os.RemoveAll(batchDir)
+			if err != nil {
+				errorLog.Printf("error removing batch dir %v :: %v", batchDir, err)
+			}
+		}()
+	}
+
+	pipeFileInfo, err := writeChangePipeFile(batch, transfer, columnInfos, source)
+	if err != nil {
+		return fmt.Errorf("error writing change pipe file :: %v", err)
+	}
+
+	pipeFiles := make(chan PipeFileInfo, 1)
+	pipeFiles <- pipeFileInfo
+	close(pipeFiles)
+
+	pksDeletedPipeFiles := deletePks(pipeFiles, columnInfos, transfer, target, true, false)
+
+	if pipeFileInfo.Rows == 0 {
+		for range pksDeletedPipeFiles {
+		}
+		return nil
+	}
+
+	err = insertPipeFiles(pksDeletedPipeFiles, transfer, columnInfos, target, "")
+	if err != nil {
+		return fmt.Errorf("error inserting pipe files :: %v", err)
+	}
+
+	return nil
+}
+
+func writeChangePipeFile(batch *ChangeBatch, transfer Transfer, columnInfos []ColumnInfo, source System) (pipeFileInfo PipeFileInfo, err error) {
+
+	pipeFileFormatters := source.getPipeFileFormatters()
+
+	pipeFileInfo.FilePath = filepath.Join(transfer.PipeFileDir, fmt.Sprintf("%032b.pipe", 0))
+	pipeFileInfo.PkFilePath = filepath.Join(transfer.PipeFileDir, fmt.Sprintf("%032bpk.pipe", 0))
+
+	pipeFile, err := os.Create(pipeFileInfo.FilePath)
+	if err != nil {
+		return pipeFileInfo, fmt.Errorf("error creating pipe file :: %v", err)
+	}
+	defer pipeFile.Close()
+
+	pkFile, err := os.Create(pipeFileInfo.PkFilePath)
+	if err != nil {
+		return pipeFileInfo, fmt.Errorf("error creating pk file :: %v", err)
+	}
+	defer pkFile.Close()
+
+	csvWriter := csv.NewWriter(pipeFile)
+	pkWriter := csv.NewWriter(pkFile)
+
+	bytes := 0
+
+	for _, change := range batch.rows {
+
+		csvRow := []string{}
+		pkRow := []string{}
+
+		for i := range columnInfos {
+			// deletes only need their primary key
+			if change.Deleted && !columnInfos[i].IsPrimaryKey {
+				continue
+			}
+
+			if _, unchanged := change.Values[i].(unchangedValue); unchanged {
+				return pipeFileInfo, fmt.Errorf("column %v of a changed row was not sent by the source and was not fetched", columnInfos[i].Name)
+			}
+
+			pipeFileValue := transfer.Null
+			if change.Values[i] != nil {
+				pipeFileValue, err = pipeFileFormatters[columnInfos[i].PipeType](change.Values[i])
+				if err != nil {
+					return pipeFileInfo, fmt.Errorf("error formatting pipe file value :: %v", err)
+				}
+			}
+
+			if columnInfos[i].IsPrimaryKey {
+				pkRow = append(pkRow, pipeFileValue)
+			}
+			csvRow = append(csvRow, pipeFileValue)
+		}
+
+		err = pkWriter.Write(pkRow)
+		if err != nil {
+			return pipeFileInfo, fmt.Errorf("error writing pk file :: %v", err)
+		}
+
+		if change.Deleted {
+			continue
+		}
+
+		err = csvWriter.Write(csvRow)
+		if err != nil {
+			return pipeFileInfo, fmt.Errorf("error writing pipe file :: %v", err)
+		}
+
+		for i := range csvRow {
+			bytes += len(csvRow[i])
+		}
+		pipeFileInfo.Rows++
+	}
+
+	csvWriter.Flush()
+	pkWriter.Flush()
+
+	if err := csvWriter.Error(); err != nil {
+		return pipeFileInfo, fmt.Errorf("error flushing pipe file :: %v", err)
+	}
+	if err := pkWriter.Error(); err != nil {
+		return pipeFileInfo, fmt.Errorf("error flushing pk file :: %v", err)
+	}
+
+	recordPipeFileWritten(transfer, pipeFileInfo.Rows, bytes)
+	transfer.Progress.addPipeFile(pipeFileInfo.Rows, bytes)
+
+	return pipeFileInfo, nil
+}
+
+func cancelOnInterrupt(transfer Transfer) (stop func()) {
+	// cli transfers that run until they are interrupted are cancelled instead, so they can clean up
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		select {
+		case <-signals:
+			infoLog.Printf("transfer %v interrupted, cancelling", transfer.Id)
+			current, _ := transferMap.Get(transfer.Id)
+			transferMap.CancelAndSetStatus(transfer.Id, current, StatusCancelled)
+		case <-done:
+		}
+	}()
+
+	// waits for a cancel that's under way, so the transfer's status is set when stop returns
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		<-stopped
+	}
+}
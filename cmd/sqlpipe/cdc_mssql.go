@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sql server changes come from change tracking, which records the primary keys that changed in
+// each table, but not what they changed to. each batch joins the changed keys to the table to
+// read the rows as they are now, and keys with no row left were deleted
+
+type mssqlChangeCapture struct {
+	system      Mssql
+	transfer    Transfer
+	columnInfos []ColumnInfo
+	// the change tracking version to read changes after
+	position string
+}
+
+func (system Mssql) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return &mssqlChangeCapture{
+		system:      system,
+		transfer:    transfer,
+		columnInfos: columnInfos,
+		position:    transfer.CdcPosition,
+	}, nil
+}
+
+func (capture *mssqlChangeCapture) start() (err error) {
+
+	if capture.position != "" {
+		_, err = strconv.ParseInt(capture.position, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cdc-position %v must be a change tracking version", capture.position)
+		}
+	}
+
+	var databaseTracked int
+	err = capture.system.queryRow("select count(*) from sys.change_tracking_databases where database_id = db_id()").Scan(&databaseTracked)
+	if err != nil {
+		return fmt.Errorf("error checking database change tracking :: %v", err)
+	}
+
+	if databaseTracked == 0 {
+		err = capture.system.exec("alter database current set change_tracking = on (change_retention = 2 days, auto_cleanup = on)")
+		if err != nil {
+			return fmt.Errorf("error enabling change tracking on database :: %v", err)
+		}
+		infoLog.Printf("transfer %v enabled change tracking on the source database", capture.transfer.Id)
+	}
+
+	var tableTracked int
+	err = capture.system.queryRow(fmt.Sprintf("select count(*) from sys.change_tracking_tables where object_id = object_id('%v')", capture.getObjectName())).Scan(&tableTracked)
+	if err != nil {
+		return fmt.Errorf("error checking table change tracking :: %v", err)
+	}
+
+	if tableTracked == 0 {
+		err = capture.system.exec(fmt.Sprintf("alter table %v enable change_tracking", capture.getEscapedTable()))
+		if err != nil {
+			return fmt.Errorf("error enabling change tracking on table :: %v", err)
+		}
+		infoLog.Printf("transfer %v enabled change tracking on %v", capture.transfer.Id, capture.getEscapedTable())
+	}
+
+	// a purged resume position is caught by the first batch, which reloads the table
+	if capture.position != "" {
+		infoLog.Printf("transfer %v resuming changes after change tracking version %v", capture.transfer.Id, capture.position)
+		return nil
+	}
+
+	capture.position, err = capture.getCurrentVersion()
+	if err != nil {
+		return err
+	}
+
+	infoLog.Printf("transfer %v capturing changes after change tracking version %v", capture.transfer.Id, capture.position)
+
+	return nil
+}
+
+func (capture *mssqlChangeCapture) getEscapedTable() string {
+	return getSchemaPeriodTable(capture.transfer.SourceSchema, capture.transfer.SourceTable, capture.system, true)
+}
+
+func (capture *mssqlChangeCapture) getObjectName() string {
+	return singleQuoteReplacer.Replace(capture.getEscapedTable())
+}
+
+func (capture *mssqlChangeCapture) getCurrentVersion() (version string, err error) {
+
+	var currentVersion sql.NullInt64
+	err = capture.system.queryRow("select change_tracking_current_version()").Scan(&currentVersion)
+	if err != nil {
+		return "", fmt.Errorf("error getting change tracking version :: %v", err)
+	}
+	if !currentVersion.Valid {
+		return "", errors.New("change tracking is not enabled on the source database")
+	}
+
+	return strconv.FormatInt(currentVersion.Int64, 10), nil
+}
+
+func (capture *mssqlChangeCapture) nextBatch(deadline time.Time) (batch *ChangeBatch, err error) {
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-capture.transfer.Context.Done():
+		return nil, capture.transfer.Context.Err()
+	case <-timer.C:
+	}
+
+	// changes committed after the current version can show up in this batch too, and are
+	// applied again by the next one
+	currentVersion, err := capture.getCurrentVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	reload := &ChangeBatch{Reload: true, Position: currentVersion, rows: map[string]RowChange{}}
+
+	purged, err := capture.isPurged()
+	if err != nil {
+		return nil, err
+	}
+	if purged {
+		return reload, nil
+	}
+
+	batch = newChangeBatch()
+
+	err = capture.readChanges(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	// the changes are only complete if cleanup hadn't removed any of them by the time they were read
+	purged, err = capture.isPurged()
+	if err != nil {
+		return nil, err
+	}
+	if purged {
+		return reload, nil
+	}
+
+	batch.Position = currentVersion
+
+	return batch, nil
+}
+
+func (capture *mssqlChangeCapture) isPurged() (purged bool, err error) {
+	// whether change tracking cleanup has removed changes made after the last version
+
+	var minValidVersion sql.NullInt64
+	err = capture.system.queryRow(fmt.Sprintf("select change_tracking_min_valid_version(object_id('%v'))", capture.getObjectName())).Scan(&minValidVersion)
+	if err != nil {
+		return false, fmt.Errorf("error getting change tracking min valid version :: %v", err)
+	}
+	if !minValidVersion.Valid {
+		return false, errors.New("change tracking is not enabled on the source table")
+	}
+
+	lastVersion, err := strconv.ParseInt(capture.position, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("error parsing change tracking version :: %v", err)
+	}
+
+	return lastVersion < minValidVersion.Int64, nil
+}
+
+func (capture *mssqlChangeCapture) readChanges(batch *ChangeBatch) (err error) {
+
+	selects := []string{}
+	joins := []string{}
+	var firstPk string
+
+	for i := range capture.columnInfos {
+		escapedColumn := escapeIfNeeded(capture.columnInfos[i].Name, capture.system)
+		if capture.columnInfos[i].IsPrimaryKey {
+			// keys come from the change table, so deleted rows still have them
+			selects = append(selects, fmt.Sprintf("ct.%v", escapedColumn))
+			joins = append(joins, fmt.Sprintf("t.%v = ct.%v", escapedColumn, escapedColumn))
+			if firstPk == "" {
+				firstPk = escapedColumn
+			}
+		} else {
+			selects = append(selects, fmt.Sprintf("t.%v", escapedColumn))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		select
+			%v,
+			case when t.%v is null then 1 else 0 end
+		from
+			changetable(changes %v, %v) as ct
+			left join %v as t on %v`,
+		strings.Join(selects, ", "),
+		firstPk,
+		capture.getEscapedTable(),
+		capture.position,
+		capture.getEscapedTable(),
+		strings.Join(joins, " and "),
+	)
+
+	rows, err := capture.system.query(query)
+	if err != nil {
+		return fmt.Errorf("error reading changes :: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values := make([]interface{}, len(capture.columnInfos))
+		var deleted int64
+
+		dest := make([]interface{}, len(values)+1)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		dest[len(values)] = &deleted
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return fmt.Errorf("error scanning changes :: %v", err)
+		}
+
+		batch.add(RowChange{Deleted: deleted == 1, Values: values}, capture.columnInfos)
+		batch.Changes++
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("error iterating changes :: %v", err)
+	}
+
+	return nil
+}
+
+func (capture *mssqlChangeCapture) confirm(position string) (err error) {
+	capture.position = position
+	return nil
+}
+
+func (capture *mssqlChangeCapture) close() {
+	// change tracking is left on, so a new transfer can resume from the last version
+}
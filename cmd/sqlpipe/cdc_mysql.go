@@ -0,0 +1,771 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysql changes are read from the row based binlog with mysqlbinlog, which decodes row events
+// into commented out statements. each batch runs mysqlbinlog from the last applied position to
+// the end of the binlog, so the position can be given to a new transfer to resume where an old
+// one stopped
+
+type mysqlChangeCapture struct {
+	system      Mysql
+	transfer    Transfer
+	columnInfos []ColumnInfo
+	columnTypes []string
+	database    string
+	// binlog file and position to read from next, like binlog.000003:1234
+	position string
+}
+
+type mysqlRowEvent struct {
+	kind string
+	// the row event is for the captured table
+	matches bool
+	before  []interface{}
+	after   []interface{}
+	section []interface{}
+}
+
+var (
+	mysqlEventHeaderRegexp  = regexp.MustCompile(`^#\d{6} +\d{1,2}:\d{2}:\d{2} server id \d+ +end_log_pos (\d+)(.*)$`)
+	mysqlRotateRegexp       = regexp.MustCompile(`Rotate to (\S+) +pos: (\d+)`)
+	mysqlRowStatementRegexp = regexp.MustCompile("^### (INSERT INTO|UPDATE|DELETE FROM) `((?:[^`]|``)*)`\\.`((?:[^`]|``)*)`$")
+	mysqlRowValueRegexp     = regexp.MustCompile(`^###   @(\d+)=(.*)$`)
+	mysqlTruncateRegexp     = regexp.MustCompile("(?is)^truncate\\s+(?:table\\s+)?(?:`?([^`.\\s]+)`?\\.)?`?([^`\\s;]+)`?\\s*$")
+	mysqlGtidSetRegexp      = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}:`)
+)
+
+func (system Mysql) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+
+	mysqlCapture := &mysqlChangeCapture{
+		system:      system,
+		transfer:    transfer,
+		columnInfos: columnInfos,
+		columnTypes: make([]string, len(columnInfos)),
+		position:    transfer.CdcPosition,
+	}
+
+	// binlog events name the database, which is the connection's database for mysql sources
+	err = system.queryRow("select database()").Scan(&mysqlCapture.database)
+	if err != nil {
+		return nil, fmt.Errorf("error getting current database :: %v", err)
+	}
+
+	// column types say whether integers are unsigned, which mysqlbinlog doesn't always show
+	rows, err := system.query(fmt.Sprintf(`
+		SELECT
+			COLUMN_NAME,
+			COLUMN_TYPE
+		FROM
+			information_schema.COLUMNS
+		WHERE
+			TABLE_SCHEMA = DATABASE()
+			AND TABLE_NAME = '%v';`, transfer.SourceTable))
+	if err != nil {
+		return nil, fmt.Errorf("error getting column types :: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var columnName, columnType string
+		err = rows.Scan(&columnName, &columnType)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning column types :: %v", err)
+		}
+		for i := range columnInfos {
+			if columnInfos[i].Name == columnName {
+				mysqlCapture.columnTypes[i] = strings.ToLower(columnType)
+			}
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating column types :: %v", err)
+	}
+
+	return mysqlCapture, nil
+}
+
+func (capture *mysqlChangeCapture) start() (err error) {
+
+	var binlogFormat string
+	err = capture.system.queryRow("select @@global.binlog_format").Scan(&binlogFormat)
+	if err != nil {
+		return fmt.Errorf("error getting binlog format :: %v", err)
+	}
+	if !strings.EqualFold(binlogFormat, "ROW") {
+		return fmt.Errorf("binlog_format must be ROW to capture changes, it is %v", binlogFormat)
+	}
+
+	binlogs, err := capture.getBinlogs()
+	if err != nil {
+		return err
+	}
+
+	if capture.position != "" {
+		file, _, err := parseBinlogPosition(capture.position)
+		if err != nil {
+			return err
+		}
+		if !permittedValue(file, binlogs...) {
+			return fmt.Errorf("binlog %v of cdc-position %v has been purged from the source", file, capture.position)
+		}
+		infoLog.Printf("transfer %v resuming changes from binlog position %v", capture.transfer.Id, capture.position)
+		return nil
+	}
+
+	capture.position, err = capture.getCurrentPosition()
+	if err != nil {
+		return err
+	}
+
+	infoLog.Printf("transfer %v capturing changes from binlog position %v", capture.transfer.Id, capture.position)
+
+	return nil
+}
+
+func (capture *mysqlChangeCapture) getBinlogs() (binlogs []string, err error) {
+
+	rows, err := capture.system.query("show binary logs")
+	if err != nil {
+		return nil, fmt.Errorf("error listing binlogs :: %v", err)
+	}
+	defer rows.Close()
+
+	values, err := scanRawRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning binlogs :: %v", err)
+	}
+
+	for i := range values {
+		binlogs = append(binlogs, string(values[i][0]))
+	}
+
+	return binlogs, nil
+}
+
+func (capture *mysqlChangeCapture) getCurrentPosition() (position string, err error) {
+
+	// show master status was renamed in mysql 8.4
+	rows, err := capture.system.query("show binary log status")
+	if err != nil {
+		rows, err = capture.system.query("show master status")
+		if err != nil {
+			return "", fmt.Errorf("error getting binlog position :: %v", err)
+		}
+	}
+	defer rows.Close()
+
+	values, err := scanRawRows(rows)
+	if err != nil {
+		return "", fmt.Errorf("error scanning binlog position :: %v", err)
+	}
+
+	if len(values) == 0 {
+		return "", errors.New("binary logging must be enabled on the source to capture changes")
+	}
+
+	return fmt.Sprintf("%s:%s", values[0][0], values[0][1]), nil
+}
+
+func scanRawRows(rows *sql.Rows) (values [][][]byte, err error) {
+	// scans rows whose columns differ between mysql versions
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		row := make([]sql.RawBytes, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		copied := [][]byte{}
+		for i := range row {
+			copied = append(copied, append([]byte{}, row[i]...))
+		}
+		values = append(values, copied)
+	}
+
+	return values, rows.Err()
+}
+
+func (capture *mysqlChangeCapture) nextBatch(deadline time.Time) (batch *ChangeBatch, err error) {
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-capture.transfer.Context.Done():
+		return nil, capture.transfer.Context.Err()
+	case <-timer.C:
+	}
+
+	file, position, err := parseBinlogPosition(capture.position)
+	if err != nil {
+		return nil, err
+	}
+
+	optionFile := filepath.Join(capture.transfer.TmpDir, "mysqlbinlog.cnf")
+	args, err := getMysqlbinlogConnectionArgs(capture.transfer.SourceConnectionInfo.ConnectionString, optionFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// without --stop-never, mysqlbinlog exits once it reaches the end of the newest binlog
+	args = append(args,
+		"--read-from-remote-server",
+		"--base64-output=DECODE-ROWS",
+		"--verbose",
+		"--to-last-log",
+		fmt.Sprintf("--start-position=%v", position),
+		file,
+	)
+
+	cmd := exec.CommandContext(capture.transfer.Context, "mysqlbinlog", args...)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error getting mysqlbinlog output :: %v", err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("error starting mysqlbinlog :: %v", err)
+	}
+
+	batch, parseErr := capture.parseBinlog(stdout, file)
+
+	// drain the output so mysqlbinlog isn't blocked writing when parsing stops early
+	io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("error running mysqlbinlog :: %v :: %v", err, stderr.String())
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("error parsing mysqlbinlog output :: %v", parseErr)
+	}
+
+	err = batch.resolveUnchanged(capture.fetchRow)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching unchanged values :: %v", err)
+	}
+
+	return batch, nil
+}
+
+func getMysqlbinlogConnectionArgs(connectionString, optionFile string) (args []string, err error) {
+	// the password is written to an option file only sqlpipe can read, since command line
+	// arguments can be seen by anyone on the machine
+
+	config, err := mysql.ParseDSN(connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing connection string :: %v", err)
+	}
+
+	options := fmt.Sprintf("[client]\npassword=\"%v\"\n", mysqlOptionFileEscaper.Replace(config.Passwd))
+	err = os.WriteFile(optionFile, []byte(options), 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error writing mysqlbinlog option file :: %v", err)
+	}
+
+	// the option file must be the first argument
+	args = []string{
+		fmt.Sprintf("--defaults-extra-file=%v", optionFile),
+		fmt.Sprintf("--user=%v", config.User),
+	}
+
+	if config.Net == "unix" {
+		return append(args, fmt.Sprintf("--socket=%v", config.Addr)), nil
+	}
+
+	host, port, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing connection string address :: %v", err)
+	}
+
+	return append(args, fmt.Sprintf("--host=%v", host), fmt.Sprintf("--port=%v", port)), nil
+}
+
+func (capture *mysqlChangeCapture) parseBinlog(output io.Reader, file string) (batch *ChangeBatch, err error) {
+	// changes are collected per transaction and merged into the batch when it commits, so a
+	// transaction that's cut off at the end of the output is read again by the next batch
+
+	batch = newChangeBatch()
+
+	reader := bufio.NewReader(output)
+
+	var tx *ChangeBatch
+	var rowEvent *mysqlRowEvent
+	eventType := ""
+	eventEnd := ""
+	currentDatabase := ""
+	statement := strings.Builder{}
+
+	commit := func() {
+		if tx != nil {
+			batch.merge(tx, capture.columnInfos)
+		}
+		tx = nil
+		batch.Position = fmt.Sprintf("%v:%v", file, eventEnd)
+	}
+
+	finishRowEvent := func() (err error) {
+		if rowEvent == nil {
+			return nil
+		}
+		event := rowEvent
+		rowEvent = nil
+		if !event.matches || tx == nil {
+			return nil
+		}
+		return capture.addRowEvent(tx, event)
+	}
+
+	for {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, "### ") {
+			err = capture.parseRowLine(line, &rowEvent, finishRowEvent)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			err = finishRowEvent()
+			if err != nil {
+				return nil, err
+			}
+
+			if match := mysqlEventHeaderRegexp.FindStringSubmatch(line); match != nil {
+				eventEnd = match[1]
+				eventType = ""
+				statement.Reset()
+
+				switch {
+				case strings.Contains(match[2], "\tQuery\t"):
+					eventType = "query"
+				case strings.Contains(match[2], "\tXid = "):
+					commit()
+				case mysqlRotateRegexp.MatchString(match[2]):
+					rotate := mysqlRotateRegexp.FindStringSubmatch(match[2])
+					file = rotate[1]
+					if tx == nil {
+						batch.Position = fmt.Sprintf("%v:%v", file, rotate[2])
+					}
+				}
+			} else if eventType == "query" && !strings.HasPrefix(line, "#") {
+
+				// query events hold statements that end with /*!*/;
+				statement.WriteString(line)
+				if !strings.HasSuffix(line, "/*!*/;") {
+					statement.WriteString("\n")
+				} else {
+					text := strings.TrimSpace(strings.TrimSuffix(statement.String(), "/*!*/;"))
+					statement.Reset()
+
+					upper := strings.ToUpper(text)
+
+					switch {
+					case strings.HasPrefix(text, "/*"):
+					case strings.HasPrefix(upper, "USE "):
+						currentDatabase = strings.Trim(strings.TrimSpace(text[4:]), "`")
+					case strings.HasPrefix(upper, "SET "):
+					case upper == "BEGIN":
+						tx = newChangeBatch()
+					case upper == "COMMIT":
+						commit()
+					case strings.HasPrefix(upper, "ROLLBACK"):
+						tx = nil
+					case tx == nil:
+						// ddl commits on its own
+						if match := mysqlTruncateRegexp.FindStringSubmatch(text); match != nil {
+							database := match[1]
+							if database == "" {
+								database = currentDatabase
+							}
+							if database == capture.database && match[2] == capture.transfer.SourceTable {
+								tx = newChangeBatch()
+								tx.truncate()
+								tx.Changes++
+							}
+						}
+						commit()
+					}
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	err = finishRowEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func (capture *mysqlChangeCapture) parseRowLine(line string, rowEvent **mysqlRowEvent, finishRowEvent func() error) (err error) {
+	// row events look like
+	// ### UPDATE `db`.`table`
+	// ### WHERE
+	// ###   @1=1
+	// ### SET
+	// ###   @1=1
+
+	if match := mysqlRowStatementRegexp.FindStringSubmatch(line); match != nil {
+		err = finishRowEvent()
+		if err != nil {
+			return err
+		}
+
+		event := &mysqlRowEvent{
+			kind:    match[1],
+			matches: strings.ReplaceAll(match[2], "``", "`") == capture.database && strings.ReplaceAll(match[3], "``", "`") == capture.transfer.SourceTable,
+			before:  newUnchangedValues(len(capture.columnInfos)),
+			after:   newUnchangedValues(len(capture.columnInfos)),
+		}
+		*rowEvent = event
+
+		return nil
+	}
+
+	event := *rowEvent
+	if event == nil {
+		return nil
+	}
+
+	switch line {
+	case "### WHERE":
+		event.section = event.before
+		return nil
+	case "### SET":
+		event.section = event.after
+		return nil
+	}
+
+	match := mysqlRowValueRegexp.FindStringSubmatch(line)
+	if match == nil || !event.matches || event.section == nil {
+		return nil
+	}
+
+	// columns added after the transfer started are ignored
+	columnNum, err := strconv.Atoi(match[1])
+	if err != nil || columnNum < 1 || columnNum > len(capture.columnInfos) {
+		return nil
+	}
+
+	value, err := capture.parseValue(match[2], columnNum-1)
+	if err != nil {
+		return fmt.Errorf("error parsing value of column %v :: %v", capture.columnInfos[columnNum-1].Name, err)
+	}
+	event.section[columnNum-1] = value
+
+	return nil
+}
+
+func (capture *mysqlChangeCapture) addRowEvent(tx *ChangeBatch, event *mysqlRowEvent) (err error) {
+
+	for i := range capture.columnInfos {
+		if !capture.columnInfos[i].IsPrimaryKey {
+			continue
+		}
+		if event.kind != "INSERT INTO" && isUnchanged(event.before[i]) {
+			return fmt.Errorf("binlog row event is missing primary key column %v", capture.columnInfos[i].Name)
+		}
+	}
+
+	switch event.kind {
+	case "INSERT INTO":
+		tx.add(RowChange{Values: event.after}, capture.columnInfos)
+
+	case "UPDATE":
+		// with a minimal row image, the new row only has the columns that changed
+		for i := range event.after {
+			if isUnchanged(event.after[i]) {
+				event.after[i] = event.before[i]
+			}
+		}
+		if getPkKey(event.before, capture.columnInfos) != getPkKey(event.after, capture.columnInfos) {
+			tx.add(RowChange{Deleted: true, Values: event.before}, capture.columnInfos)
+		}
+		tx.add(RowChange{Values: event.after}, capture.columnInfos)
+
+	case "DELETE FROM":
+		tx.add(RowChange{Deleted: true, Values: event.before}, capture.columnInfos)
+	}
+
+	tx.Changes++
+
+	return nil
+}
+
+func (capture *mysqlChangeCapture) parseValue(text string, columnNum int) (value interface{}, err error) {
+	// converts a value printed by mysqlbinlog into what the mysql driver scans from a text
+	// query with parseTime, so changes are formatted the same way as the initial load. values
+	// mysqlbinlog prints differently, like enums as their index, are fetched from the source
+
+	text = strings.TrimSpace(text)
+	if text == "NULL" {
+		return nil, nil
+	}
+
+	columnInfo := capture.columnInfos[columnNum]
+	columnType := capture.columnTypes[columnNum]
+
+	if strings.HasPrefix(columnType, "enum") || strings.HasPrefix(columnType, "set") {
+		return unchangedValue{}, nil
+	}
+
+	switch columnInfo.PipeType {
+	case "int16", "int32", "int64":
+		// negative values of unsigned columns are followed by their unsigned value, like -1 (255)
+		signed, unsigned, found := strings.Cut(text, " (")
+		if found && strings.Contains(columnType, "unsigned") {
+			return []byte(strings.TrimSuffix(unsigned, ")")), nil
+		}
+		return []byte(signed), nil
+
+	case "float32", "float64", "decimal", "varchar":
+		return []byte(text), nil
+
+	case "datetime":
+		// timestamps are printed as unix time, datetimes as text
+		if !strings.HasPrefix(text, "'") {
+			whole, fraction, _ := strings.Cut(text, ".")
+			seconds, err := strconv.ParseInt(whole, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			nanos := int64(0)
+			if fraction != "" {
+				nanos, err = strconv.ParseInt((fraction + "000000000")[:9], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if seconds == 0 && nanos == 0 {
+				return time.Time{}, nil
+			}
+			return time.Unix(seconds, nanos).UTC(), nil
+		}
+		return parseMysqlbinlogTime(text, mysqlDatetimeFormat)
+
+	case "date":
+		// dates are printed with colons, like '2024:01:31'
+		return parseMysqlbinlogTime(strings.ReplaceAll(text, ":", "-"), mysqlDateFormat)
+
+	case "varbit":
+		bits := strings.TrimSuffix(strings.TrimPrefix(text, "b'"), "'")
+		bitValue, err := strconv.ParseUint(bits, 2, 64)
+		if err != nil {
+			return nil, err
+		}
+		numBytes := (len(bits) + 7) / 8
+		bitBytes := make([]byte, numBytes)
+		for i := numBytes - 1; i >= 0; i-- {
+			bitBytes[i] = byte(bitValue)
+			bitValue >>= 8
+		}
+		return bitBytes, nil
+	}
+
+	if !strings.HasPrefix(text, "'") {
+		return unchangedValue{}, nil
+	}
+
+	unquoted, err := unquoteMysqlbinlogString(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// binary columns are padded with zeros
+	if strings.HasPrefix(columnType, "binary") && columnInfo.LengthOk {
+		for int64(len(unquoted)) < columnInfo.Length {
+			unquoted = append(unquoted, 0)
+		}
+	}
+
+	return unquoted, nil
+}
+
+func parseMysqlbinlogTime(text, layout string) (value interface{}, err error) {
+
+	unquoted, err := unquoteMysqlbinlogString(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// zero dates are scanned as the zero time
+	if strings.Trim(string(unquoted), "0-: .") == "" {
+		return time.Time{}, nil
+	}
+
+	return time.ParseInLocation(layout, string(unquoted), time.UTC)
+}
+
+func unquoteMysqlbinlogString(text string) (unquoted []byte, err error) {
+	// mysqlbinlog quotes strings, and escapes control characters, quotes and backslashes as \x hex
+
+	if len(text) < 2 || text[0] != '\'' || text[len(text)-1] != '\'' {
+		return nil, fmt.Errorf("expected a quoted string, got %v", text)
+	}
+	text = text[1 : len(text)-1]
+
+	unquoted = make([]byte, 0, len(text))
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 >= len(text) {
+			unquoted = append(unquoted, text[i])
+			continue
+		}
+
+		if text[i+1] == 'x' && i+3 < len(text) {
+			b, err := strconv.ParseUint(text[i+2:i+4], 16, 8)
+			if err == nil {
+				unquoted = append(unquoted, byte(b))
+				i += 3
+				continue
+			}
+		}
+
+		unquoted = append(unquoted, text[i+1])
+		i++
+	}
+
+	return unquoted, nil
+}
+
+func newUnchangedValues(numColumns int) (values []interface{}) {
+	values = make([]interface{}, numColumns)
+	for i := range values {
+		values[i] = unchangedValue{}
+	}
+	return values
+}
+
+func isUnchanged(value interface{}) bool {
+	_, unchanged := value.(unchangedValue)
+	return unchanged
+}
+
+func (capture *mysqlChangeCapture) fetchRow(values []interface{}) (found bool, err error) {
+	// reads the row's current values, for columns that weren't in the binlog or were printed
+	// in a form that can't be converted. values are written into the query so the driver
+	// returns text, like it does for the initial load
+
+	pipeFileFormatters := capture.system.getPipeFileFormatters()
+	sqlFormatters := capture.system.getSqlFormatters()
+
+	escapedColumns := []string{}
+	where := []string{}
+
+	for i := range capture.columnInfos {
+		escapedColumn := escapeIfNeeded(capture.columnInfos[i].Name, capture.system)
+		escapedColumns = append(escapedColumns, escapedColumn)
+
+		if !capture.columnInfos[i].IsPrimaryKey {
+			continue
+		}
+
+		pipeFileValue, err := pipeFileFormatters[capture.columnInfos[i].PipeType](values[i])
+		if err != nil {
+			return false, fmt.Errorf("error formatting primary key value :: %v", err)
+		}
+
+		sqlValue, err := sqlFormatters[capture.columnInfos[i].PipeType](pipeFileValue)
+		if err != nil {
+			return false, fmt.Errorf("error formatting primary key value :: %v", err)
+		}
+
+		where = append(where, fmt.Sprintf("%v = %v", escapedColumn, sqlValue))
+	}
+
+	query := fmt.Sprintf("select %v from %v where %v",
+		strings.Join(escapedColumns, ", "),
+		getSchemaPeriodTable(capture.transfer.SourceSchema, capture.transfer.SourceTable, capture.system, true),
+		strings.Join(where, " and "),
+	)
+
+	valuePtrs := make([]interface{}, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	err = capture.system.queryRow(query).Scan(valuePtrs...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error running %v :: %v", query, err)
+	}
+
+	return true, nil
+}
+
+func (capture *mysqlChangeCapture) confirm(position string) (err error) {
+	// the binlog isn't changed by reading it, so the position is only kept for the next batch
+	capture.position = position
+	return nil
+}
+
+func (capture *mysqlChangeCapture) close() {}
+
+var mysqlOptionFileEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func parseBinlogPosition(position string) (file string, offset int64, err error) {
+
+	// a gtid set has a colon too, but would be taken for a binlog file that doesn't exist
+	if mysqlGtidSetRegexp.MatchString(position) {
+		return "", 0, fmt.Errorf("binlog position %v looks like a gtid set, which isn't supported. use a binlog file and position like binlog.000001:4", position)
+	}
+
+	separator := strings.LastIndex(position, ":")
+	if separator < 1 {
+		return "", 0, fmt.Errorf("binlog position %v must look like binlog.000001:4", position)
+	}
+
+	offset, err = strconv.ParseInt(position[separator+1:], 10, 64)
+	if err != nil || offset < 4 {
+		return "", 0, fmt.Errorf("binlog position %v must look like binlog.000001:4", position)
+	}
+
+	return position[:separator], offset, nil
+}
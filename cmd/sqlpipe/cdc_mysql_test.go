@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBinlogPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		position   string
+		wantFile   string
+		wantOffset int64
+		wantErr    string
+	}{
+		{"file and offset", "binlog.000001:4", "binlog.000001", 4, ""},
+		{"large offset", "mysql-bin.000123:9876543210", "mysql-bin.000123", 9876543210, ""},
+		{"path with a colon splits on the last one", "C:/data/binlog.000002:120", "C:/data/binlog.000002", 120, ""},
+		{"offset before the binlog header", "binlog.000001:3", "", 0, "must look like"},
+		{"negative offset", "binlog.000001:-4", "", 0, "must look like"},
+		{"offset that isn't a number", "binlog.000001:abc", "", 0, "must look like"},
+		{"no offset", "binlog.000001", "", 0, "must look like"},
+		{"no file", ":4", "", 0, "must look like"},
+		{"empty", "", "", 0, "must look like"},
+		{"gtid set", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", "", 0, "gtid set"},
+		{"uppercase gtid set", "3E11FA47-71CA-11E1-9E33-C80AA9429562:23", "", 0, "gtid set"},
+		{"several gtid sets", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,4a6a4f52-71ca-11e1-9e33-c80aa9429562:1-3", "", 0, "gtid set"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, offset, err := parseBinlogPosition(test.position)
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file != test.wantFile || offset != test.wantOffset {
+				t.Fatalf("got %v and %v, want %v and %v", file, offset, test.wantFile, test.wantOffset)
+			}
+		})
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// oracle changes come from a row trigger on the source table, which writes the primary keys of
+// each changed row to a tracking table next to it, numbered by a tracking sequence. each batch
+// joins the tracked keys to the table to read the rows as they are now, keys with no row left
+// were deleted, and the tracking rows are deleted once their changes are applied
+
+const (
+	oracleTrackingTablePrefix    = "SQLPIPE_TRK_"
+	oracleTrackingTriggerPrefix  = "SQLPIPE_TRG_"
+	oracleTrackingSequencePrefix = "SQLPIPE_SEQ_"
+	oracleTrackingIdColumn       = "SQLPIPE_TRACKING_ID"
+	// oracle allows at most 1000 expressions in an in list
+	oracleDeleteChunkSize = 1000
+)
+
+type oracleChangeCapture struct {
+	system      Oracle
+	transfer    Transfer
+	columnInfos []ColumnInfo
+	// the highest tracking version applied so far
+	position string
+	// tracking rows read by the last batch, deleted once it is applied
+	pendingIds []int64
+}
+
+func (system Oracle) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return &oracleChangeCapture{
+		system:      system,
+		transfer:    transfer,
+		columnInfos: columnInfos,
+		position:    transfer.CdcPosition,
+	}, nil
+}
+
+func getOracleTrackingSuffix(table string, system Oracle) string {
+	// tracking objects are named after a hash of the table, since oracle names can be as short as
+	// 30 characters. unquoted names are stored in upper case, so they hash the same either way
+
+	if !needsEscaping(table, system) {
+		table = strings.ToUpper(table)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(table))
+
+	return fmt.Sprintf("%08X", hash.Sum32())
+}
+
+func (capture *oracleChangeCapture) getEscapedTable() string {
+	return getSchemaPeriodTable(capture.transfer.SourceSchema, capture.transfer.SourceTable, capture.system, true)
+}
+
+func (capture *oracleChangeCapture) getTrackingObject(prefix string) string {
+	return fmt.Sprintf("%v.%v%v",
+		escapeIfNeeded(capture.transfer.SourceSchema, capture.system),
+		prefix,
+		getOracleTrackingSuffix(capture.transfer.SourceTable, capture.system),
+	)
+}
+
+func (capture *oracleChangeCapture) start() (err error) {
+
+	if capture.position != "" {
+		_, err = strconv.ParseInt(capture.position, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cdc-position %v must be a tracking version", capture.position)
+		}
+	}
+
+	trackingTable := oracleTrackingTablePrefix + getOracleTrackingSuffix(capture.transfer.SourceTable, capture.system)
+
+	var tableExists int
+	err = capture.system.queryRow(fmt.Sprintf("select count(*) from all_tables where owner = upper('%v') and table_name = '%v'",
+		capture.transfer.SourceSchema, trackingTable)).Scan(&tableExists)
+	if err != nil {
+		return fmt.Errorf("error checking for tracking table :: %v", err)
+	}
+
+	// resuming only works if the trigger kept tracking changes since the last transfer stopped
+	if capture.position != "" {
+		if tableExists == 0 {
+			return fmt.Errorf("cannot resume, tracking table %v does not exist", capture.getTrackingObject(oracleTrackingTablePrefix))
+		}
+		infoLog.Printf("transfer %v resuming changes tracked in %v", capture.transfer.Id, capture.getTrackingObject(oracleTrackingTablePrefix))
+		return nil
+	}
+
+	if tableExists == 0 {
+		err = capture.createTrackingObjects()
+		if err != nil {
+			return err
+		}
+	} else {
+		// the initial load covers anything tracked before it
+		err = capture.system.exec(fmt.Sprintf("delete from %v", capture.getTrackingObject(oracleTrackingTablePrefix)))
+		if err != nil {
+			return fmt.Errorf("error clearing tracking table :: %v", err)
+		}
+	}
+
+	err = capture.createTrackingTrigger()
+	if err != nil {
+		return err
+	}
+
+	capture.position = "0"
+
+	infoLog.Printf("transfer %v tracking changes to %v in %v", capture.transfer.Id, capture.getEscapedTable(), capture.getTrackingObject(oracleTrackingTablePrefix))
+
+	return nil
+}
+
+func (capture *oracleChangeCapture) createTrackingObjects() (err error) {
+
+	pks := []string{}
+	for i := range capture.columnInfos {
+		if capture.columnInfos[i].IsPrimaryKey {
+			pks = append(pks, escapeIfNeeded(capture.columnInfos[i].Name, capture.system))
+		}
+	}
+
+	err = capture.system.exec(fmt.Sprintf("create table %v as select %v from %v where 1=0",
+		capture.getTrackingObject(oracleTrackingTablePrefix), strings.Join(pks, ", "), capture.getEscapedTable()))
+	if err != nil {
+		return fmt.Errorf("error creating tracking table :: %v", err)
+	}
+
+	err = capture.system.exec(fmt.Sprintf("alter table %v add %v number(19) primary key",
+		capture.getTrackingObject(oracleTrackingTablePrefix), oracleTrackingIdColumn))
+	if err != nil {
+		return fmt.Errorf("error adding tracking id to tracking table :: %v", err)
+	}
+
+	var sequenceExists int
+	err = capture.system.queryRow(fmt.Sprintf("select count(*) from all_sequences where sequence_owner = upper('%v') and sequence_name = '%v'",
+		capture.transfer.SourceSchema, oracleTrackingSequencePrefix+getOracleTrackingSuffix(capture.transfer.SourceTable, capture.system))).Scan(&sequenceExists)
+	if err != nil {
+		return fmt.Errorf("error checking for tracking sequence :: %v", err)
+	}
+
+	if sequenceExists == 0 {
+		err = capture.system.exec(fmt.Sprintf("create sequence %v", capture.getTrackingObject(oracleTrackingSequencePrefix)))
+		if err != nil {
+			return fmt.Errorf("error creating tracking sequence :: %v", err)
+		}
+	}
+
+	infoLog.Printf("transfer %v created tracking table %v", capture.transfer.Id, capture.getTrackingObject(oracleTrackingTablePrefix))
+
+	return nil
+}
+
+func (capture *oracleChangeCapture) createTrackingTrigger() (err error) {
+	// an update records the old keys too, so a row whose key changed is deleted under the old one
+
+	pks := []string{}
+	oldPks := []string{}
+	newPks := []string{}
+	for i := range capture.columnInfos {
+		if capture.columnInfos[i].IsPrimaryKey {
+			escapedColumn := escapeIfNeeded(capture.columnInfos[i].Name, capture.system)
+			pks = append(pks, escapedColumn)
+			oldPks = append(oldPks, ":old."+escapedColumn)
+			newPks = append(newPks, ":new."+escapedColumn)
+		}
+	}
+
+	insert := fmt.Sprintf("insert into %v (%v, %v) values (%%v, %v.nextval);",
+		capture.getTrackingObject(oracleTrackingTablePrefix),
+		strings.Join(pks, ", "),
+		oracleTrackingIdColumn,
+		capture.getTrackingObject(oracleTrackingSequencePrefix),
+	)
+
+	query := fmt.Sprintf(`
+		create or replace trigger %v
+		after insert or update or delete on %v
+		for each row
+		begin
+			if deleting or updating then
+				%v
+			end if;
+			if inserting or updating then
+				%v
+			end if;
+		end;`,
+		capture.getTrackingObject(oracleTrackingTriggerPrefix),
+		capture.getEscapedTable(),
+		fmt.Sprintf(insert, strings.Join(oldPks, ", ")),
+		fmt.Sprintf(insert, strings.Join(newPks, ", ")),
+	)
+
+	err = capture.system.exec(query)
+	if err != nil {
+		return fmt.Errorf("error creating tracking trigger :: %v", err)
+	}
+
+	return nil
+}
+
+func (capture *oracleChangeCapture) nextBatch(deadline time.Time) (batch *ChangeBatch, err error) {
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-capture.transfer.Context.Done():
+		return nil, capture.transfer.Context.Err()
+	case <-timer.C:
+	}
+
+	selects := []string{}
+	joins := []string{}
+	var firstPk string
+
+	for i := range capture.columnInfos {
+		escapedColumn := escapeIfNeeded(capture.columnInfos[i].Name, capture.system)
+		if capture.columnInfos[i].IsPrimaryKey {
+			// keys come from the tracking table, so deleted rows still have them
+			selects = append(selects, fmt.Sprintf("ct.%v", escapedColumn))
+			joins = append(joins, fmt.Sprintf("t.%v = ct.%v", escapedColumn, escapedColumn))
+			if firstPk == "" {
+				firstPk = escapedColumn
+			}
+		} else {
+			selects = append(selects, fmt.Sprintf("t.%v", escapedColumn))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		select
+			ct.%v,
+			%v,
+			case when t.%v is null then 1 else 0 end
+		from
+			%v ct
+			left join %v t on %v
+		order by
+			ct.%v`,
+		oracleTrackingIdColumn,
+		strings.Join(selects, ", "),
+		firstPk,
+		capture.getTrackingObject(oracleTrackingTablePrefix),
+		capture.getEscapedTable(),
+		strings.Join(joins, " and "),
+		oracleTrackingIdColumn,
+	)
+
+	rows, err := capture.system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error reading changes :: %v", err)
+	}
+	defer rows.Close()
+
+	batch = newChangeBatch()
+	batch.Position = capture.position
+	capture.pendingIds = nil
+
+	maxId, err := strconv.ParseInt(capture.position, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tracking version :: %v", err)
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(capture.columnInfos))
+		var trackingId int64
+		var deleted int64
+
+		dest := make([]interface{}, len(values)+2)
+		dest[0] = &trackingId
+		for i := range values {
+			dest[i+1] = &values[i]
+		}
+		dest[len(values)+1] = &deleted
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning changes :: %v", err)
+		}
+
+		batch.add(RowChange{Deleted: deleted == 1, Values: values}, capture.columnInfos)
+		batch.Changes++
+
+		capture.pendingIds = append(capture.pendingIds, trackingId)
+		if trackingId > maxId {
+			maxId = trackingId
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating changes :: %v", err)
+	}
+
+	batch.Position = strconv.FormatInt(maxId, 10)
+
+	return batch, nil
+}
+
+func (capture *oracleChangeCapture) confirm(position string) (err error) {
+	// sequence values can commit out of order, so only the rows that were read are deleted
+
+	for start := 0; start < len(capture.pendingIds); start += oracleDeleteChunkSize {
+		end := start + oracleDeleteChunkSize
+		if end > len(capture.pendingIds) {
+			end = len(capture.pendingIds)
+		}
+
+		ids := make([]string, end-start)
+		for i, id := range capture.pendingIds[start:end] {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+
+		err = capture.system.exec(fmt.Sprintf("delete from %v where %v in (%v)",
+			capture.getTrackingObject(oracleTrackingTablePrefix), oracleTrackingIdColumn, strings.Join(ids, ", ")))
+		if err != nil {
+			return fmt.Errorf("error deleting consumed tracking rows :: %v", err)
+		}
+	}
+
+	capture.pendingIds = nil
+	capture.position = position
+
+	return nil
+}
+
+func (capture *oracleChangeCapture) close() {
+	// the trigger is left in place, so a new transfer can resume from the tracking table. the
+	// uninstall endpoint removes it
+}
+
+func uninstallOracleTracking(system Oracle, schema, table string) (dropped []string, err error) {
+	// drops the tracking objects for one table, or every table in the schema. triggers go first,
+	// since a trigger left without its tracking table would fail every write to the source table
+
+	objectTypes := []struct {
+		objectType string
+		prefix     string
+		query      string
+	}{
+		{"trigger", oracleTrackingTriggerPrefix, "select trigger_name from all_triggers where owner = upper('%v') and trigger_name like '%v' escape '\\'"},
+		{"table", oracleTrackingTablePrefix, "select table_name from all_tables where owner = upper('%v') and table_name like '%v' escape '\\'"},
+		{"sequence", oracleTrackingSequencePrefix, "select sequence_name from all_sequences where sequence_owner = upper('%v') and sequence_name like '%v' escape '\\'"},
+	}
+
+	escapedSchema := escapeIfNeeded(schema, system)
+
+	for _, objectType := range objectTypes {
+
+		// underscores are wildcards in like patterns
+		pattern := strings.ReplaceAll(objectType.prefix, "_", `\_`) + "%"
+		if table != "" {
+			pattern = strings.ReplaceAll(objectType.prefix, "_", `\_`) + getOracleTrackingSuffix(table, system)
+		}
+
+		rows, err := system.query(fmt.Sprintf(objectType.query, schema, pattern))
+		if err != nil {
+			return dropped, fmt.Errorf("error finding tracking %vs :: %v", objectType.objectType, err)
+		}
+
+		names := []string{}
+		for rows.Next() {
+			var name string
+			err = rows.Scan(&name)
+			if err != nil {
+				rows.Close()
+				return dropped, fmt.Errorf("error scanning tracking %v name :: %v", objectType.objectType, err)
+			}
+			names = append(names, name)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return dropped, fmt.Errorf("error iterating tracking %vs :: %v", objectType.objectType, err)
+		}
+
+		for _, name := range names {
+			object := fmt.Sprintf("%v.%v", escapedSchema, name)
+
+			err = system.exec(fmt.Sprintf("drop %v %v", objectType.objectType, object))
+			if err != nil {
+				return dropped, fmt.Errorf("error dropping tracking %v :: %v", objectType.objectType, err)
+			}
+
+			infoLog.Printf("dropped tracking %v %v from %v", objectType.objectType, object, system.Name)
+			dropped = append(dropped, object)
+		}
+	}
+
+	return dropped, nil
+}
+
+func uninstallOracleTrackingHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SourceName             string `json:"source-name"`
+		SourceConnectionString string `json:"source-connection-string"`
+		SourceSchema           string `json:"source-schema"`
+		SourceTable            string `json:"source-table"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		clientErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	v := newValidator()
+	v.check(input.SourceName != "", "source-name", "must be provided")
+	v.check(input.SourceConnectionString != "", "source-connection-string", "must be provided")
+	v.check(input.SourceSchema != "", "source-schema", "must be provided")
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	// a running transfer would fail on its next batch once the tracking table is gone
+	for _, transfer := range transferMap.GetAll() {
+		if transfer.Cdc && transfer.Status == StatusRunning &&
+			transfer.SourceConnectionInfo.Type == TypeOracle &&
+			transfer.SourceConnectionInfo.ConnectionString == input.SourceConnectionString &&
+			strings.EqualFold(transfer.SourceSchema, input.SourceSchema) &&
+			(input.SourceTable == "" || strings.EqualFold(transfer.SourceTable, input.SourceTable)) {
+			clientErrorResponse(w, r, http.StatusConflict,
+				fmt.Errorf("transfer %v is capturing changes from %v.%v, cancel it first", transfer.Id, transfer.SourceSchema, transfer.SourceTable),
+			)
+			return
+		}
+	}
+
+	system, err := newOracle(ConnectionInfo{
+		Name:             input.SourceName,
+		Type:             TypeOracle,
+		ConnectionString: input.SourceConnectionString,
+	})
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer system.closeConnectionPool(true)
+
+	dropped, err := uninstallOracleTracking(system, input.SourceSchema, input.SourceTable)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(dropped) == 0 {
+		clientErrorResponse(w, r, http.StatusNotFound, errors.New("no tracking objects found"))
+		return
+	}
+
+	infoLog.Printf("ip %v uninstalled oracle change tracking from %v", r.RemoteAddr, input.SourceName)
+
+	err = writeJSON(w, http.StatusOK, envelope{"dropped": dropped}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// postgresql changes are read from a logical replication slot with the pgoutput plugin that
+// ships with postgresql 10 and later. the source needs wal_level set to logical, and the user
+// needs the replication attribute and permission to create a publication on the table
+
+const postgresqlStatusInterval = 10 * time.Second
+
+type postgresqlChangeCapture struct {
+	system      Postgresql
+	transfer    Transfer
+	columnInfos []ColumnInfo
+	// the slot and the publication share a name
+	name             string
+	connectionString string
+
+	conn      *pgconn.PgConn
+	typeMap   *pgtype.Map
+	relations map[uint32]pgoutputRelation
+	// changes of the transaction being received, nil between transactions
+	tx           *ChangeBatch
+	receivedLsn  uint64
+	confirmedLsn uint64
+	nextStatusAt time.Time
+}
+
+type pgoutputRelation struct {
+	namespace string
+	name      string
+	columns   []pgoutputColumn
+}
+
+type pgoutputColumn struct {
+	name string
+	oid  uint32
+	// the column's position in the table's column infos, -1 if it was added after the transfer started
+	columnInfoIndex int
+}
+
+func (system Postgresql) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return &postgresqlChangeCapture{
+		system:           system,
+		transfer:         transfer,
+		columnInfos:      columnInfos,
+		name:             fmt.Sprintf("sqlpipe_%v", strings.ReplaceAll(transfer.Id, "-", "")),
+		connectionString: transfer.SourceConnectionInfo.ConnectionString,
+		typeMap:          pgtype.NewMap(),
+		relations:        map[uint32]pgoutputRelation{},
+	}, nil
+}
+
+func (capture *postgresqlChangeCapture) start() (err error) {
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(capture.transfer.SourceSchema, capture.transfer.SourceTable, capture.system, true)
+
+	err = capture.system.exec(fmt.Sprintf("create publication %v for table %v", capture.name, escapedSchemaPeriodTable))
+	if err != nil {
+		return fmt.Errorf("error creating publication :: %v", err)
+	}
+
+	var lsn string
+	err = capture.system.queryRow(fmt.Sprintf("select lsn::text from pg_create_logical_replication_slot('%v', 'pgoutput')", capture.name)).Scan(&lsn)
+	if err != nil {
+		return fmt.Errorf("error creating replication slot :: %v", err)
+	}
+
+	infoLog.Printf("transfer %v created publication and replication slot %v at %v", capture.transfer.Id, capture.name, lsn)
+
+	return nil
+}
+
+func (capture *postgresqlChangeCapture) startStreaming() (err error) {
+
+	config, err := pgconn.ParseConfig(capture.connectionString)
+	if err != nil {
+		return fmt.Errorf("error parsing connection string :: %v", err)
+	}
+	config.RuntimeParams["replication"] = "database"
+
+	capture.conn, err = pgconn.ConnectConfig(capture.transfer.Context, config)
+	if err != nil {
+		return fmt.Errorf("error opening replication connection :: %v", err)
+	}
+
+	// streaming from 0/0 starts where the slot was last confirmed
+	query := fmt.Sprintf("START_REPLICATION SLOT %v LOGICAL 0/0 (proto_version '1', publication_names '%v')", capture.name, capture.name)
+
+	capture.conn.Frontend().SendQuery(&pgproto3.Query{String: query})
+	err = capture.conn.Frontend().Flush()
+	if err != nil {
+		return fmt.Errorf("error sending start replication :: %v", err)
+	}
+
+	for {
+		msg, err := capture.conn.ReceiveMessage(capture.transfer.Context)
+		if err != nil {
+			return fmt.Errorf("error starting replication :: %v", err)
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			capture.nextStatusAt = time.Now().Add(postgresqlStatusInterval)
+			return nil
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("error starting replication :: %v", pgconn.ErrorResponseToPgError(msg))
+		}
+	}
+}
+
+func (capture *postgresqlChangeCapture) nextBatch(deadline time.Time) (batch *ChangeBatch, err error) {
+
+	if capture.conn == nil {
+		err = capture.startStreaming()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	batch = newChangeBatch()
+
+	for {
+		now := time.Now()
+
+		// the server disconnects clients that don't report their position
+		if !now.Before(capture.nextStatusAt) {
+			err = capture.sendStatus()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !now.Before(deadline) {
+			break
+		}
+
+		waitUntil := deadline
+		if capture.nextStatusAt.Before(waitUntil) {
+			waitUntil = capture.nextStatusAt
+		}
+
+		ctx, cancel := context.WithDeadline(capture.transfer.Context, waitUntil)
+		msg, err := capture.conn.ReceiveMessage(ctx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) {
+				continue
+			}
+			return nil, fmt.Errorf("error receiving replication message :: %v", err)
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			err = capture.handleCopyData(msg.Data, batch)
+			if err != nil {
+				return nil, err
+			}
+		case *pgproto3.ErrorResponse:
+			return nil, fmt.Errorf("error streaming changes :: %v", pgconn.ErrorResponseToPgError(msg))
+		}
+	}
+
+	err = batch.resolveUnchanged(capture.fetchRow)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching unchanged values :: %v", err)
+	}
+
+	// between transactions, everything the server has sent is in the batch, so the slot can move
+	// past it even if none of it was for this table
+	if capture.tx == nil && capture.receivedLsn > 0 {
+		batch.Position = formatLsn(capture.receivedLsn)
+	}
+
+	return batch, nil
+}
+
+func (capture *postgresqlChangeCapture) handleCopyData(data []byte, batch *ChangeBatch) (err error) {
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	switch data[0] {
+	case 'k':
+		// a keepalive has the server's position, and asks for a reply if the server is about to time out
+		if len(data) < 18 {
+			return errors.New("keepalive message too short")
+		}
+		walEnd := binary.BigEndian.Uint64(data[1:9])
+		if capture.tx == nil && walEnd > capture.receivedLsn {
+			capture.receivedLsn = walEnd
+		}
+		if data[17] != 0 {
+			return capture.sendStatus()
+		}
+	case 'w':
+		if len(data) < 25 {
+			return errors.New("wal data message too short")
+		}
+		err = capture.decode(data[25:], batch)
+		if err != nil {
+			return fmt.Errorf("error decoding pgoutput message :: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (capture *postgresqlChangeCapture) decode(msg []byte, batch *ChangeBatch) (err error) {
+	// decodes a pgoutput message, see https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html
+
+	if len(msg) == 0 {
+		return nil
+	}
+
+	reader := &pgoutputReader{buf: msg[1:]}
+
+	switch msg[0] {
+	case 'B':
+		capture.tx = newChangeBatch()
+
+	case 'C':
+		reader.byte()
+		reader.uint64()
+		endLsn := reader.uint64()
+		if reader.err != nil {
+			return reader.err
+		}
+		if capture.tx != nil {
+			capture.tx.Position = formatLsn(endLsn)
+			batch.merge(capture.tx, capture.columnInfos)
+		}
+		capture.tx = nil
+		if endLsn > capture.receivedLsn {
+			capture.receivedLsn = endLsn
+		}
+
+	case 'R':
+		relationId := reader.uint32()
+		relation := pgoutputRelation{
+			namespace: reader.string(),
+			name:      reader.string(),
+		}
+		reader.byte()
+		numColumns := int(reader.uint16())
+		for i := 0; i < numColumns; i++ {
+			reader.byte()
+			column := pgoutputColumn{
+				name:            reader.string(),
+				oid:             reader.uint32(),
+				columnInfoIndex: -1,
+			}
+			reader.uint32()
+			for j := range capture.columnInfos {
+				if capture.columnInfos[j].Name == column.name {
+					column.columnInfoIndex = j
+				}
+			}
+			relation.columns = append(relation.columns, column)
+		}
+		if reader.err != nil {
+			return reader.err
+		}
+		capture.relations[relationId] = relation
+
+	case 'I':
+		relation, err := capture.getRelation(reader.uint32())
+		if err != nil {
+			return err
+		}
+		reader.byte()
+		values, err := capture.readTuple(reader, relation)
+		if err != nil {
+			return err
+		}
+		capture.addChange(RowChange{Values: values})
+
+	case 'U':
+		relation, err := capture.getRelation(reader.uint32())
+		if err != nil {
+			return err
+		}
+
+		// the old row is only sent if its key changed, or the table's replica identity is full
+		tupleType := reader.byte()
+		var oldValues []interface{}
+		if tupleType == 'K' || tupleType == 'O' {
+			oldValues, err = capture.readTuple(reader, relation)
+			if err != nil {
+				return err
+			}
+			reader.byte()
+		}
+
+		values, err := capture.readTuple(reader, relation)
+		if err != nil {
+			return err
+		}
+
+		if oldValues != nil && getPkKey(oldValues, capture.columnInfos) != getPkKey(values, capture.columnInfos) {
+			capture.addChange(RowChange{Deleted: true, Values: oldValues})
+		}
+		capture.addChange(RowChange{Values: values})
+
+	case 'D':
+		relation, err := capture.getRelation(reader.uint32())
+		if err != nil {
+			return err
+		}
+		reader.byte()
+		values, err := capture.readTuple(reader, relation)
+		if err != nil {
+			return err
+		}
+		capture.addChange(RowChange{Deleted: true, Values: values})
+
+	case 'T':
+		// the publication only has the source table, so any truncate is of it
+		if capture.tx != nil {
+			capture.tx.truncate()
+			capture.tx.Changes++
+		}
+	}
+
+	return nil
+}
+
+func (capture *postgresqlChangeCapture) addChange(change RowChange) {
+	if capture.tx == nil {
+		return
+	}
+	capture.tx.add(change, capture.columnInfos)
+	capture.tx.Changes++
+}
+
+func (capture *postgresqlChangeCapture) getRelation(relationId uint32) (relation pgoutputRelation, err error) {
+	relation, ok := capture.relations[relationId]
+	if !ok {
+		return relation, fmt.Errorf("change for relation %v arrived before its relation message", relationId)
+	}
+	return relation, nil
+}
+
+func (capture *postgresqlChangeCapture) readTuple(reader *pgoutputReader, relation pgoutputRelation) (values []interface{}, err error) {
+	// columns are sent as text. large values that didn't change aren't sent at all
+
+	values = make([]interface{}, len(capture.columnInfos))
+
+	numColumns := int(reader.uint16())
+	for i := 0; i < numColumns; i++ {
+
+		kind := reader.byte()
+
+		var data []byte
+		if kind == 't' {
+			data = reader.bytes(int(reader.uint32()))
+		}
+
+		if reader.err != nil {
+			return nil, reader.err
+		}
+
+		if i >= len(relation.columns) || relation.columns[i].columnInfoIndex < 0 {
+			continue
+		}
+		column := relation.columns[i]
+
+		switch kind {
+		case 'n':
+			values[column.columnInfoIndex] = nil
+		case 'u':
+			values[column.columnInfoIndex] = unchangedValue{}
+		case 't':
+			values[column.columnInfoIndex], err = pgTextToDriverValue(capture.typeMap, column.oid, data)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding column %v :: %v", column.name, err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported tuple column kind %q", kind)
+		}
+	}
+
+	return values, nil
+}
+
+func (capture *postgresqlChangeCapture) fetchRow(values []interface{}) (found bool, err error) {
+	// reads the row's current values, for rows that were updated without sending every column
+
+	escapedColumns := []string{}
+	where := []string{}
+	args := []interface{}{}
+
+	for i := range capture.columnInfos {
+		escapedColumn := escapeIfNeeded(capture.columnInfos[i].Name, capture.system)
+		escapedColumns = append(escapedColumns, escapedColumn)
+		if capture.columnInfos[i].IsPrimaryKey {
+			args = append(args, values[i])
+			where = append(where, fmt.Sprintf("%v = $%v", escapedColumn, len(args)))
+		}
+	}
+
+	query := fmt.Sprintf("select %v from %v where %v",
+		strings.Join(escapedColumns, ", "),
+		getSchemaPeriodTable(capture.transfer.SourceSchema, capture.transfer.SourceTable, capture.system, true),
+		strings.Join(where, " and "),
+	)
+
+	valuePtrs := make([]interface{}, len(values))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	err = capture.system.Connection.QueryRow(query, args...).Scan(valuePtrs...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error running %v :: %v", query, err)
+	}
+
+	return true, nil
+}
+
+func (capture *postgresqlChangeCapture) confirm(position string) (err error) {
+
+	lsn, err := parseLsn(position)
+	if err != nil {
+		return err
+	}
+
+	capture.confirmedLsn = lsn
+
+	return capture.sendStatus()
+}
+
+func (capture *postgresqlChangeCapture) sendStatus() (err error) {
+	// the flushed position is what the slot keeps wal for, so it's only moved once changes are applied
+
+	written := capture.receivedLsn
+	if capture.confirmedLsn > written {
+		written = capture.confirmedLsn
+	}
+
+	postgresEpoch := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	status := make([]byte, 34)
+	status[0] = 'r'
+	binary.BigEndian.PutUint64(status[1:], written)
+	binary.BigEndian.PutUint64(status[9:], capture.confirmedLsn)
+	binary.BigEndian.PutUint64(status[17:], capture.confirmedLsn)
+	binary.BigEndian.PutUint64(status[25:], uint64(time.Since(postgresEpoch).Microseconds()))
+
+	copyData := &pgproto3.CopyData{Data: status}
+
+	err = capture.conn.Frontend().SendUnbufferedEncodedCopyData(copyData.Encode(nil))
+	if err != nil {
+		return fmt.Errorf("error sending standby status :: %v", err)
+	}
+
+	capture.nextStatusAt = time.Now().Add(postgresqlStatusInterval)
+
+	return nil
+}
+
+func (capture *postgresqlChangeCapture) close() {
+
+	if capture.conn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		capture.conn.Close(ctx)
+		cancel()
+	}
+
+	// the slot stays active for a moment after its connection closes
+	var err error
+	for i := 0; i < 10; i++ {
+		err = capture.system.exec(fmt.Sprintf("select pg_drop_replication_slot(slot_name) from pg_replication_slots where slot_name = '%v'", capture.name))
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		errorLog.Printf("transfer %v error dropping replication slot %v, drop it to stop the source keeping wal for it :: %v", capture.transfer.Id, capture.name, err)
+	}
+
+	err = capture.system.exec(fmt.Sprintf("drop publication if exists %v", capture.name))
+	if err != nil {
+		errorLog.Printf("transfer %v error dropping publication %v :: %v", capture.transfer.Id, capture.name, err)
+		return
+	}
+
+	infoLog.Printf("transfer %v dropped publication and replication slot %v", capture.transfer.Id, capture.name)
+}
+
+type pgoutputReader struct {
+	// reads the big endian fields of a pgoutput message, remembering the first error
+	buf []byte
+	err error
+}
+
+func (reader *pgoutputReader) bytes(n int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+	if n < 0 || len(reader.buf) < n {
+		reader.err = errors.New("pgoutput message too short")
+		return nil
+	}
+	b := reader.buf[:n]
+	reader.buf = reader.buf[n:]
+	return b
+}
+
+func (reader *pgoutputReader) byte() byte {
+	b := reader.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (reader *pgoutputReader) uint16() uint16 {
+	b := reader.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (reader *pgoutputReader) uint32() uint32 {
+	b := reader.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (reader *pgoutputReader) uint64() uint64 {
+	b := reader.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (reader *pgoutputReader) string() string {
+	if reader.err != nil {
+		return ""
+	}
+	end := strings.IndexByte(string(reader.buf), 0)
+	if end < 0 {
+		reader.err = errors.New("pgoutput string not terminated")
+		return ""
+	}
+	s := string(reader.buf[:end])
+	reader.buf = reader.buf[end+1:]
+	return s
+}
+
+func pgTextToDriverValue(typeMap *pgtype.Map, oid uint32, src []byte) (value driver.Value, err error) {
+	// decodes a text value into the same type the pgx database/sql driver would scan, so
+	// changes are formatted into pipe files the same way as the initial load
+
+	switch oid {
+	case pgtype.BoolOID:
+		var d bool
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		return d, err
+	case pgtype.ByteaOID:
+		var d []byte
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		return d, err
+	case pgtype.CIDOID, pgtype.OIDOID, pgtype.XIDOID:
+		var d pgtype.Uint32
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		if err != nil {
+			return nil, err
+		}
+		return d.Value()
+	case pgtype.DateOID:
+		var d pgtype.Date
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		if err != nil {
+			return nil, err
+		}
+		return d.Value()
+	case pgtype.Float4OID:
+		var d float32
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		return float64(d), err
+	case pgtype.Float8OID:
+		var d float64
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		return d, err
+	case pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID:
+		var d int64
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		return d, err
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		return append([]byte{}, src...), nil
+	case pgtype.TimestampOID:
+		var d pgtype.Timestamp
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		if err != nil {
+			return nil, err
+		}
+		return d.Value()
+	case pgtype.TimestamptzOID:
+		var d pgtype.Timestamptz
+		err = typeMap.Scan(oid, pgtype.TextFormatCode, src, &d)
+		if err != nil {
+			return nil, err
+		}
+		return d.Value()
+	default:
+		return string(src), nil
+	}
+}
+
+func parseLsn(lsn string) (parsed uint64, err error) {
+	var upper, lower uint32
+	_, err = fmt.Sscanf(lsn, "%X/%X", &upper, &lower)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing lsn %v :: %v", lsn, err)
+	}
+	return uint64(upper)<<32 | uint64(lower), nil
+}
+
+func formatLsn(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
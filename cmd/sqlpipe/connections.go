@@ -0,0 +1,863 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+)
+
+// connections are stored once and used by name in transfers, instead of sending connection
+// strings with every transfer. they're encrypted with a key from the environment before they
+// reach the store, and passwords never leave sqlpipe. responses show connection strings with
+// their passwords replaced, and every secret sqlpipe knows about is replaced in logs
+
+var (
+	connectionKeyEnv     = "SQLPIPE_CONNECTION_KEY"
+	nameRegex            = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	redactedSecret       = "xxxxx"
+	secretSettingRegex   = regexp.MustCompile(`(?i)(?:^|;)\s*(?:password|pwd)\s*=\s*([^;]*)`)
+	spacedSettingRegex   = regexp.MustCompile(`(?i)(?:^|\s)password\s*=\s*('[^']*'|\S*)`)
+	dsnPasswordRegex     = regexp.MustCompile(`^[^:/@]+:(.*)@`)
+	minRedactedSecretLen = 4
+	errConnectionExists  = errors.New("a connection with this name already exists")
+)
+
+type Connection struct {
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	ConnectionString string    `json:"connection-string"`
+	Hostname         string    `json:"hostname,omitempty"`
+	Port             int       `json:"port,omitempty"`
+	Database         string    `json:"database,omitempty"`
+	Username         string    `json:"username,omitempty"`
+	Password         string    `json:"-"`
+	TableEngine      string    `json:"table-engine,omitempty"`
+	OrderBy          string    `json:"order-by,omitempty"`
+	RowGroupSize     int       `json:"row-group-size,omitempty"`
+	CreatedAt        time.Time `json:"created-at"`
+	UpdatedAt        time.Time `json:"updated-at"`
+}
+
+// the password is left out of a connection's json, so it's added back for the sealed copy
+type sealedConnection struct {
+	Connection
+	Password string `json:"password,omitempty"`
+}
+
+func (connection Connection) getConnectionInfo() ConnectionInfo {
+	return ConnectionInfo{
+		Name:             connection.Name,
+		Type:             connection.Type,
+		ConnectionString: connection.ConnectionString,
+		Hostname:         connection.Hostname,
+		Port:             connection.Port,
+		Database:         connection.Database,
+		Username:         connection.Username,
+		Password:         connection.Password,
+		TableEngine:      connection.TableEngine,
+		OrderBy:          connection.OrderBy,
+		RowGroupSize:     connection.RowGroupSize,
+	}
+}
+
+func (connection Connection) redacted() Connection {
+	connection.ConnectionString = redactConnectionString(connection.ConnectionString)
+	connection.Password = ""
+	return connection
+}
+
+// ***************
+// ** redaction **
+// ***************
+
+type Redactor struct {
+	// secrets are held by owners, like a stored connection or a running transfer, so they
+	// stop being redacted once nothing that uses them is left. a secret held by several
+	// owners is counted once for each
+
+	mu       sync.RWMutex
+	owners   map[string][]string
+	counts   map[string]int
+	replacer *strings.Replacer
+}
+
+func newRedactor() *Redactor {
+	return &Redactor{owners: make(map[string][]string), counts: make(map[string]int)}
+}
+
+var redactor = newRedactor()
+
+func (r *Redactor) set(owner string, secrets ...string) {
+	// replaces the secrets an owner holds. very short secrets would redact ordinary words all
+	// over the logs, so they're skipped. the replacer is only rebuilt if a secret starts or
+	// stops being redacted
+
+	kept := []string{}
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		if len(secret) >= minRedactedSecretLen && !seen[secret] {
+			seen[secret] = true
+			kept = append(kept, secret)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for _, secret := range kept {
+		r.counts[secret]++
+		if r.counts[secret] == 1 {
+			changed = true
+		}
+	}
+	for _, secret := range r.owners[owner] {
+		r.counts[secret]--
+		if r.counts[secret] == 0 {
+			delete(r.counts, secret)
+			changed = true
+		}
+	}
+
+	if len(kept) > 0 {
+		r.owners[owner] = kept
+	} else {
+		delete(r.owners, owner)
+	}
+
+	if !changed {
+		return
+	}
+
+	if len(r.counts) == 0 {
+		r.replacer = nil
+		return
+	}
+
+	redacted := []string{}
+	for secret := range r.counts {
+		redacted = append(redacted, secret)
+	}
+	r.replacer = newSecretReplacer(redacted)
+}
+
+func (r *Redactor) remove(owner string) {
+	r.set(owner)
+}
+
+func (r *Redactor) redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+func newSecretReplacer(secrets []string) *strings.Replacer {
+	// longer secrets go first, so a secret that contains another is replaced whole
+
+	sorted := append([]string{}, secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	oldNew := []string{}
+	for _, secret := range sorted {
+		oldNew = append(oldNew, secret, redactedSecret)
+	}
+
+	return strings.NewReplacer(oldNew...)
+}
+
+type redactingWriter struct {
+	writer io.Writer
+}
+
+func (writer redactingWriter) Write(p []byte) (n int, err error) {
+	_, err = writer.writer.Write([]byte(redactor.redact(string(p))))
+	return len(p), err
+}
+
+func getConnectionStringSecrets(connectionString string) (secrets []string) {
+	// finds the passwords in url connection strings, key value connection strings like sql
+	// server's and postgresql's, and dsns like mysql's and snowflake's. url passwords are
+	// returned as written and decoded, since either can end up in a log
+
+	addSecret := func(raw string) {
+		if raw == "" {
+			return
+		}
+		secrets = append(secrets, raw)
+		decoded, err := url.QueryUnescape(raw)
+		if err == nil && decoded != raw {
+			secrets = append(secrets, decoded)
+		}
+	}
+
+	parsedUrl, err := url.Parse(connectionString)
+	if err == nil && parsedUrl.Scheme != "" && parsedUrl.Opaque == "" {
+		if password, ok := parsedUrl.User.Password(); ok {
+			addSecret(password)
+			// the raw password is between the username and the host
+			rawUserinfo := strings.TrimPrefix(connectionString, parsedUrl.Scheme+"://")
+			if at := strings.LastIndex(rawUserinfo, "@"+parsedUrl.Host); at >= 0 {
+				if colon := strings.Index(rawUserinfo[:at], ":"); colon >= 0 {
+					addSecret(rawUserinfo[colon+1 : at])
+				}
+			}
+		}
+		for name, values := range parsedUrl.Query() {
+			if isSecretSetting(name) {
+				for _, value := range values {
+					addSecret(value)
+				}
+			}
+		}
+	} else if matches := dsnPasswordRegex.FindStringSubmatch(connectionString); matches != nil {
+		addSecret(matches[1])
+	}
+
+	// sql server's settings are separated by semicolons, and postgresql's by spaces
+	if strings.Contains(connectionString, ";") {
+		for _, matches := range secretSettingRegex.FindAllStringSubmatch(connectionString, -1) {
+			addSecret(strings.TrimSpace(matches[1]))
+		}
+	} else {
+		for _, matches := range spacedSettingRegex.FindAllStringSubmatch(connectionString, -1) {
+			addSecret(strings.Trim(matches[1], "'"))
+		}
+	}
+
+	return secrets
+}
+
+func isSecretSetting(name string) bool {
+	name = strings.ToLower(name)
+	return name == "pwd" || strings.Contains(name, "password") || strings.Contains(name, "secret") || strings.Contains(name, "token")
+}
+
+func getConnectionInfoSecrets(connectionInfo ConnectionInfo) (secrets []string) {
+	return append(getConnectionStringSecrets(connectionInfo.ConnectionString), connectionInfo.Password)
+}
+
+func getUrlParseReason(err error) error {
+	// url parse errors quote the url, password and all, so only the reason is kept
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		return urlError.Err
+	}
+	return err
+}
+
+func redactConnectionString(connectionString string) string {
+
+	secrets := getConnectionStringSecrets(connectionString)
+	if len(secrets) == 0 {
+		return connectionString
+	}
+
+	return newSecretReplacer(secrets).Replace(connectionString)
+}
+
+func redactTransferSecrets(transfer Transfer) {
+	// transfers can also be given connection strings directly, whose passwords are redacted
+	// until the transfer stops
+	secrets := getConnectionInfoSecrets(transfer.SourceConnectionInfo)
+	secrets = append(secrets, getConnectionInfoSecrets(transfer.TargetConnectionInfo)...)
+	secrets = append(secrets, getConnectionStringSecrets(transfer.StagingUrl)...)
+	redactor.set("transfers/"+transfer.Id, secrets...)
+}
+
+// ****************
+// ** encryption **
+// ****************
+
+func loadConnectionKey() (key []byte, err error) {
+	// the key is 32 random bytes, base64 encoded, like the output of openssl rand -base64 32
+
+	encodedKey := os.Getenv(connectionKeyEnv)
+	if encodedKey == "" {
+		return nil, nil
+	}
+
+	key, err = base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %v, it must be base64 encoded :: %v", connectionKeyEnv, err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%v must be 32 bytes, it is %v", connectionKeyEnv, len(key))
+	}
+
+	return key, nil
+}
+
+func seal(key, plaintext, additionalData []byte) (sealed []byte, err error) {
+	// encrypts with aes-gcm, and puts the nonce in front of the ciphertext
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher :: %v", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher :: %v", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("error creating nonce :: %v", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func unseal(key, sealed, additionalData []byte) (plaintext []byte, err error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher :: %v", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher :: %v", err)
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+
+	plaintext, err = gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting :: %v", err)
+	}
+
+	return plaintext, nil
+}
+
+func sealConnection(key []byte, connection Connection) (sealed []byte, err error) {
+	// the connection's name is authenticated with it, so a sealed connection can't be
+	// moved to another name in the store
+
+	plaintext, err := json.Marshal(sealedConnection{Connection: connection, Password: connection.Password})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling connection :: %v", err)
+	}
+
+	return seal(key, plaintext, []byte(connection.Name))
+}
+
+func openConnection(key []byte, name string, sealed []byte) (connection Connection, err error) {
+
+	plaintext, err := unseal(key, sealed, []byte(name))
+	if err != nil {
+		return connection, fmt.Errorf("error opening connection :: %v", err)
+	}
+
+	var unsealed sealedConnection
+	err = json.Unmarshal(plaintext, &unsealed)
+	if err != nil {
+		return connection, fmt.Errorf("error unmarshalling connection :: %v", err)
+	}
+
+	connection = unsealed.Connection
+	connection.Password = unsealed.Password
+
+	return connection, nil
+}
+
+// ********************
+// ** connection map **
+// ********************
+
+var connectionMap = NewSafeConnectionMap()
+
+type SafeConnectionMap struct {
+	mu    sync.Mutex
+	m     map[string]Connection
+	store TransferStore
+	key   []byte
+}
+
+func NewSafeConnectionMap() *SafeConnectionMap {
+	return &SafeConnectionMap{
+		m:     make(map[string]Connection),
+		store: newMemoryTransferStore(),
+	}
+}
+
+func (sm *SafeConnectionMap) LoadStore(store TransferStore, key []byte) (err error) {
+	// loads connections from a store. connections that can't be decrypted, because the key
+	// changed, are skipped and can be created again
+
+	sealedConnections, err := store.getConnections()
+	if err != nil {
+		return fmt.Errorf("error getting connections from store :: %v", err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.store = store
+	sm.key = key
+
+	if key == nil {
+		if len(sealedConnections) > 0 {
+			warningLog.Printf("%v stored connections can't be used until %v is set", len(sealedConnections), connectionKeyEnv)
+		}
+		return nil
+	}
+
+	for name, sealed := range sealedConnections {
+		connection, err := openConnection(key, name, sealed)
+		if err != nil {
+			warningLog.Printf("skipping stored connection %v, it may have been stored with a different %v :: %v", name, connectionKeyEnv, err)
+			continue
+		}
+		redactor.set("connections/"+name, getConnectionInfoSecrets(connection.getConnectionInfo())...)
+		sm.m[name] = connection
+	}
+
+	infoLog.Printf("loaded %v connections from store", len(sm.m))
+
+	return nil
+}
+
+func (sm *SafeConnectionMap) Get(name string) (Connection, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	connection, ok := sm.m[name]
+	return connection, ok
+}
+
+func (sm *SafeConnectionMap) GetRedactedMap() map[string]Connection {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	connections := make(map[string]Connection, len(sm.m))
+	for name, connection := range sm.m {
+		connections[name] = connection.redacted()
+	}
+	return connections
+}
+
+func (sm *SafeConnectionMap) Create(connection Connection) (err error) {
+	// stores a connection only if no connection has its name, which the store checks too, in
+	// case another sqlpipe shares it
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.key == nil {
+		return fmt.Errorf("%v must be set to store connections", connectionKeyEnv)
+	}
+
+	if _, exists := sm.m[connection.Name]; exists {
+		return errConnectionExists
+	}
+
+	sealed, err := sealConnection(sm.key, connection)
+	if err != nil {
+		return err
+	}
+
+	created, err := sm.store.createConnection(connection.Name, sealed)
+	if err != nil {
+		return fmt.Errorf("error creating connection %v in store :: %v", connection.Name, err)
+	}
+	if !created {
+		return errConnectionExists
+	}
+
+	redactor.set("connections/"+connection.Name, getConnectionInfoSecrets(connection.getConnectionInfo())...)
+	sm.m[connection.Name] = connection
+
+	return nil
+}
+
+func (sm *SafeConnectionMap) Set(connection Connection) (err error) {
+	// the connection's secrets replace the ones it had, so an old password stops being redacted
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.key == nil {
+		return fmt.Errorf("%v must be set to store connections", connectionKeyEnv)
+	}
+
+	sealed, err := sealConnection(sm.key, connection)
+	if err != nil {
+		return err
+	}
+
+	err = sm.store.saveConnection(connection.Name, sealed)
+	if err != nil {
+		return fmt.Errorf("error saving connection %v to store :: %v", connection.Name, err)
+	}
+
+	redactor.set("connections/"+connection.Name, getConnectionInfoSecrets(connection.getConnectionInfo())...)
+	sm.m[connection.Name] = connection
+
+	return nil
+}
+
+func (sm *SafeConnectionMap) Delete(name string) (err error) {
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	err = sm.store.deleteConnection(name)
+	if err != nil {
+		return fmt.Errorf("error deleting connection %v from store :: %v", name, err)
+	}
+
+	redactor.remove("connections/" + name)
+	delete(sm.m, name)
+
+	return nil
+}
+
+func (sm *SafeConnectionMap) CanStore() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.key != nil
+}
+
+func loadCliConnections() (err error) {
+	// cli transfers don't otherwise use the store, so it's opened to read connections and closed
+
+	key, err := loadConnectionKey()
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("%v must be set to use stored connections", connectionKeyEnv)
+	}
+
+	store, err := newTransferStore(storeType, storePath, storeConnectionString)
+	if err != nil {
+		return fmt.Errorf("error opening transfer store :: %v", err)
+	}
+	defer store.close()
+
+	return connectionMap.LoadStore(store, key)
+}
+
+// ****************
+// ** validation **
+// ****************
+
+func validateConnection(v validator, connection Connection) {
+
+	v.check(connection.Name != "", "name", "must be provided")
+	v.check(nameRegex.MatchString(connection.Name), "name", "must only contain letters, numbers, underscores, periods, and dashes")
+	v.check(connection.Type != "", "type", "must be provided")
+	v.check(permittedValue(connection.Type, permittedTransferSources...) || permittedValue(connection.Type, permittedTransferTargets...),
+		"type", fmt.Sprintf("must be one of %v", permittedTransferTargets))
+	v.check(connection.ConnectionString != "", "connection-string", "must be provided")
+	v.check(connection.Port >= 0, "port", "must not be negative")
+	v.check(connection.RowGroupSize >= 0, "row-group-size", "must not be negative")
+}
+
+func checkConnection(connection Connection) (err error) {
+	// connects the way a transfer would, which opens a pool and pings it. the connection's
+	// secrets are redacted from its errors, and from anything logged while it's checked
+
+	owner := "connection-checks/" + uuid.NewString()
+	redactor.set(owner, getConnectionInfoSecrets(connection.getConnectionInfo())...)
+	defer redactor.remove(owner)
+
+	system, err := newSystem(connection.getConnectionInfo())
+	if err != nil {
+		return errors.New(redactor.redact(err.Error()))
+	}
+
+	return system.closeConnectionPool(false)
+}
+
+func getStoredConnectionInfo(v validator, side, name string, connectionInfo ConnectionInfo) ConnectionInfo {
+	// fills in a transfer's source or target from a stored connection. the connection's own
+	// fields can't be given too, but the table settings of the transfer take precedence
+
+	if name == "" {
+		return connectionInfo
+	}
+
+	connection, ok := connectionMap.Get(name)
+	if !ok {
+		v.addError(side+"-connection", fmt.Sprintf("no stored connection is named %v", name))
+		return connectionInfo
+	}
+
+	message := fmt.Sprintf("must not be provided if %v-connection is provided", side)
+	v.check(connectionInfo.Type == "", side+"-type", message)
+	v.check(connectionInfo.ConnectionString == "", side+"-connection-string", message)
+	v.check(connectionInfo.Hostname == "", side+"-hostname", message)
+	v.check(connectionInfo.Port == 0, side+"-port", message)
+	v.check(connectionInfo.Database == "", side+"-database", message)
+	v.check(connectionInfo.Username == "", side+"-username", message)
+	v.check(connectionInfo.Password == "", side+"-password", message)
+
+	storedConnectionInfo := connection.getConnectionInfo()
+	if connectionInfo.Name != "" {
+		storedConnectionInfo.Name = connectionInfo.Name
+	}
+	if connectionInfo.TableEngine != "" {
+		storedConnectionInfo.TableEngine = connectionInfo.TableEngine
+	}
+	if connectionInfo.OrderBy != "" {
+		storedConnectionInfo.OrderBy = connectionInfo.OrderBy
+	}
+	if connectionInfo.RowGroupSize != 0 {
+		storedConnectionInfo.RowGroupSize = connectionInfo.RowGroupSize
+	}
+
+	return storedConnectionInfo
+}
+
+// **************
+// ** handlers **
+// **************
+
+func createConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name             string `json:"name"`
+		Type             string `json:"type"`
+		ConnectionString string `json:"connection-string"`
+		Hostname         string `json:"hostname"`
+		Port             int    `json:"port"`
+		Database         string `json:"database"`
+		Username         string `json:"username"`
+		Password         string `json:"password"`
+		TableEngine      string `json:"table-engine"`
+		OrderBy          string `json:"order-by"`
+		RowGroupSize     int    `json:"row-group-size"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		clientErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if !connectionMap.CanStore() {
+		clientErrorResponse(w, r, http.StatusServiceUnavailable, fmt.Errorf("%v must be set to store connections", connectionKeyEnv))
+		return
+	}
+
+	connection := Connection{
+		Name:             input.Name,
+		Type:             input.Type,
+		ConnectionString: input.ConnectionString,
+		Hostname:         input.Hostname,
+		Port:             input.Port,
+		Database:         input.Database,
+		Username:         input.Username,
+		Password:         input.Password,
+		TableEngine:      input.TableEngine,
+		OrderBy:          input.OrderBy,
+		RowGroupSize:     input.RowGroupSize,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+
+	v := newValidator()
+	validateConnection(v, connection)
+
+	_, exists := connectionMap.Get(connection.Name)
+	v.check(!exists, "name", errConnectionExists.Error())
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	err = checkConnection(connection)
+	if err != nil {
+		failedValidationResponse(w, r, map[string]string{"connection-string": err.Error()})
+		return
+	}
+
+	// another request may have created the connection while this one was checked
+	err = connectionMap.Create(connection)
+	if errors.Is(err, errConnectionExists) {
+		failedValidationResponse(w, r, map[string]string{"name": err.Error()})
+		return
+	}
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v created connection %v", r.RemoteAddr, connection.Name)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/connections/show/%s", connection.Name))
+
+	err = writeJSON(w, http.StatusCreated, envelope{"connection": connection.redacted()}, headers)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func showConnectionHandler(w http.ResponseWriter, r *http.Request) {
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	connection, ok := connectionMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, envelope{"connection": connection.redacted()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func listConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	err := writeJSON(w, http.StatusOK, envelope{"connections": connectionMap.GetRedactedMap()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func updateConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	// fields left out of the body keep their stored values, so a password can be changed alone
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	connection, ok := connectionMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Type             *string `json:"type"`
+		ConnectionString *string `json:"connection-string"`
+		Hostname         *string `json:"hostname"`
+		Port             *int    `json:"port"`
+		Database         *string `json:"database"`
+		Username         *string `json:"username"`
+		Password         *string `json:"password"`
+		TableEngine      *string `json:"table-engine"`
+		OrderBy          *string `json:"order-by"`
+		RowGroupSize     *int    `json:"row-group-size"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		clientErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if input.Type != nil {
+		connection.Type = *input.Type
+	}
+	if input.ConnectionString != nil {
+		connection.ConnectionString = *input.ConnectionString
+	}
+	if input.Hostname != nil {
+		connection.Hostname = *input.Hostname
+	}
+	if input.Port != nil {
+		connection.Port = *input.Port
+	}
+	if input.Database != nil {
+		connection.Database = *input.Database
+	}
+	if input.Username != nil {
+		connection.Username = *input.Username
+	}
+	if input.Password != nil {
+		connection.Password = *input.Password
+	}
+	if input.TableEngine != nil {
+		connection.TableEngine = *input.TableEngine
+	}
+	if input.OrderBy != nil {
+		connection.OrderBy = *input.OrderBy
+	}
+	if input.RowGroupSize != nil {
+		connection.RowGroupSize = *input.RowGroupSize
+	}
+	connection.UpdatedAt = time.Now()
+
+	v := newValidator()
+	validateConnection(v, connection)
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	err = checkConnection(connection)
+	if err != nil {
+		failedValidationResponse(w, r, map[string]string{"connection-string": err.Error()})
+		return
+	}
+
+	err = connectionMap.Set(connection)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v updated connection %v", r.RemoteAddr, connection.Name)
+
+	err = writeJSON(w, http.StatusOK, envelope{"connection": connection.redacted()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func deleteConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	// transfers already using the connection keep their own copy of it
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	_, ok := connectionMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	err := connectionMap.Delete(name)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v deleted connection %v", r.RemoteAddr, name)
+
+	err = writeJSON(w, http.StatusOK, envelope{"message": fmt.Sprintf("connection %v deleted", name)}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
@@ -0,0 +1,368 @@
+//go:build cgo
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+
+	// go-duckdb links a static duckdb library kept in these packages, which are only vendored
+	// when they're imported
+	_ "github.com/marcboeker/go-duckdb/deps/darwin_amd64"
+	_ "github.com/marcboeker/go-duckdb/deps/darwin_arm64"
+	_ "github.com/marcboeker/go-duckdb/deps/linux_amd64"
+	_ "github.com/marcboeker/go-duckdb/deps/linux_arm64"
+)
+
+// duckdb is linked into the binary through go-duckdb, which needs cgo. sqlpipe wraps its driver
+// to share one database between every pool on the same file, and to read each column back as a
+// value the formatters understand. queries are described first to get their column types, and
+// columns the driver can't scan, or scans into its own types, are selected as text instead
+
+const duckdbAvailable = true
+
+func init() {
+	sql.Register(DriverDuckDB, duckdbDriver{})
+}
+
+// duckdb locks a database file to the database that opened it, so pools on the same file share
+// one, which is closed along with the last pool using it
+var (
+	duckdbDatabases   = map[string]*duckdbDatabase{}
+	duckdbDatabasesMu sync.Mutex
+)
+
+type duckdbDatabase struct {
+	connector driver.Connector
+	pools     int
+}
+
+type duckdbDriver struct{}
+
+func (d duckdbDriver) Open(name string) (driver.Conn, error) {
+	// database/sql opens connections through OpenConnector, so the database is shared
+	return nil, errors.New("duckdb connections must be opened with sql.Open")
+}
+
+func (d duckdbDriver) OpenConnector(name string) (driver.Connector, error) {
+	duckdbDatabasesMu.Lock()
+	defer duckdbDatabasesMu.Unlock()
+
+	database, ok := duckdbDatabases[name]
+	if !ok {
+		connector, err := duckdb.NewConnector(name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error opening duckdb database :: %v", err)
+		}
+		database = &duckdbDatabase{connector: connector}
+		duckdbDatabases[name] = database
+	}
+	database.pools++
+
+	return &duckdbConnector{name: name, database: database}, nil
+}
+
+type duckdbConnector struct {
+	name     string
+	database *duckdbDatabase
+}
+
+func (connector *duckdbConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := connector.database.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &duckdbConn{Conn: conn}, nil
+}
+
+func (connector *duckdbConnector) Driver() driver.Driver {
+	return duckdbDriver{}
+}
+
+func (connector *duckdbConnector) Close() error {
+	duckdbDatabasesMu.Lock()
+	defer duckdbDatabasesMu.Unlock()
+
+	connector.database.pools--
+	if connector.database.pools > 0 {
+		return nil
+	}
+
+	delete(duckdbDatabases, connector.name)
+	return connector.database.connector.(io.Closer).Close()
+}
+
+type duckdbConn struct {
+	driver.Conn
+}
+
+func (conn *duckdbConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return conn.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (conn *duckdbConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return conn.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (conn *duckdbConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	queryer := conn.Conn.(driver.QueryerContext)
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	columnNames, columnTypes, err := conn.describe(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	selects := make([]string, len(columnNames))
+	kinds := make([]string, len(columnNames))
+	wrap := false
+	for i := range columnNames {
+		escapedColumn := fmt.Sprintf(`"%v"`, strings.ReplaceAll(columnNames[i], `"`, `""`))
+
+		var selectExpression string
+		selectExpression, kinds[i] = getDuckdbSelect(escapedColumn, columnTypes[i])
+		if selectExpression != escapedColumn {
+			wrap = true
+		}
+		selects[i] = fmt.Sprintf("%v as %v", selectExpression, escapedColumn)
+	}
+
+	if wrap {
+		query = fmt.Sprintf("select %v from (%v) as sqlpipe_query", strings.Join(selects, ", "), query)
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows.Columns()) != len(columnNames) {
+		rows.Close()
+		return nil, fmt.Errorf("duckdb query returned %v columns, but was described with %v", len(rows.Columns()), len(columnNames))
+	}
+
+	return &duckdbRows{Rows: rows, columnTypes: columnTypes, kinds: kinds}, nil
+}
+
+func (conn *duckdbConn) describe(ctx context.Context, query string, args []driver.NamedValue) (columnNames, columnTypes []string, err error) {
+
+	rows, err := conn.Conn.(driver.QueryerContext).QueryContext(ctx, "describe "+query, args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error describing duckdb query :: %v", err)
+	}
+	defer rows.Close()
+
+	record := make([]driver.Value, len(rows.Columns()))
+	if len(record) < 2 {
+		return nil, nil, fmt.Errorf("unexpected duckdb query description columns %v", rows.Columns())
+	}
+
+	for {
+		err = rows.Next(record)
+		if err != nil {
+			break
+		}
+
+		columnName, nameOk := record[0].(string)
+		columnType, typeOk := record[1].(string)
+		if !nameOk || !typeOk {
+			return nil, nil, fmt.Errorf("unexpected duckdb query description %v", record)
+		}
+
+		columnNames = append(columnNames, columnName)
+		columnTypes = append(columnTypes, columnType)
+	}
+
+	if err != io.EOF {
+		return nil, nil, fmt.Errorf("error reading duckdb query description :: %v", err)
+	}
+
+	return columnNames, columnTypes, nil
+}
+
+func getDuckdbSelect(escapedColumn, columnType string) (selectExpression, kind string) {
+	// picks how a column is selected, and how the driver's value is read back
+
+	baseType := getDuckdbBaseType(columnType)
+
+	switch {
+	case strings.HasSuffix(columnType, "]") || baseType == "STRUCT" || baseType == "MAP":
+		// duckdb's to_json lives in the json extension, which isn't linked in, so nested
+		// values are scanned and marshalled here
+		return escapedColumn, "json"
+	}
+
+	switch baseType {
+	case "BOOLEAN", "VARCHAR", "JSON", "ENUM", "BLOB", "DATE", "TIMESTAMP", "TIMESTAMP_S", "TIMESTAMP_MS", "TIMESTAMP_NS", "DATETIME":
+		return escapedColumn, "value"
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "UTINYINT", "USMALLINT", "UINTEGER":
+		return escapedColumn, "int"
+	case "FLOAT", "DOUBLE":
+		return escapedColumn, "float"
+	case "TIMESTAMP WITH TIME ZONE", "TIMESTAMPTZ":
+		// the driver can't scan timestamps with a time zone
+		return fmt.Sprintf("epoch_us(%v)", escapedColumn), "timestamptz"
+	default:
+		// decimals, uuids, and the like are scanned into the driver's own types, and times
+		// into a date in 1970, so they're read as text
+		return fmt.Sprintf("cast(%v as varchar)", escapedColumn), "text"
+	}
+}
+
+type duckdbRows struct {
+	driver.Rows
+	columnTypes []string
+	kinds       []string
+}
+
+func (rows *duckdbRows) ColumnTypeDatabaseTypeName(index int) string {
+	return rows.columnTypes[index]
+}
+
+func (rows *duckdbRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	columnType := rows.columnTypes[index]
+
+	i := strings.Index(columnType, "(")
+	if getDuckdbBaseType(columnType) != "DECIMAL" || i < 0 {
+		return 0, 0, false
+	}
+
+	_, err := fmt.Sscanf(columnType[i:], "(%d,%d)", &precision, &scale)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return precision, scale, true
+}
+
+func (rows *duckdbRows) Next(dest []driver.Value) error {
+
+	err := rows.Rows.Next(dest)
+	if err != nil {
+		return err
+	}
+
+	for i := range dest {
+		if dest[i] == nil {
+			continue
+		}
+
+		dest[i], err = convertDuckdbValue(dest[i], rows.kinds[i])
+		if err != nil {
+			return fmt.Errorf("error reading column %v :: %v", rows.Columns()[i], err)
+		}
+	}
+
+	return nil
+}
+
+func convertDuckdbValue(value driver.Value, kind string) (converted driver.Value, err error) {
+	switch kind {
+	case "int":
+		switch v := value.(type) {
+		case int8:
+			return int64(v), nil
+		case int16:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case uint8:
+			return int64(v), nil
+		case uint16:
+			return int64(v), nil
+		case uint32:
+			return int64(v), nil
+		}
+	case "float":
+		switch v := value.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case "timestamptz":
+		if micros, ok := value.(int64); ok {
+			return time.UnixMicro(micros).UTC(), nil
+		}
+	case "json":
+		marshalled, err := json.Marshal(getDuckdbJsonValue(value))
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling nested value to json :: %v", err)
+		}
+		return string(marshalled), nil
+	default:
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %T value for a %v column", value, kind)
+}
+
+func getDuckdbJsonValue(value any) any {
+	// makes the driver's nested values marshallable, since maps can have any type of key, and
+	// decimals are scanned into the driver's own type
+
+	switch v := value.(type) {
+	case []any:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = getDuckdbJsonValue(v[i])
+		}
+		return values
+	case map[string]any:
+		values := make(map[string]any, len(v))
+		for key := range v {
+			values[key] = getDuckdbJsonValue(v[key])
+		}
+		return values
+	case duckdb.Map:
+		values := make(map[string]any, len(v))
+		for key := range v {
+			values[fmt.Sprint(key)] = getDuckdbJsonValue(v[key])
+		}
+		return values
+	case duckdb.Decimal:
+		return json.Number(getDuckdbDecimalString(v))
+	case *big.Int:
+		return json.Number(v.String())
+	default:
+		return v
+	}
+}
+
+func getDuckdbDecimalString(decimal duckdb.Decimal) string {
+	// places the decimal point in the decimal's unscaled value
+
+	digits := new(big.Int).Abs(decimal.Value).String()
+
+	scale := int(decimal.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if decimal.Value.Sign() < 0 {
+		sign = "-"
+	}
+
+	if scale == 0 {
+		return sign + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
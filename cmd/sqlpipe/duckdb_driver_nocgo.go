@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package main
+
+// the duckdb driver needs cgo, so builds without it can't move data to or from duckdb
+
+const duckdbAvailable = false
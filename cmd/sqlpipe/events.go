@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var transferEvents = newEventBroker()
+
+type TransferEvent struct {
+	Name       string
+	TransferId string
+	Data       any
+}
+
+type LogLine struct {
+	TransferId string    `json:"transfer-id"`
+	Level      string    `json:"level"`
+	Message    string    `json:"message"`
+	LoggedAt   time.Time `json:"logged-at"`
+}
+
+type ProgressUpdate struct {
+	TransferId string                 `json:"transfer-id"`
+	Progress   transferProgressValues `json:"progress"`
+}
+
+type EventBroker struct {
+	// fans transfer events out to the clients streaming them. clients that fall behind miss
+	// events rather than holding up the transfers that publish them
+
+	mu          sync.Mutex
+	subscribers map[chan TransferEvent]bool
+}
+
+func newEventBroker() *EventBroker {
+	return &EventBroker{
+		subscribers: make(map[chan TransferEvent]bool),
+	}
+}
+
+func (broker *EventBroker) subscribe() chan TransferEvent {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	events := make(chan TransferEvent, 100)
+	broker.subscribers[events] = true
+	return events
+}
+
+func (broker *EventBroker) unsubscribe(events chan TransferEvent) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	delete(broker.subscribers, events)
+}
+
+func (broker *EventBroker) publish(event TransferEvent) {
+	// this is called while logging, so it must not log
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for events := range broker.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+var transferIdRegex = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// matches the level prefix, date and time the loggers put at the start of each line
+var logPrefixRegex = regexp.MustCompile(`^[A-Z]+\t\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+type transferLogWriter struct {
+	level string
+}
+
+func (writer transferLogWriter) Write(p []byte) (n int, err error) {
+	// log lines that mention a transfer are published to that transfer's stream
+
+	line := strings.TrimRight(string(p), "\n")
+
+	transferId := transferIdRegex.FindString(line)
+	if transferId == "" {
+		return len(p), nil
+	}
+
+	transferEvents.publish(TransferEvent{
+		Name:       "log",
+		TransferId: transferId,
+		Data: LogLine{
+			TransferId: transferId,
+			Level:      writer.level,
+			Message:    logPrefixRegex.ReplaceAllString(line, ""),
+			LoggedAt:   time.Now(),
+		},
+	})
+
+	return len(p), nil
+}
+
+func streamTransferHandler(w http.ResponseWriter, r *http.Request) {
+	// streams one transfer's status changes, progress and log lines as server-sent events.
+	// a schema transfer's stream includes its children. the stream ends when the transfer stops
+
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	_, ok := transferMap.Get(id)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	streamTransferEvents(w, r, id)
+}
+
+func streamTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	// streams status changes, progress and log lines for every transfer as server-sent events
+	streamTransferEvents(w, r, "")
+}
+
+func streamTransferEvents(w http.ResponseWriter, r *http.Request, id string) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		serverErrorResponse(w, r, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	// the server's write timeout would otherwise cut streams off
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("error clearing write deadline :: %v", err))
+		return
+	}
+
+	events := transferEvents.subscribe()
+	defer transferEvents.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	matches := func(transferId string) bool {
+		if id == "" || transferId == id {
+			return true
+		}
+		eventTransfer, ok := transferMap.Get(transferId)
+		return ok && eventTransfer.ParentId == id
+	}
+
+	if id != "" {
+		// read after subscribing, so a status change can't slip in between the two
+		transfer, _ := transferMap.Get(id)
+		err = writeEvent(w, "status", StatusChange{TransferId: id, Status: transfer.Status, Error: transfer.Error, ChangedAt: time.Now()})
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+		if isStopped(transfer.Status) {
+			return
+		}
+	}
+
+	progressTicker := time.NewTicker(time.Second)
+	defer progressTicker.Stop()
+
+	lastProgress := map[string]transferProgressValues{}
+	ticks := 0
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case event := <-events:
+			if !matches(event.TransferId) {
+				continue
+			}
+
+			err = writeEvent(w, event.Name, event.Data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+			if statusChange, ok := event.Data.(StatusChange); ok && event.TransferId == id && isStopped(statusChange.Status) {
+				return
+			}
+
+		case <-progressTicker.C:
+			ticks++
+
+			// progress changes too often to publish, so it's checked once a second and sent if it moved
+			for _, eventTransfer := range transferMap.GetAll() {
+				transferId := eventTransfer.Id
+				if eventTransfer.Status != StatusRunning || eventTransfer.Progress == nil || !matches(transferId) {
+					continue
+				}
+
+				progress := eventTransfer.Progress.snapshot()
+
+				counts := progress
+				counts.RowsPerSecond, counts.PercentComplete, counts.EstimatedSecondsRemaining = 0, 0, 0
+				if lastProgress[transferId] == counts {
+					continue
+				}
+				lastProgress[transferId] = counts
+
+				err = writeEvent(w, "progress", ProgressUpdate{TransferId: transferId, Progress: progress})
+				if err != nil {
+					return
+				}
+				ticks = 0
+			}
+
+			// comments keep proxies from closing quiet streams
+			if ticks >= 15 {
+				_, err = fmt.Fprint(w, ": keep-alive\n\n")
+				if err != nil {
+					return
+				}
+				ticks = 0
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, name string, data any) (err error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshalling %v event :: %v", name, err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %v\ndata: %s\n\n", name, js)
+	return err
+}
+
+func isStopped(status string) bool {
+	return status == StatusComplete || status == StatusError || status == StatusCancelled
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// file systems keep each table in a file named after it, in the directory the connection
+// string names. their drivers only run the statements sqlpipe sends to read tables:
+//
+//	select * from <table>
+//	select count(*) from <table>
+//	describe <table>
+//	show tables
+
+const (
+	fileStatementSelect     = "select"
+	fileStatementCount      = "count"
+	fileStatementDescribe   = "describe"
+	fileStatementShowTables = "show tables"
+)
+
+var (
+	fileSelectRegex     = regexp.MustCompile(`(?is)^select\s+\*\s+from\s+(.+)$`)
+	fileCountRegex      = regexp.MustCompile(`(?is)^select\s+count\(\*\)\s+from\s+(.+)$`)
+	fileDescribeRegex   = regexp.MustCompile(`(?is)^describe\s+(.+)$`)
+	fileShowTablesRegex = regexp.MustCompile(`(?is)^show\s+tables$`)
+)
+
+func parseFileStatement(query string) (statement, table string, err error) {
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	if fileShowTablesRegex.MatchString(query) {
+		return fileStatementShowTables, "", nil
+	}
+
+	if matches := fileCountRegex.FindStringSubmatch(query); matches != nil {
+		return fileStatementCount, unescapeFileIdentifier(matches[1]), nil
+	}
+
+	if matches := fileSelectRegex.FindStringSubmatch(query); matches != nil {
+		return fileStatementSelect, unescapeFileIdentifier(matches[1]), nil
+	}
+
+	if matches := fileDescribeRegex.FindStringSubmatch(query); matches != nil {
+		return fileStatementDescribe, unescapeFileIdentifier(matches[1]), nil
+	}
+
+	return "", "", fmt.Errorf("file connections can't run %v", query)
+}
+
+func unescapeFileIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return identifier
+}
+
+func getTableFilePath(directory, table, extension string) (path string, err error) {
+	// tables are files in the directory, so their names can't leave it
+	if table == "" || table == "." || table == ".." || strings.ContainsAny(table, `/\`) {
+		return "", fmt.Errorf("%v is not a valid table name for a file", table)
+	}
+	return filepath.Join(directory, table+extension), nil
+}
+
+func getTableFileRows(directory, extension string) (rows driver.Rows, err error) {
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory :: %v", err)
+	}
+
+	tables := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), extension) {
+			continue
+		}
+		tables = append(tables, strings.TrimSuffix(entry.Name(), extension))
+	}
+
+	sort.Strings(tables)
+
+	staticRows := &fileStaticRows{columnNames: []string{"table_name"}}
+	for _, table := range tables {
+		staticRows.values = append(staticRows.values, []driver.Value{table})
+	}
+
+	return staticRows, nil
+}
+
+func replaceFile(path string, write func(sink *os.File) error) (err error) {
+	// writes next to path, then renames over it, so readers never see a partial file
+
+	tmpPath := path + ".sqlpipe-tmp"
+
+	sink, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("error creating file :: %v", err)
+	}
+
+	// some writers close their sink, and closing it again does nothing
+	err = write(sink)
+	sink.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("error renaming file :: %v", err)
+	}
+
+	return nil
+}
+
+type fileStaticRows struct {
+	columnNames []string
+	values      [][]driver.Value
+	next        int
+}
+
+func (rows *fileStaticRows) Columns() []string {
+	return rows.columnNames
+}
+
+func (rows *fileStaticRows) Next(dest []driver.Value) error {
+	if rows.next >= len(rows.values) {
+		return io.EOF
+	}
+	copy(dest, rows.values[rows.next])
+	rows.next++
+	return nil
+}
+
+func (rows *fileStaticRows) Close() error {
+	return nil
+}
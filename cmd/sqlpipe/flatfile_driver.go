@@ -0,0 +1,894 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// csv and json lines files are read through a small database/sql driver, so they can be a source
+// like any database. the connection string is a directory, optionally followed by settings, like
+// /data/partner?delimiter=%7C&header=false, and every <table>.csv or <table>.jsonl file in the
+// directory is a table. csv settings are delimiter, quote, header, and null, and both formats take
+// columns, a schema like "id int64,name nvarchar(100),amount decimal(10,2)" that replaces the one
+// sqlpipe infers from the first rows of a file. types are named after pipe types
+
+var flatFileInferenceRows = 1000
+
+// every pipe type can be named in a columns setting
+var flatFileColumnTypes = []string{
+	"nvarchar", "varchar", "ntext", "text", "int64", "int32", "int16", "float64", "float32", "decimal", "money",
+	"datetime", "datetimetz", "date", "time", "varbinary", "blob", "uuid", "bool", "json", "xml", "varbit",
+}
+
+func init() {
+	sql.Register(DriverCsv, flatFileDriver{format: TypeCsv})
+	sql.Register(DriverJsonl, flatFileDriver{format: TypeJsonl})
+}
+
+type flatFileOptions struct {
+	delimiter rune
+	quote     rune
+	header    bool
+	null      string
+	columns   []flatFileColumn
+}
+
+type flatFileColumn struct {
+	name       string
+	columnType string
+}
+
+func parseFlatFileConnectionString(format, connectionString string) (directory string, options flatFileOptions, err error) {
+
+	options = flatFileOptions{delimiter: ',', quote: '"', header: true}
+
+	directory, rawSettings, _ := strings.Cut(connectionString, "?")
+	if directory == "" {
+		return "", options, fmt.Errorf("%v connection string must start with a directory", format)
+	}
+
+	settings, err := url.ParseQuery(rawSettings)
+	if err != nil {
+		return "", options, fmt.Errorf("error parsing %v settings :: %v", format, err)
+	}
+
+	for key := range settings {
+		value := settings.Get(key)
+
+		switch {
+		case key == "columns":
+			options.columns, err = parseFlatFileColumns(value)
+			if err != nil {
+				return "", options, err
+			}
+			continue
+		case format != TypeCsv && (key == "delimiter" || key == "quote" || key == "header" || key == "null"):
+			return "", options, fmt.Errorf("%v setting is only used by csv files", key)
+		}
+
+		switch key {
+		case "delimiter":
+			if value == `\t` {
+				value = "\t"
+			}
+			options.delimiter, err = getFlatFileRune(key, value)
+		case "quote":
+			options.quote, err = getFlatFileRune(key, value)
+		case "header":
+			options.header, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("header setting must be true or false")
+			}
+		case "null":
+			options.null = value
+		default:
+			err = fmt.Errorf("%v is not a %v setting", key, format)
+		}
+		if err != nil {
+			return "", options, err
+		}
+	}
+
+	if options.delimiter == options.quote {
+		return "", options, errors.New("delimiter and quote settings must be different characters")
+	}
+
+	return directory, options, nil
+}
+
+func getFlatFileRune(key, value string) (character rune, err error) {
+	character, size := utf8.DecodeRuneInString(value)
+	if size == 0 || size != len(value) || character == utf8.RuneError || character == '\r' || character == '\n' {
+		return 0, fmt.Errorf("%v setting must be one character, other than a line break", key)
+	}
+	return character, nil
+}
+
+func parseFlatFileColumns(value string) (columns []flatFileColumn, err error) {
+	// splits on commas outside of parentheses, so decimal(10,2) stays one type
+
+	depth := 0
+	start := 0
+	definitions := []string{}
+
+	for i, character := range value {
+		switch character {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	definitions = append(definitions, value[start:])
+
+	for _, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+
+		i := strings.LastIndexAny(definition, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf(`columns setting entry %v must be a name and a type, like "id int64"`, definition)
+		}
+
+		column := flatFileColumn{
+			name:       strings.TrimSpace(definition[:i]),
+			columnType: strings.ToLower(strings.TrimSpace(definition[i+1:])),
+		}
+
+		if !permittedValue(getFlatFileBaseType(column.columnType), flatFileColumnTypes...) {
+			return nil, fmt.Errorf("column %v has type %v, columns setting types must be one of %v", column.name, column.columnType, flatFileColumnTypes)
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+func getFlatFileBaseType(columnType string) string {
+	// strips the length, or precision and scale, from types like nvarchar(100) or decimal(10,2)
+	if i := strings.Index(columnType, "("); i >= 0 {
+		columnType = columnType[:i]
+	}
+	return columnType
+}
+
+func getFlatFilePrecisionScale(columnType string) (precision, scale int64, ok bool) {
+
+	i := strings.Index(columnType, "(")
+	if getFlatFileBaseType(columnType) != "decimal" || i < 0 {
+		return 0, 0, false
+	}
+
+	_, err := fmt.Sscanf(strings.ReplaceAll(columnType[i:], " ", ""), "(%d,%d)", &precision, &scale)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return precision, scale, true
+}
+
+func getFlatFileLength(columnType string) (length int64, ok bool) {
+
+	i := strings.Index(columnType, "(")
+	if getFlatFileBaseType(columnType) == "decimal" || i < 0 {
+		return 0, false
+	}
+
+	_, err := fmt.Sscanf(strings.ReplaceAll(columnType[i:], " ", ""), "(%d)", &length)
+	if err != nil {
+		return 0, false
+	}
+
+	return length, true
+}
+
+type flatFileDriver struct {
+	format string
+}
+
+func (d flatFileDriver) Open(name string) (driver.Conn, error) {
+
+	directory, options, err := parseFlatFileConnectionString(d.format, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flatFileConn{format: d.format, directory: directory, options: options}, nil
+}
+
+type flatFileConn struct {
+	format    string
+	directory string
+	options   flatFileOptions
+}
+
+func (conn *flatFileConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("%v connections do not support prepared statements", conn.format)
+}
+
+func (conn *flatFileConn) Close() error {
+	return nil
+}
+
+func (conn *flatFileConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("%v connections do not support transactions", conn.format)
+}
+
+func (conn *flatFileConn) Ping(ctx context.Context) error {
+	info, err := os.Stat(conn.directory)
+	if err != nil {
+		return fmt.Errorf("error reading %v directory :: %v", conn.format, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v connection string %v is not a directory", conn.format, conn.directory)
+	}
+	return nil
+}
+
+func (conn *flatFileConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, fmt.Errorf("%v connections only read files, sqlpipe writes them without running statements", conn.format)
+}
+
+func (conn *flatFileConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	if len(args) > 0 {
+		return nil, fmt.Errorf("%v connections do not support query arguments", conn.format)
+	}
+
+	statement, table, err := parseFileStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := "." + conn.format
+
+	if statement == fileStatementShowTables {
+		return getTableFileRows(conn.directory, extension)
+	}
+
+	path, err := getTableFilePath(conn.directory, table, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	switch statement {
+	case fileStatementCount:
+		return conn.count(path)
+	case fileStatementDescribe:
+		return conn.describe(path)
+	default:
+		return conn.selectAll(path)
+	}
+}
+
+func (conn *flatFileConn) open(path string) (rows *flatFileRows, err error) {
+	// opens a file for reading records, past its header
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %v file :: %v", conn.format, err)
+	}
+
+	rows = &flatFileRows{
+		format:  conn.format,
+		file:    file,
+		options: conn.options,
+		reader: &flatFileReader{
+			reader:    bufio.NewReaderSize(file, 1024*1024),
+			delimiter: conn.options.delimiter,
+			quote:     conn.options.quote,
+		},
+	}
+
+	if conn.format == TypeCsv && conn.options.header {
+		rows.headers, _, err = rows.reader.readCsv()
+		if err != nil && !errors.Is(err, io.EOF) {
+			file.Close()
+			return nil, fmt.Errorf("error reading csv header :: %v", err)
+		}
+	}
+
+	return rows, nil
+}
+
+func (conn *flatFileConn) count(path string) (driver.Rows, error) {
+
+	rows, err := conn.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	count := int64(0)
+
+	for {
+		if conn.format == TypeCsv {
+			_, _, err = rows.reader.readCsv()
+		} else {
+			_, err = rows.reader.readLine()
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %v file :: %v", conn.format, err)
+		}
+		count++
+	}
+
+	return &fileStaticRows{
+		columnNames: []string{"count"},
+		values:      [][]driver.Value{{count}},
+	}, nil
+}
+
+func (conn *flatFileConn) getColumns(path string) (columns []flatFileColumn, err error) {
+
+	if len(conn.options.columns) > 0 {
+		return conn.options.columns, nil
+	}
+
+	rows, err := conn.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return rows.inferColumns()
+}
+
+func (conn *flatFileConn) describe(path string) (driver.Rows, error) {
+
+	columns, err := conn.getColumns(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &fileStaticRows{
+		columnNames: []string{"col_name", "col_type", "col_precision", "col_scale", "col_length", "col_is_primary"},
+	}
+
+	for _, column := range columns {
+		precision, scale, ok := getFlatFilePrecisionScale(column.columnType)
+		if !ok {
+			precision, scale = -1, -1
+		}
+		length, ok := getFlatFileLength(column.columnType)
+		if !ok {
+			length = -1
+		}
+		rows.values = append(rows.values, []driver.Value{column.name, column.columnType, precision, scale, length, false})
+	}
+
+	return rows, nil
+}
+
+func (conn *flatFileConn) selectAll(path string) (driver.Rows, error) {
+
+	columns, err := conn.getColumns(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows.columns = columns
+
+	return rows, nil
+}
+
+type flatFileRows struct {
+	format  string
+	file    *os.File
+	reader  *flatFileReader
+	options flatFileOptions
+	headers []string
+	columns []flatFileColumn
+	rows    int64
+}
+
+func (rows *flatFileRows) inferColumns() (columns []flatFileColumn, err error) {
+	// samples the first rows of the file, and gives each column the narrowest type all of its
+	// values fit. csv columns are named by the header, and json lines columns by the keys of
+	// every sampled object, in the order they're first seen
+
+	names := []string{}
+	guesses := map[string]*flatFileTypeGuess{}
+
+	addName := func(name string) {
+		if _, ok := guesses[name]; !ok {
+			names = append(names, name)
+			guesses[name] = newFlatFileTypeGuess()
+		}
+	}
+
+	if rows.format == TypeCsv {
+		for i, header := range rows.headers {
+			if header == "" {
+				header = fmt.Sprintf("column%v", i+1)
+			}
+			if _, ok := guesses[header]; ok {
+				return nil, fmt.Errorf("csv header has more than one column named %v", header)
+			}
+			addName(header)
+		}
+	}
+
+	for sampled := 0; sampled < flatFileInferenceRows; sampled++ {
+
+		if rows.format == TypeCsv {
+			fields, quoted, err := rows.reader.readCsv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading csv file :: %v", err)
+			}
+
+			// files without headers name their columns by position
+			for len(names) < len(fields) && (!rows.options.header || len(rows.headers) == 0) {
+				addName(fmt.Sprintf("column%v", len(names)+1))
+			}
+			if len(fields) != len(names) {
+				return nil, fmt.Errorf("csv row %v has %v fields, expected %v", sampled+1, len(fields), len(names))
+			}
+
+			for i := range fields {
+				if !quoted[i] && fields[i] == rows.options.null {
+					continue
+				}
+				guesses[names[i]].addText(fields[i])
+			}
+			continue
+		}
+
+		line, err := rows.reader.readLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading jsonl file :: %v", err)
+		}
+
+		keys, values, err := parseFlatFileJsonObject(line)
+		if err != nil {
+			return nil, fmt.Errorf("error reading jsonl row %v :: %v", sampled+1, err)
+		}
+
+		for _, key := range keys {
+			addName(key)
+			guesses[key].addJson(values[key])
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%v file has no columns, provide a header or a columns setting", rows.format)
+	}
+
+	for _, name := range names {
+		columns = append(columns, flatFileColumn{name: name, columnType: guesses[name].getType()})
+	}
+
+	return columns, nil
+}
+
+func (rows *flatFileRows) Columns() (columnNames []string) {
+	for _, column := range rows.columns {
+		columnNames = append(columnNames, column.name)
+	}
+	return columnNames
+}
+
+func (rows *flatFileRows) ColumnTypeDatabaseTypeName(index int) string {
+	return rows.columns[index].columnType
+}
+
+func (rows *flatFileRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return true, true
+}
+
+func (rows *flatFileRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	return getFlatFilePrecisionScale(rows.columns[index].columnType)
+}
+
+func (rows *flatFileRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	return getFlatFileLength(rows.columns[index].columnType)
+}
+
+func (rows *flatFileRows) Next(dest []driver.Value) (err error) {
+
+	rows.rows++
+
+	if rows.format == TypeCsv {
+		fields, quoted, err := rows.reader.readCsv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return err
+			}
+			return fmt.Errorf("error reading csv file :: %v", err)
+		}
+
+		if len(fields) != len(rows.columns) {
+			return fmt.Errorf("csv row %v has %v fields, expected %v", rows.rows, len(fields), len(rows.columns))
+		}
+
+		for i := range fields {
+			// quoted fields are never null, so an empty string can be told apart from a null one
+			if !quoted[i] && fields[i] == rows.options.null {
+				dest[i] = nil
+				continue
+			}
+
+			dest[i], err = parseFlatFileValue(fields[i], rows.columns[i].columnType)
+			if err != nil {
+				return fmt.Errorf("error reading column %v in csv row %v :: %v", rows.columns[i].name, rows.rows, err)
+			}
+		}
+
+		return nil
+	}
+
+	line, err := rows.reader.readLine()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return err
+		}
+		return fmt.Errorf("error reading jsonl file :: %v", err)
+	}
+
+	_, values, err := parseFlatFileJsonObject(line)
+	if err != nil {
+		return fmt.Errorf("error reading jsonl row %v :: %v", rows.rows, err)
+	}
+
+	for i, column := range rows.columns {
+		dest[i], err = parseFlatFileJsonValue(values[column.name], column.columnType)
+		if err != nil {
+			return fmt.Errorf("error reading column %v in jsonl row %v :: %v", column.name, rows.rows, err)
+		}
+	}
+
+	return nil
+}
+
+func (rows *flatFileRows) Close() error {
+	return rows.file.Close()
+}
+
+var (
+	flatFileDatetimetzLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00"}
+	flatFileDatetimeLayouts   = []string{"2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}
+	flatFileDateLayout        = "2006-01-02"
+	flatFileTimeLayout        = "15:04:05.999999999"
+)
+
+func parseFlatFileTime(value string, layouts ...string) (parsed time.Time, ok bool) {
+	for _, layout := range layouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed, true
+		}
+	}
+	return parsed, false
+}
+
+func parseFlatFileValue(value, columnType string) (parsed driver.Value, err error) {
+
+	switch getFlatFileBaseType(columnType) {
+	case "int64", "int32", "int16":
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case "float64", "float32":
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case "bool":
+		return strconv.ParseBool(strings.TrimSpace(value))
+	case "datetimetz", "datetime":
+		layouts := append(append([]string{}, flatFileDatetimetzLayouts...), flatFileDatetimeLayouts...)
+		parsed, ok := parseFlatFileTime(value, append(layouts, flatFileDateLayout)...)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a %v", value, columnType)
+		}
+		return parsed.UTC(), nil
+	case "date":
+		parsed, ok := parseFlatFileTime(value, append([]string{flatFileDateLayout}, flatFileDatetimetzLayouts...)...)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a date", value)
+		}
+		return parsed, nil
+	case "time":
+		return time.Parse(flatFileTimeLayout, value)
+	case "varbinary", "blob":
+		return hex.DecodeString(value)
+	default:
+		return value, nil
+	}
+}
+
+func parseFlatFileJsonValue(raw json.RawMessage, columnType string) (parsed driver.Value, err error) {
+	// strings are read for their contents, and other values for their json text, so a number
+	// can fill a text column, and an object a json one
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	if getFlatFileBaseType(columnType) == "json" {
+		return string(raw), nil
+	}
+
+	value := string(raw)
+	if raw[0] == '"' {
+		err = json.Unmarshal(raw, &value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseFlatFileValue(value, columnType)
+}
+
+func parseFlatFileJsonObject(line []byte) (keys []string, values map[string]json.RawMessage, err error) {
+	// reads the keys in the order they're written, which a map alone would lose
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, nil, errors.New("json lines rows must be objects")
+	}
+
+	values = map[string]json.RawMessage{}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key := token.(string)
+
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+
+	return keys, values, nil
+}
+
+type flatFileTypeGuess struct {
+	seen       bool
+	int64      bool
+	float64    bool
+	bool       bool
+	datetimetz bool
+	datetime   bool
+	date       bool
+	json       bool
+}
+
+func newFlatFileTypeGuess() *flatFileTypeGuess {
+	return &flatFileTypeGuess{int64: true, float64: true, bool: true, datetimetz: true, datetime: true, date: true, json: true}
+}
+
+func (guess *flatFileTypeGuess) getType() string {
+	switch {
+	case !guess.seen:
+		return "ntext"
+	case guess.int64:
+		return "int64"
+	case guess.float64:
+		return "float64"
+	case guess.bool:
+		return "bool"
+	case guess.datetimetz:
+		return "datetimetz"
+	case guess.datetime:
+		return "datetime"
+	case guess.date:
+		return "date"
+	case guess.json:
+		return "json"
+	default:
+		return "ntext"
+	}
+}
+
+func isFlatFileNumber(value string) bool {
+	// numbers with leading zeros, like zip codes, are text, and so are words like nan and inf
+
+	digits := strings.TrimLeft(value, "+-")
+	if digits == "" || (digits[0] < '0' || digits[0] > '9') && digits[0] != '.' {
+		return false
+	}
+
+	return !(len(digits) > 1 && digits[0] == '0' && digits[1] != '.' && digits[1] != 'e' && digits[1] != 'E')
+}
+
+func (guess *flatFileTypeGuess) addTimes(value string) {
+	_, ok := parseFlatFileTime(value, flatFileDatetimetzLayouts...)
+	guess.datetimetz = guess.datetimetz && ok
+	_, ok = parseFlatFileTime(value, flatFileDatetimeLayouts...)
+	guess.datetime = guess.datetime && ok
+	_, ok = parseFlatFileTime(value, flatFileDateLayout)
+	guess.date = guess.date && ok
+}
+
+func (guess *flatFileTypeGuess) addText(value string) {
+
+	guess.seen = true
+
+	_, err := strconv.ParseInt(value, 10, 64)
+	guess.int64 = guess.int64 && err == nil && isFlatFileNumber(value)
+
+	_, err = strconv.ParseFloat(value, 64)
+	guess.float64 = guess.float64 && err == nil && isFlatFileNumber(value)
+
+	guess.bool = guess.bool && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false"))
+	guess.json = false
+
+	guess.addTimes(value)
+}
+
+func (guess *flatFileTypeGuess) addJson(raw json.RawMessage) {
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return
+	}
+
+	guess.seen = true
+
+	switch raw[0] {
+	case '"':
+		var value string
+		json.Unmarshal(raw, &value)
+		guess.int64, guess.float64, guess.bool, guess.json = false, false, false, false
+		guess.addTimes(value)
+	case 't', 'f':
+		guess.int64, guess.float64, guess.datetimetz, guess.datetime, guess.date, guess.json = false, false, false, false, false, false
+	case '{', '[':
+		guess.int64, guess.float64, guess.bool, guess.datetimetz, guess.datetime, guess.date = false, false, false, false, false, false
+	default:
+		_, err := strconv.ParseInt(string(raw), 10, 64)
+		guess.int64 = guess.int64 && err == nil
+		guess.bool, guess.datetimetz, guess.datetime, guess.date, guess.json = false, false, false, false, false
+	}
+}
+
+type flatFileReader struct {
+	reader    *bufio.Reader
+	delimiter rune
+	quote     rune
+}
+
+func (reader *flatFileReader) readLine() (line []byte, err error) {
+	// reads the next json lines row, skipping blank lines
+
+	for {
+		line, err = reader.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (reader *flatFileReader) readCsv() (fields []string, quoted []bool, err error) {
+	// reads the next csv record, and whether each of its fields was quoted. quotes inside a
+	// quoted field are doubled, and blank lines are skipped
+
+	for {
+		fields, quoted, err = reader.readCsvRecord()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(fields) == 1 && fields[0] == "" && !quoted[0] {
+			continue
+		}
+		return fields, quoted, nil
+	}
+}
+
+func (reader *flatFileReader) readCsvRecord() (fields []string, quoted []bool, err error) {
+
+	var field strings.Builder
+	fieldQuoted := false
+	fieldStart := true
+	inQuotes := false
+	started := false
+
+	endField := func(lineEnd bool) {
+		value := field.String()
+		if lineEnd {
+			value = strings.TrimSuffix(value, "\r")
+		}
+		fields = append(fields, value)
+		quoted = append(quoted, fieldQuoted)
+		field.Reset()
+		fieldQuoted = false
+		fieldStart = true
+	}
+
+	for {
+		character, _, err := reader.reader.ReadRune()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil, nil, err
+			}
+			if inQuotes {
+				return nil, nil, errors.New("csv file ends inside a quoted field")
+			}
+			if !started {
+				return nil, nil, io.EOF
+			}
+			endField(true)
+			return fields, quoted, nil
+		}
+
+		started = true
+
+		if inQuotes {
+			if character != reader.quote {
+				field.WriteRune(character)
+				continue
+			}
+			next, _, err := reader.reader.ReadRune()
+			if err == nil && next == reader.quote {
+				field.WriteRune(character)
+				continue
+			}
+			if err == nil {
+				reader.reader.UnreadRune()
+			}
+			inQuotes = false
+			continue
+		}
+
+		switch {
+		case character == reader.quote && fieldStart:
+			inQuotes, fieldQuoted = true, true
+		case character == reader.delimiter:
+			endField(false)
+			continue
+		case character == '\n':
+			endField(true)
+			return fields, quoted, nil
+		default:
+			field.WriteRune(character)
+		}
+
+		fieldStart = false
+	}
+}
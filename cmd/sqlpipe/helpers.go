@@ -20,9 +20,11 @@ import (
 
 type envelope map[string]any
 
-var schemaRequired = map[string]bool{TypePostgreSQL: true, TypeMySQL: false, TypeMSSQL: true, TypeOracle: true, TypeSnowflake: true}
-var permittedTransferSources = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle, TypeSnowflake}
-var permittedTransferTargets = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle, TypeSnowflake}
+var schemaRequired = map[string]bool{TypePostgreSQL: true, TypeMySQL: false, TypeMSSQL: true, TypeOracle: true, TypeSnowflake: true, TypeSQLite: false, TypeDuckDB: true, TypeClickHouse: true, TypeParquet: false, TypeCsv: false, TypeJsonl: false, TypeS3: false}
+var permittedTransferSources = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle, TypeSnowflake, TypeSQLite, TypeDuckDB, TypeParquet, TypeCsv, TypeJsonl}
+var permittedTransferTargets = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle, TypeSnowflake, TypeSQLite, TypeDuckDB, TypeClickHouse, TypeParquet, TypeCsv, TypeJsonl, TypeS3}
+var singleWriterTargets = map[string]bool{TypeSQLite: true, TypeDuckDB: true}
+var partitionPipeTypes = []string{"int64", "int32", "int16", "float64", "float32", "decimal", "date", "datetime", "datetimetz"}
 
 var (
 	Statuses = []string{StatusQueued, StatusRunning, StatusCancelled, StatusError, StatusComplete, ""}
@@ -33,17 +35,68 @@ var (
 	StatusError     = "error"
 	StatusComplete  = "complete"
 
+	Loaders = []string{LoaderNative, LoaderExternal}
+
+	LoaderNative   = "native"
+	LoaderExternal = "external"
+
+	WriteModes = []string{WriteModeAppend, WriteModeTruncate, WriteModeSwap, WriteModeUpsert}
+
+	WriteModeAppend   = "append"
+	WriteModeTruncate = "truncate"
+	WriteModeSwap     = "swap"
+	WriteModeUpsert   = "upsert"
+
+	Verifications = []string{VerificationNone, VerificationRowCount, VerificationChecksum}
+
+	VerificationNone     = "none"
+	VerificationRowCount = "row-count"
+	VerificationChecksum = "checksum"
+
+	ScheduleOverlaps = []string{ScheduleOverlapSkip, ScheduleOverlapQueue}
+
+	ScheduleOverlapSkip  = "skip"
+	ScheduleOverlapQueue = "queue"
+
+	// the status of a schedule's run when it fires while the last run is still going
+	ScheduleRunSkipped = "skipped"
+
+	CdcSourceTypes       = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle}
+	CdcResumeSourceTypes = []string{TypeMySQL, TypeMSSQL, TypeOracle}
+
+	// checksums aggregate the source in the snapshot it's read in, which these sources can take
+	ChecksumSourceTypes = []string{TypePostgreSQL, TypeMySQL, TypeMSSQL, TypeOracle, TypeSQLite, TypeDuckDB}
+
+	StoreTypes = []string{StoreTypeBolt, StoreTypePostgreSQL, StoreTypeMemory}
+
+	StoreTypeBolt       = "bolt"
+	StoreTypePostgreSQL = "postgresql"
+	StoreTypeMemory     = "memory"
+
 	TypePostgreSQL = "postgresql"
 	TypeMySQL      = "mysql"
 	TypeMSSQL      = "mssql"
 	TypeOracle     = "oracle"
 	TypeSnowflake  = "snowflake"
+	TypeSQLite     = "sqlite"
+	TypeDuckDB     = "duckdb"
+	TypeClickHouse = "clickhouse"
+	TypeParquet    = "parquet"
+	TypeCsv        = "csv"
+	TypeJsonl      = "jsonl"
+	TypeS3         = "s3"
 
 	DriverPostgreSQL = "pgx"
 	DriverMySQL      = "mysql"
 	DriverMSSQL      = "sqlserver"
 	DriverOracle     = "oracle"
 	DriverSnowflake  = "snowflake"
+	DriverSQLite     = "sqlite"
+	DriverDuckDB     = "sqlpipe-duckdb"
+	DriverClickHouse = "clickhouse"
+	DriverParquet    = "parquet"
+	DriverCsv        = "csv"
+	DriverJsonl      = "jsonl"
 )
 
 func getFileNum(fileName string) (fileNum int64, err error) {
@@ -214,12 +267,14 @@ func checkDeps() {
 	checkPsql()
 	checkBcp()
 	checkSqlLdr()
+	checkMysqlbinlog()
+	checkDuckdb()
 }
 
 func checkPsql() {
 	output, err := exec.Command("psql", "--version").CombinedOutput()
 	if err != nil {
-		warningLog.Printf("psql not found. please install psql to transfer data to postgresql :: %v :: %v\n", err, string(output))
+		warningLog.Printf("psql not found. please install psql to use the external loader for postgresql :: %v :: %v\n", err, string(output))
 		return
 	}
 
@@ -229,7 +284,7 @@ func checkPsql() {
 func checkBcp() {
 	output, err := exec.Command("bcp", "-v").CombinedOutput()
 	if err != nil {
-		warningLog.Printf("bcp not found. please install bcp to transfer data to mssql :: %v :: %v\n", err, string(output))
+		warningLog.Printf("bcp not found. please install bcp to use the external loader for mssql :: %v :: %v\n", err, string(output))
 		return
 	}
 
@@ -239,13 +294,29 @@ func checkBcp() {
 func checkSqlLdr() {
 	output, err := exec.Command("sqlldr", "-help").CombinedOutput()
 	if err != nil {
-		warningLog.Printf("sqlldr not found. please install sqllder to transfer data to oracle :: %v :: %v\n", err, string(output))
+		warningLog.Printf("sqlldr not found. please install sqlldr to use the external loader for oracle :: %v :: %v\n", err, string(output))
 		return
 	}
 
 	sqlldrAvailable = true
 }
 
+func checkMysqlbinlog() {
+	output, err := exec.Command("mysqlbinlog", "--version").CombinedOutput()
+	if err != nil {
+		warningLog.Printf("mysqlbinlog not found. please install mysqlbinlog to capture changes from mysql :: %v :: %v\n", err, string(output))
+		return
+	}
+
+	mysqlbinlogAvailable = true
+}
+
+func checkDuckdb() {
+	if !duckdbAvailable {
+		warningLog.Println("sqlpipe was built without cgo, which the duckdb driver needs. please build it with CGO_ENABLED=1 to move data to or from duckdb")
+	}
+}
+
 func containsSpaces(s string) bool {
 	for _, char := range s {
 		if unicode.IsSpace(char) {
@@ -255,6 +326,18 @@ func containsSpaces(s string) bool {
 	return false
 }
 
+func splitCommaSeparated(s string) []string {
+	// splits a comma separated cli flag, ignoring blanks
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func RandomPrintableAsciiCharacters(length int) (string, error) {
 	randomString := ""
 
@@ -463,4 +546,5 @@ type ColumnInfo struct {
 type FinalCsvInfo struct {
 	FilePath   string
 	InsertInfo string
+	Rows       int
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -16,25 +17,34 @@ import (
 	_ "github.com/microsoft/go-mssqldb"
 	_ "github.com/sijms/go-ora/v2"
 	_ "github.com/snowflakedb/gosnowflake"
+	_ "modernc.org/sqlite"
 )
 
 var (
-	programVersion  = ProgramVersion()
-	port            int
-	infoLog         = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	warningLog      = log.New(os.Stdout, "WARNING\t", log.Ldate|log.Ltime)
-	errorLog        = log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	psqlAvailable   bool
-	bcpAvailable    bool
-	sqlldrAvailable bool
-	globalTmpDir    string
+	programVersion       = ProgramVersion()
+	port                 int
+	infoLog              = log.New(redactingWriter{io.MultiWriter(os.Stdout, transferLogWriter{level: "info"})}, "INFO\t", log.Ldate|log.Ltime)
+	warningLog           = log.New(redactingWriter{io.MultiWriter(os.Stdout, transferLogWriter{level: "warning"})}, "WARNING\t", log.Ldate|log.Ltime)
+	errorLog             = log.New(redactingWriter{io.MultiWriter(os.Stderr, transferLogWriter{level: "error"})}, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	psqlAvailable        bool
+	bcpAvailable         bool
+	sqlldrAvailable      bool
+	mysqlbinlogAvailable bool
+	globalTmpDir         string
+
+	storeType             string
+	storePath             string
+	storeConnectionString string
 
 	cliTransfer                                   bool
 	keepFilesCliTransferInput                     bool
+	stagingUrlCliTransferInput                    string
 	sourceNameCliTransferInput                    string
+	sourceConnectionCliTransferInput              string
 	sourceTypeCliTransferInput                    string
 	sourceConnectionStringCliTransferInput        string
 	targetNameCliTransferInput                    string
+	targetConnectionCliTransferInput              string
 	targetTypeCliTransferInput                    string
 	targetConnectionStringCliTransferInput        string
 	targetHostnameCliTransferInput                string
@@ -42,6 +52,9 @@ var (
 	targetDatabaseCliTransferInput                string
 	targetUsernameCliTransferInput                string
 	targetPasswordCliTransferInput                string
+	targetTableEngineCliTransferInput             string
+	targetOrderByCliTransferInput                 string
+	targetRowGroupSizeCliTransferInput            int
 	dropTargetTableIfExistsCliTransferInput       bool
 	createTargetSchemaIfNotExistsCliTransferInput bool
 	createTargetTableIfNotExistsCliTransferInput  bool
@@ -50,6 +63,18 @@ var (
 	targetSchemaCliTransferInput                  string
 	targetTableCliTransferInput                   string
 	queryCliTransferInput                         string
+	incrementalColumnCliTransferInput             string
+	includeTablesCliTransferInput                 string
+	excludeTablesCliTransferInput                 string
+	loaderCliTransferInput                        string
+	writeModeCliTransferInput                     string
+	verificationCliTransferInput                  string
+	cdcCliTransferInput                           bool
+	cdcBatchSecondsCliTransferInput               int
+	cdcPositionCliTransferInput                   string
+	partitionsCliTransferInput                    int
+	partitionColumnCliTransferInput               string
+	concurrentTablesCliTransferInput              int
 	delimiterCliTransferInput                     string
 	newlineCliTransferInput                       string
 	nullCliTransferInput                          string
@@ -59,13 +84,24 @@ func main() {
 
 	flag.IntVar(&port, "port", 9000, "api server port")
 	displayVersion := flag.Bool("version", false, "display version and exit")
+	flag.StringVar(&storeType, "store-type", StoreTypeBolt, fmt.Sprintf("where the api server keeps transfer history - one of %v", StoreTypes))
+	flag.StringVar(&storePath, "store-path", "sqlpipe.db", "path to the bolt transfer store file")
+	flag.StringVar(&storeConnectionString, "store-connection-string", "", "postgresql connection string for the postgresql transfer store")
+	flag.DurationVar(&shutdownGracePeriod, "shutdown-grace-period", 25*time.Second, "how long the api server waits for running transfers to finish when it's stopped, before cancelling them")
+	flag.IntVar(&maxConcurrentTransfers, "max-concurrent-transfers", 0, "most transfers the api server runs at once, others wait in the queue, 0 for no limit")
+	flag.IntVar(&maxTransfersPerSourceHost, "max-transfers-per-source-host", 0, "most transfers the api server runs at once from the same source host, 0 for no limit")
+	flag.IntVar(&maxTransfersPerTargetHost, "max-transfers-per-target-host", 0, "most transfers the api server runs at once to the same target host, 0 for no limit")
+	flag.IntVar(&maxCdcTransfers, "max-cdc-transfers", 0, "most change data capture transfers the api server runs at once, they don't count against the other limits, 0 for no limit")
 
 	flag.BoolVar(&cliTransfer, "cli-transfer", false, "perform a cli transfer")
 	flag.BoolVar(&keepFilesCliTransferInput, "keep-files", false, "keep files after cli transfer")
+	flag.StringVar(&stagingUrlCliTransferInput, "staging-url", "", "s3 url to stage pipe files in, like s3://bucket/prefix")
 	flag.StringVar(&sourceNameCliTransferInput, "source-name", "", "source name")
+	flag.StringVar(&sourceConnectionCliTransferInput, "source-connection", "", "name of a stored connection to use as the source")
 	flag.StringVar(&sourceTypeCliTransferInput, "source-type", "", "source type")
 	flag.StringVar(&sourceConnectionStringCliTransferInput, "source-connection-string", "", "source connection string")
 	flag.StringVar(&targetNameCliTransferInput, "target-name", "", "target name")
+	flag.StringVar(&targetConnectionCliTransferInput, "target-connection", "", "name of a stored connection to use as the target")
 	flag.StringVar(&targetTypeCliTransferInput, "target-type", "", "target type")
 	flag.StringVar(&targetConnectionStringCliTransferInput, "target-connection-string", "", "target connection string")
 	flag.StringVar(&targetHostnameCliTransferInput, "target-hostname", "", "target hostname")
@@ -73,6 +109,9 @@ func main() {
 	flag.StringVar(&targetDatabaseCliTransferInput, "target-database", "", "target database")
 	flag.StringVar(&targetUsernameCliTransferInput, "target-username", "", "target username")
 	flag.StringVar(&targetPasswordCliTransferInput, "target-password", "", "target password")
+	flag.StringVar(&targetTableEngineCliTransferInput, "target-table-engine", "", "engine for clickhouse target tables sqlpipe creates, defaults to MergeTree")
+	flag.StringVar(&targetOrderByCliTransferInput, "target-order-by", "", "order by for clickhouse target tables sqlpipe creates, defaults to the primary key")
+	flag.IntVar(&targetRowGroupSizeCliTransferInput, "target-row-group-size", 0, "rows per row group in parquet files sqlpipe writes, defaults to 100000")
 	flag.BoolVar(&dropTargetTableIfExistsCliTransferInput, "drop-target-table-if-exists", false, "drop target table if exists")
 	flag.BoolVar(&createTargetSchemaIfNotExistsCliTransferInput, "create-target-schema-if-not-exists", false, "create target schema if not exists")
 	flag.BoolVar(&createTargetTableIfNotExistsCliTransferInput, "create-target-table-if-not-exists", false, "create target table if not exists")
@@ -81,6 +120,18 @@ func main() {
 	flag.StringVar(&targetSchemaCliTransferInput, "target-schema", "", "target schema")
 	flag.StringVar(&targetTableCliTransferInput, "target-table", "", "target table")
 	flag.StringVar(&queryCliTransferInput, "query", "", "query")
+	flag.StringVar(&loaderCliTransferInput, "loader", "native", "how to load postgresql, mssql and oracle targets - native or external")
+	flag.BoolVar(&cdcCliTransferInput, "cdc", false, "after the initial load, keep applying changes from the source table until interrupted")
+	flag.IntVar(&cdcBatchSecondsCliTransferInput, "cdc-batch-seconds", 0, "seconds of source changes to collect before applying them to the target, defaults to 60")
+	flag.StringVar(&cdcPositionCliTransferInput, "cdc-position", "", "source position a previous cdc transfer stopped at, a mysql binlog position, sql server change tracking version or oracle tracking version, to resume from instead of loading the table")
+	flag.StringVar(&verificationCliTransferInput, "verification", "none", fmt.Sprintf("how to check the target after loading - one of %v", Verifications))
+	flag.StringVar(&writeModeCliTransferInput, "write-mode", "append", fmt.Sprintf("how to write to the target table - one of %v", WriteModes))
+	flag.IntVar(&partitionsCliTransferInput, "partitions", 0, "number of concurrent range reads to split the source table into")
+	flag.StringVar(&partitionColumnCliTransferInput, "partition-column", "", "numeric or date column to split the source table on, defaults to the primary key")
+	flag.StringVar(&incrementalColumnCliTransferInput, "incremental-column", "", "timestamp column used to only transfer new or updated rows")
+	flag.StringVar(&includeTablesCliTransferInput, "include-tables", "", "comma separated table patterns to transfer from the source schema")
+	flag.StringVar(&excludeTablesCliTransferInput, "exclude-tables", "", "comma separated table patterns to skip when transferring the source schema")
+	flag.IntVar(&concurrentTablesCliTransferInput, "concurrent-tables", 0, fmt.Sprintf("number of tables a schema transfer moves at once, defaults to %v", defaultConcurrentTables))
 	flag.StringVar(&delimiterCliTransferInput, "delimiter", "{dlm}", "delimiter")
 	flag.StringVar(&newlineCliTransferInput, "newline", "{nwln}", "newline")
 	flag.StringVar(&nullCliTransferInput, "null", "{nll}", "null")
@@ -92,6 +143,10 @@ func main() {
 		os.Exit(0)
 	}
 
+	if maxConcurrentTransfers < 0 || maxTransfersPerSourceHost < 0 || maxTransfersPerTargetHost < 0 || maxCdcTransfers < 0 {
+		errorLog.Fatal("max-concurrent-transfers, max-transfers-per-source-host, max-transfers-per-target-host, and max-cdc-transfers must not be negative")
+	}
+
 	checkDeps()
 
 	globalTmpDir = filepath.Join(os.TempDir(), "sqlpipe")
@@ -106,10 +161,13 @@ func main() {
 	if cliTransfer {
 		cliTransferInput := CliTransferInput{
 			KeepFiles:                     keepFilesCliTransferInput,
+			StagingUrl:                    stagingUrlCliTransferInput,
 			SourceName:                    sourceNameCliTransferInput,
+			SourceConnection:              sourceConnectionCliTransferInput,
 			SourceType:                    sourceTypeCliTransferInput,
 			SourceConnectionString:        sourceConnectionStringCliTransferInput,
 			TargetName:                    targetNameCliTransferInput,
+			TargetConnection:              targetConnectionCliTransferInput,
 			TargetType:                    targetTypeCliTransferInput,
 			TargetConnectionString:        targetConnectionStringCliTransferInput,
 			TargetHostname:                targetHostnameCliTransferInput,
@@ -117,6 +175,9 @@ func main() {
 			TargetDatabase:                targetDatabaseCliTransferInput,
 			TargetUsername:                targetUsernameCliTransferInput,
 			TargetPassword:                targetPasswordCliTransferInput,
+			TargetTableEngine:             targetTableEngineCliTransferInput,
+			TargetOrderBy:                 targetOrderByCliTransferInput,
+			TargetRowGroupSize:            targetRowGroupSizeCliTransferInput,
 			DropTargetTableIfExists:       dropTargetTableIfExistsCliTransferInput,
 			CreateTargetSchemaIfNotExists: createTargetSchemaIfNotExistsCliTransferInput,
 			CreateTargetTableIfNotExists:  createTargetTableIfNotExistsCliTransferInput,
@@ -125,6 +186,18 @@ func main() {
 			TargetSchema:                  targetSchemaCliTransferInput,
 			TargetTable:                   targetTableCliTransferInput,
 			Query:                         queryCliTransferInput,
+			IncrementalColumn:             incrementalColumnCliTransferInput,
+			IncludeTables:                 splitCommaSeparated(includeTablesCliTransferInput),
+			ExcludeTables:                 splitCommaSeparated(excludeTablesCliTransferInput),
+			ConcurrentTables:              concurrentTablesCliTransferInput,
+			Loader:                        loaderCliTransferInput,
+			WriteMode:                     writeModeCliTransferInput,
+			Verification:                  verificationCliTransferInput,
+			Cdc:                           cdcCliTransferInput,
+			CdcBatchSeconds:               cdcBatchSecondsCliTransferInput,
+			CdcPosition:                   cdcPositionCliTransferInput,
+			Partitions:                    partitionsCliTransferInput,
+			PartitionColumn:               partitionColumnCliTransferInput,
 			Delimiter:                     delimiterCliTransferInput,
 			Newline:                       newlineCliTransferInput,
 			Null:                          nullCliTransferInput,
@@ -134,6 +207,33 @@ func main() {
 		return
 	}
 
+	store, err := newTransferStore(storeType, storePath, storeConnectionString)
+	if err != nil {
+		errorLog.Fatalf("error opening transfer store :: %v", err)
+	}
+
+	err = transferMap.LoadStore(store)
+	if err != nil {
+		errorLog.Fatalf("error loading transfer store :: %v", err)
+	}
+
+	connectionKey, err := loadConnectionKey()
+	if err != nil {
+		errorLog.Fatalf("error loading connection key :: %v", err)
+	}
+
+	err = connectionMap.LoadStore(store, connectionKey)
+	if err != nil {
+		errorLog.Fatalf("error loading connections :: %v", err)
+	}
+
+	// schedules can use stored connections, so they're loaded after them
+	err = scheduleMap.LoadStore(store, connectionKey)
+	if err != nil {
+		errorLog.Fatalf("error loading schedules :: %v", err)
+	}
+	defer transferMap.Close()
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
 		Handler:      routes(),
@@ -144,8 +244,5 @@ func main() {
 
 	infoLog.Printf("now listening on port %d", port)
 
-	err = srv.ListenAndServe()
-	if err != nil {
-		errorLog.Fatal(err)
-	}
+	serveUntilShutdown(srv)
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metrics are served in the prometheus format on /metrics. per transfer metrics are labelled
+// with the transfer id, so they can be joined with the transfer's status from the api. a
+// transfer's series are deleted when it stops, so they don't pile up on a long running server
+
+var (
+	rowsReadCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_rows_read_total",
+		Help: "Rows read from the source and written to pipe files.",
+	}, []string{"transfer_id", "source_type"})
+
+	bytesReadCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_bytes_read_total",
+		Help: "Bytes of formatted values read from the source and written to pipe files.",
+	}, []string{"transfer_id", "source_type"})
+
+	pipeFilesWrittenCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_pipe_files_written_total",
+		Help: "Pipe files written.",
+	}, []string{"transfer_id", "source_type"})
+
+	finalCsvsInsertedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_final_csvs_inserted_total",
+		Help: "Final csvs inserted into the target. Loaders that read pipe files directly count each pipe file.",
+	}, []string{"transfer_id", "target_type"})
+
+	stageDurationCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_stage_duration_seconds_total",
+		Help: "Time spent in each stage of a transfer. Stages run concurrently, so they can add up to more than the transfer's duration.",
+	}, []string{"transfer_id", "system_type", "stage"})
+
+	loaderFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqlpipe_loader_failures_total",
+		Help: "Failed runs of external loader subprocesses.",
+	}, []string{"transfer_id", "target_type", "loader"})
+
+	transfersDesc = prometheus.NewDesc(
+		"sqlpipe_transfers",
+		"Transfers by status, source type and target type.",
+		[]string{"status", "source_type", "target_type"}, nil,
+	)
+)
+
+var (
+	StageQuery         = "query"
+	StagePipeFileWrite = "pipe-file-write"
+	StageConversion    = "conversion"
+	StageLoad          = "load"
+	StageCdc           = "cdc"
+)
+
+var (
+	transferCounters = []*prometheus.CounterVec{rowsReadCounter, bytesReadCounter, pipeFilesWrittenCounter,
+		finalCsvsInsertedCounter, stageDurationCounter, loaderFailuresCounter}
+
+	// recording holds a read lock and deleting a write lock, so a transfer's series can't be
+	// recorded again after they're deleted
+	transferCountersMu sync.RWMutex
+)
+
+func init() {
+	prometheus.MustRegister(transfersCollector{})
+}
+
+type transfersCollector struct{}
+
+func (collector transfersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- transfersDesc
+}
+
+func (collector transfersCollector) Collect(ch chan<- prometheus.Metric) {
+	// counted when scraped, so the gauge always agrees with the transfer map
+
+	counts := map[[3]string]int{}
+	for _, transfer := range transferMap.GetAll() {
+		counts[[3]string{transfer.Status, transfer.SourceConnectionInfo.Type, transfer.TargetConnectionInfo.Type}]++
+	}
+
+	for labels, count := range counts {
+		ch <- prometheus.MustNewConstMetric(transfersDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1], labels[2])
+	}
+}
+
+func recordPipeFileWritten(transfer Transfer, rows, bytes int) {
+	if !lockTransferCounters(transfer) {
+		return
+	}
+	defer transferCountersMu.RUnlock()
+
+	sourceType := transfer.SourceConnectionInfo.Type
+	rowsReadCounter.WithLabelValues(transfer.Id, sourceType).Add(float64(rows))
+	bytesReadCounter.WithLabelValues(transfer.Id, sourceType).Add(float64(bytes))
+	pipeFilesWrittenCounter.WithLabelValues(transfer.Id, sourceType).Inc()
+}
+
+func recordFinalCsvInserted(transfer Transfer) {
+	if !lockTransferCounters(transfer) {
+		return
+	}
+	defer transferCountersMu.RUnlock()
+
+	finalCsvsInsertedCounter.WithLabelValues(transfer.Id, transfer.TargetConnectionInfo.Type).Inc()
+}
+
+func recordStageDuration(transfer Transfer, stage string, start time.Time) {
+	// querying and writing pipe files happen on the source, converting and loading on the target
+
+	if !lockTransferCounters(transfer) {
+		return
+	}
+	defer transferCountersMu.RUnlock()
+
+	systemType := transfer.TargetConnectionInfo.Type
+	switch stage {
+	case StageQuery, StagePipeFileWrite:
+		systemType = transfer.SourceConnectionInfo.Type
+	}
+
+	stageDurationCounter.WithLabelValues(transfer.Id, systemType, stage).Add(time.Since(start).Seconds())
+}
+
+func recordLoaderFailure(transfer Transfer, loader string) {
+	if !lockTransferCounters(transfer) {
+		return
+	}
+	defer transferCountersMu.RUnlock()
+
+	loaderFailuresCounter.WithLabelValues(transfer.Id, transfer.TargetConnectionInfo.Type, loader).Inc()
+}
+
+func lockTransferCounters(transfer Transfer) bool {
+	// read locks the counters, unless the transfer was cancelled. a stopped transfer is
+	// cancelled before its series are deleted, so nothing it records afterwards is kept
+
+	transferCountersMu.RLock()
+	if transfer.Context != nil && transfer.Context.Err() != nil {
+		transferCountersMu.RUnlock()
+		return false
+	}
+	return true
+}
+
+func deleteTransferMetrics(transfer Transfer) {
+	transferCountersMu.Lock()
+	defer transferCountersMu.Unlock()
+
+	for _, counter := range transferCounters {
+		counter.DeletePartialMatch(prometheus.Labels{"transfer_id": transfer.Id})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// buckets are named by urls like s3://access-key:secret-key@bucket/prefix?region=us-west-2. keys
+// left out of the url are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN.
+// an endpoint setting points the client at an s3 compatible service, like minio, and addresses
+// buckets by path unless path-style is false. the s3 target takes more settings, so settings
+// this file doesn't use are handed back to the caller
+
+type s3Location struct {
+	Bucket             string
+	Prefix             string
+	Region             string
+	Endpoint           string
+	AccessKeyId        string
+	SecretAccessKey    string
+	SessionToken       string
+	StorageIntegration string
+	client             *s3.Client
+}
+
+func newS3Location(rawUrl string) (location s3Location, settings url.Values, err error) {
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return location, nil, fmt.Errorf("error parsing s3 url :: %v", getUrlParseReason(err))
+	}
+
+	if parsedUrl.Scheme != "s3" || parsedUrl.Host == "" {
+		return location, nil, errors.New("s3 urls must look like s3://bucket/prefix")
+	}
+
+	location.Bucket = parsedUrl.Host
+	location.Prefix = strings.Trim(parsedUrl.Path, "/")
+
+	if parsedUrl.User != nil {
+		location.AccessKeyId = parsedUrl.User.Username()
+		location.SecretAccessKey, _ = parsedUrl.User.Password()
+	} else {
+		location.AccessKeyId = os.Getenv("AWS_ACCESS_KEY_ID")
+		location.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		location.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	settings, err = url.ParseQuery(parsedUrl.RawQuery)
+	if err != nil {
+		return location, nil, fmt.Errorf("error parsing s3 url settings :: %v", err)
+	}
+
+	location.Region = settings.Get("region")
+	if location.Region == "" {
+		location.Region = os.Getenv("AWS_REGION")
+	}
+	if location.Region == "" {
+		location.Region = "us-east-1"
+	}
+
+	location.Endpoint = settings.Get("endpoint")
+
+	pathStyle := location.Endpoint != ""
+	if settings.Has("path-style") {
+		pathStyle, err = strconv.ParseBool(settings.Get("path-style"))
+		if err != nil {
+			return location, nil, errors.New("s3 url setting path-style must be true or false")
+		}
+	}
+
+	// snowflake reads staged files through a storage integration, if there is one, instead of keys
+	location.StorageIntegration = settings.Get("storage-integration")
+
+	for _, key := range []string{"region", "endpoint", "path-style", "storage-integration"} {
+		settings.Del(key)
+	}
+
+	options := s3.Options{
+		Region:       location.Region,
+		UsePathStyle: pathStyle,
+		Credentials:  aws.AnonymousCredentials{},
+	}
+
+	if location.AccessKeyId != "" {
+		options.Credentials = credentials.NewStaticCredentialsProvider(location.AccessKeyId, location.SecretAccessKey, location.SessionToken)
+	}
+
+	if location.Endpoint != "" {
+		options.BaseEndpoint = aws.String(location.Endpoint)
+	}
+
+	location.client = s3.New(options)
+
+	return location, settings, nil
+}
+
+func (location s3Location) getKey(names ...string) (key string) {
+	return path.Join(append([]string{location.Prefix}, names...)...)
+}
+
+func (location s3Location) getUrl(names ...string) (objectUrl string) {
+	return fmt.Sprintf("s3://%v/%v", location.Bucket, location.getKey(names...))
+}
+
+func isS3NotFoundError(err error) bool {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) {
+		switch apiError.ErrorCode() {
+		case "NotFound", "NoSuchKey":
+			return true
+		}
+	}
+	return false
+}
+
+func (location s3Location) upload(ctx context.Context, key, localPath string) (err error) {
+	// large files are uploaded in parts, so their size isn't limited by a single request
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("error opening file to upload :: %v", err)
+	}
+	defer file.Close()
+
+	_, err = manager.NewUploader(location.client).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(location.Bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading %v to s3://%v/%v :: %v", localPath, location.Bucket, key, err)
+	}
+
+	return nil
+}
+
+func (location s3Location) download(ctx context.Context, key, localPath string) (found bool, err error) {
+
+	output, err := location.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(location.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error downloading s3://%v/%v :: %v", location.Bucket, key, err)
+	}
+	defer output.Body.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return false, fmt.Errorf("error creating downloaded file :: %v", err)
+	}
+
+	_, err = io.Copy(file, output.Body)
+	if err != nil {
+		file.Close()
+		return false, fmt.Errorf("error downloading s3://%v/%v :: %v", location.Bucket, key, err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return false, fmt.Errorf("error closing downloaded file :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (location s3Location) delete(ctx context.Context, key string) (err error) {
+	// deleting a key that doesn't exist isn't an error in s3
+
+	_, err = location.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(location.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting s3://%v/%v :: %v", location.Bucket, key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/file"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"github.com/apache/arrow/go/v12/parquet/schema"
+	"github.com/google/uuid"
+)
+
+// parquet files are read through a small database/sql driver, so they can be a source like any
+// database. the connection string is a directory, and every <table>.parquet file in it is a table.
+// types are named after the parquet logical type of each column, like TIMESTAMPTZ for timestamps
+// adjusted to utc, and columns that aren't primitives are read as json
+
+const parquetFileExtension = ".parquet"
+
+func init() {
+	sql.Register(DriverParquet, parquetDriver{})
+}
+
+type parquetDriver struct{}
+
+func (d parquetDriver) Open(name string) (driver.Conn, error) {
+	return &parquetConn{directory: name}, nil
+}
+
+type parquetConn struct {
+	directory string
+}
+
+func (conn *parquetConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("parquet connections do not support prepared statements")
+}
+
+func (conn *parquetConn) Close() error {
+	return nil
+}
+
+func (conn *parquetConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("parquet connections do not support transactions")
+}
+
+func (conn *parquetConn) Ping(ctx context.Context) error {
+	info, err := os.Stat(conn.directory)
+	if err != nil {
+		return fmt.Errorf("error reading parquet directory :: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("parquet connection string %v is not a directory", conn.directory)
+	}
+	return nil
+}
+
+func (conn *parquetConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, errors.New("parquet connections only read files, sqlpipe writes them without running statements")
+}
+
+func (conn *parquetConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	if len(args) > 0 {
+		return nil, errors.New("parquet connections do not support query arguments")
+	}
+
+	statement, table, err := parseFileStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if statement == fileStatementShowTables {
+		return getTableFileRows(conn.directory, parquetFileExtension)
+	}
+
+	path, err := getTableFilePath(conn.directory, table, parquetFileExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	switch statement {
+	case fileStatementCount:
+		return conn.count(path)
+	case fileStatementDescribe:
+		return conn.describe(path)
+	default:
+		return conn.selectAll(path)
+	}
+}
+
+func (conn *parquetConn) count(path string) (driver.Rows, error) {
+
+	reader, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("error opening parquet file :: %v", err)
+	}
+	defer reader.Close()
+
+	return &fileStaticRows{
+		columnNames: []string{"count"},
+		values:      [][]driver.Value{{reader.NumRows()}},
+	}, nil
+}
+
+func (conn *parquetConn) describe(path string) (driver.Rows, error) {
+
+	reader, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("error opening parquet file :: %v", err)
+	}
+	defer reader.Close()
+
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, fmt.Errorf("error reading parquet schema :: %v", err)
+	}
+
+	rows := &fileStaticRows{
+		columnNames: []string{"col_name", "col_type", "col_precision", "col_scale", "col_length", "col_is_primary"},
+	}
+
+	for i, columnType := range getParquetColumnTypes(reader, fileReader) {
+		precision, scale, ok := getParquetPrecisionScale(columnType)
+		if !ok {
+			precision, scale = -1, -1
+		}
+		rows.values = append(rows.values, []driver.Value{
+			fileReader.Manifest.Fields[i].Field.Name, columnType, precision, scale, int64(-1), false,
+		})
+	}
+
+	return rows, nil
+}
+
+func (conn *parquetConn) selectAll(path string) (driver.Rows, error) {
+
+	reader, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return nil, fmt.Errorf("error opening parquet file :: %v", err)
+	}
+
+	fileReader, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{BatchSize: 64 * 1024}, memory.DefaultAllocator)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("error reading parquet schema :: %v", err)
+	}
+
+	recordReader, err := fileReader.GetRecordReader(context.Background(), nil, nil)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("error creating parquet record reader :: %v", err)
+	}
+
+	rows := &parquetFileRows{
+		reader:       reader,
+		recordReader: recordReader,
+		columnTypes:  getParquetColumnTypes(reader, fileReader),
+	}
+
+	for _, field := range fileReader.Manifest.Fields {
+		rows.columnNames = append(rows.columnNames, field.Field.Name)
+		rows.nullable = append(rows.nullable, field.Field.Nullable)
+	}
+
+	return rows, nil
+}
+
+func getParquetColumnTypes(reader *file.Reader, fileReader *pqarrow.FileReader) (columnTypes []string) {
+	// names each top level field by its parquet column. fields that group other columns,
+	// like lists and structs, have no column of their own
+
+	for _, field := range fileReader.Manifest.Fields {
+		if !field.IsLeaf() {
+			columnTypes = append(columnTypes, "NESTED")
+			continue
+		}
+		columnTypes = append(columnTypes, getParquetColumnType(reader.MetaData().Schema.Column(field.ColIndex)))
+	}
+
+	return columnTypes
+}
+
+func getParquetColumnType(column *schema.Column) (columnType string) {
+
+	switch logicalType := column.LogicalType().(type) {
+	case schema.StringLogicalType, schema.EnumLogicalType:
+		return "STRING"
+	case schema.JSONLogicalType:
+		return "JSON"
+	case schema.UUIDLogicalType:
+		return "UUID"
+	case schema.DateLogicalType:
+		return "DATE"
+	case *schema.DecimalLogicalType:
+		return fmt.Sprintf("DECIMAL(%v,%v)", logicalType.Precision(), logicalType.Scale())
+	case *schema.TimeLogicalType:
+		return "TIME"
+	case *schema.TimestampLogicalType:
+		if logicalType.IsAdjustedToUTC() {
+			return "TIMESTAMPTZ"
+		}
+		return "TIMESTAMP"
+	case *schema.IntLogicalType:
+		if logicalType.IsSigned() {
+			return fmt.Sprintf("INT%v", logicalType.BitWidth())
+		}
+		return fmt.Sprintf("UINT%v", logicalType.BitWidth())
+	}
+
+	switch column.PhysicalType() {
+	case parquet.Types.Boolean:
+		return "BOOLEAN"
+	case parquet.Types.Int32:
+		return "INT32"
+	case parquet.Types.Int64:
+		return "INT64"
+	case parquet.Types.Int96:
+		// legacy timestamps, written by older versions of spark and impala
+		return "INT96"
+	case parquet.Types.Float:
+		return "FLOAT"
+	case parquet.Types.Double:
+		return "DOUBLE"
+	default:
+		return "BINARY"
+	}
+}
+
+func getParquetBaseType(columnType string) string {
+	// strips the precision and scale from types like DECIMAL(18,3)
+	if i := strings.Index(columnType, "("); i >= 0 {
+		columnType = columnType[:i]
+	}
+	return columnType
+}
+
+func getParquetPrecisionScale(columnType string) (precision, scale int64, ok bool) {
+
+	i := strings.Index(columnType, "(")
+	if getParquetBaseType(columnType) != "DECIMAL" || i < 0 {
+		return 0, 0, false
+	}
+
+	_, err := fmt.Sscanf(columnType[i:], "(%d,%d)", &precision, &scale)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return precision, scale, true
+}
+
+type parquetFileRows struct {
+	columnNames  []string
+	columnTypes  []string
+	nullable     []bool
+	reader       *file.Reader
+	recordReader pqarrow.RecordReader
+	record       arrow.Record
+	next         int
+}
+
+func (rows *parquetFileRows) Columns() []string {
+	return rows.columnNames
+}
+
+func (rows *parquetFileRows) ColumnTypeDatabaseTypeName(index int) string {
+	return rows.columnTypes[index]
+}
+
+func (rows *parquetFileRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return rows.nullable[index], true
+}
+
+func (rows *parquetFileRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	return getParquetPrecisionScale(rows.columnTypes[index])
+}
+
+func (rows *parquetFileRows) Next(dest []driver.Value) (err error) {
+
+	// records are read a batch at a time, and the reader reuses them, so values are copied out
+	for rows.record == nil || rows.next >= int(rows.record.NumRows()) {
+		if !rows.recordReader.Next() {
+			if err := rows.recordReader.Err(); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("error reading parquet file :: %v", err)
+			}
+			return io.EOF
+		}
+		rows.record = rows.recordReader.Record()
+		rows.next = 0
+	}
+
+	for i, column := range rows.record.Columns() {
+		if column.IsNull(rows.next) {
+			dest[i] = nil
+			continue
+		}
+
+		dest[i], err = getParquetValue(column, rows.next, rows.columnTypes[i])
+		if err != nil {
+			return fmt.Errorf("error reading column %v :: %v", rows.columnNames[i], err)
+		}
+	}
+
+	rows.next++
+
+	return nil
+}
+
+func getParquetValue(column arrow.Array, i int, columnType string) (value driver.Value, err error) {
+
+	switch column := column.(type) {
+	case *array.Boolean:
+		return column.Value(i), nil
+	case *array.Int8:
+		return int64(column.Value(i)), nil
+	case *array.Int16:
+		return int64(column.Value(i)), nil
+	case *array.Int32:
+		return int64(column.Value(i)), nil
+	case *array.Int64:
+		return column.Value(i), nil
+	case *array.Uint8:
+		return int64(column.Value(i)), nil
+	case *array.Uint16:
+		return int64(column.Value(i)), nil
+	case *array.Uint32:
+		return int64(column.Value(i)), nil
+	case *array.Uint64:
+		// too big for an int64, so they're moved as decimals
+		return strconv.FormatUint(column.Value(i), 10), nil
+	case *array.Float32:
+		return float64(column.Value(i)), nil
+	case *array.Float64:
+		return column.Value(i), nil
+	case *array.String:
+		return column.Value(i), nil
+	case *array.Binary:
+		if columnType == "JSON" {
+			return string(column.Value(i)), nil
+		}
+		return append([]byte{}, column.Value(i)...), nil
+	case *array.FixedSizeBinary:
+		if columnType == "UUID" {
+			id, err := uuid.FromBytes(column.Value(i))
+			if err != nil {
+				return nil, err
+			}
+			return id.String(), nil
+		}
+		return append([]byte{}, column.Value(i)...), nil
+	case *array.Decimal128:
+		return column.Value(i).ToString(column.DataType().(*arrow.Decimal128Type).Scale), nil
+	case *array.Date32:
+		return column.Value(i).ToTime(), nil
+	case *array.Date64:
+		return column.Value(i).ToTime(), nil
+	case *array.Time32:
+		return column.Value(i).ToTime(column.DataType().(*arrow.Time32Type).Unit), nil
+	case *array.Time64:
+		return column.Value(i).ToTime(column.DataType().(*arrow.Time64Type).Unit), nil
+	case *array.Timestamp:
+		return column.Value(i).ToTime(column.DataType().(*arrow.TimestampType).Unit).UTC(), nil
+	default:
+		jsonValue, err := json.Marshal(column.GetOneForMarshal(i))
+		if err != nil {
+			return nil, err
+		}
+		return string(jsonValue), nil
+	}
+}
+
+func (rows *parquetFileRows) Close() error {
+	rows.recordReader.Release()
+	return rows.reader.Close()
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TransferProgress struct {
+	// transfers are copied by value into each pipeline stage, so progress is shared through
+	// a pointer that every copy of the transfer holds. all methods are safe on a nil progress
+
+	mu     sync.Mutex
+	values transferProgressValues
+}
+
+type transferProgressValues struct {
+	Stage                     string  `json:"stage,omitempty"`
+	StartedAt                 string  `json:"started-at,omitempty"`
+	StoppedAt                 string  `json:"stopped-at,omitempty"`
+	RowsRead                  int64   `json:"rows-read"`
+	BytesRead                 int64   `json:"bytes-read"`
+	PipeFilesWritten          int64   `json:"pipe-files-written"`
+	FinalCsvsConverted        int64   `json:"final-csvs-converted"`
+	FilesLoaded               int64   `json:"files-loaded"`
+	RowsLoaded                int64   `json:"rows-loaded"`
+	EstimatedRows             int64   `json:"estimated-rows,omitempty"`
+	RowsPerSecond             float64 `json:"rows-per-second"`
+	PercentComplete           float64 `json:"percent-complete,omitempty"`
+	EstimatedSecondsRemaining float64 `json:"estimated-seconds-remaining,omitempty"`
+}
+
+func newTransferProgress() *TransferProgress {
+	return &TransferProgress{}
+}
+
+func (progress *TransferProgress) setStage(stage string) {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.values.StartedAt == "" {
+		progress.values.StartedAt = time.Now().Format(time.RFC3339Nano)
+	}
+	progress.values.Stage = stage
+}
+
+func (progress *TransferProgress) setEstimatedRows(estimatedRows int64) {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.values.EstimatedRows = estimatedRows
+}
+
+func (progress *TransferProgress) addPipeFile(rows, bytes int) {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.values.RowsRead += int64(rows)
+	progress.values.BytesRead += int64(bytes)
+	progress.values.PipeFilesWritten++
+}
+
+func (progress *TransferProgress) addFinalCsv() {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.values.FinalCsvsConverted++
+}
+
+func (progress *TransferProgress) addLoadedFile(rows int) {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	progress.values.RowsLoaded += int64(rows)
+	progress.values.FilesLoaded++
+}
+
+func (progress *TransferProgress) finish() {
+	if progress == nil {
+		return
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.values.StoppedAt == "" {
+		progress.values.StoppedAt = time.Now().Format(time.RFC3339Nano)
+	}
+	progress.values.Stage = ""
+}
+
+func (progress *TransferProgress) snapshot() (values transferProgressValues) {
+	// throughput and eta are worked out from rows loaded, since loading is the last stage
+
+	if progress == nil {
+		return values
+	}
+
+	progress.mu.Lock()
+	values = progress.values
+	progress.mu.Unlock()
+
+	startedAt, err := time.Parse(time.RFC3339Nano, values.StartedAt)
+	if err != nil {
+		return values
+	}
+
+	stoppedAt := time.Now()
+	if values.StoppedAt != "" {
+		stoppedAt, err = time.Parse(time.RFC3339Nano, values.StoppedAt)
+		if err != nil {
+			return values
+		}
+	}
+
+	elapsed := stoppedAt.Sub(startedAt).Seconds()
+	if elapsed > 0 {
+		values.RowsPerSecond = float64(values.RowsLoaded) / elapsed
+	}
+
+	if values.EstimatedRows > 0 {
+		// estimates from the catalog can be stale, so don't report more than 100 percent
+		values.PercentComplete = math.Min(100, 100*float64(values.RowsLoaded)/float64(values.EstimatedRows))
+		if values.Stage != "" && values.RowsPerSecond > 0 && values.EstimatedRows > values.RowsLoaded {
+			values.EstimatedSecondsRemaining = float64(values.EstimatedRows-values.RowsLoaded) / values.RowsPerSecond
+		}
+	}
+
+	return values
+}
+
+func (progress *TransferProgress) summary() string {
+	if progress == nil {
+		return "no progress reported"
+	}
+
+	values := progress.snapshot()
+
+	parts := []string{
+		fmt.Sprintf("stage %v", values.Stage),
+		fmt.Sprintf("%v rows read", values.RowsRead),
+		fmt.Sprintf("%v rows loaded", values.RowsLoaded),
+		fmt.Sprintf("%v pipe files written", values.PipeFilesWritten),
+		fmt.Sprintf("%v files loaded", values.FilesLoaded),
+		fmt.Sprintf("%.0f rows/s", values.RowsPerSecond),
+	}
+
+	if values.EstimatedRows > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f%% of about %v rows", values.PercentComplete, values.EstimatedRows))
+	}
+
+	if values.EstimatedSecondsRemaining > 0 {
+		parts = append(parts, fmt.Sprintf("about %v remaining", (time.Duration(values.EstimatedSecondsRemaining)*time.Second).String()))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (progress *TransferProgress) MarshalJSON() ([]byte, error) {
+	return json.Marshal(progress.snapshot())
+}
+
+func (progress *TransferProgress) UnmarshalJSON(data []byte) error {
+	// derived values are worked out again from the counts when the progress is shown
+	var values transferProgressValues
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+	values.RowsPerSecond = 0
+	values.PercentComplete = 0
+	values.EstimatedSecondsRemaining = 0
+	progress.values = values
+	return nil
+}
+
+const cliProgressInterval = 10 * time.Second
+
+func logProgress(interval time.Duration) (stop func()) {
+	// periodically logs the progress of running transfers, for cli transfers where there's
+	// no api to ask. schema transfers report no progress themselves, this logs each running child
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, transfer := range transferMap.GetAll() {
+					if transfer.Status == StatusRunning && transfer.Progress != nil {
+						infoLog.Printf("transfer %v progress :: %v", transfer.Id, transfer.Progress.summary())
+					}
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func estimateRows(ctx context.Context, transfer Transfer, query string, filtered bool, source System) {
+	// estimates how many rows a table transfer will move, so progress can show a percentage
+	// and an eta. the catalog's estimate is used for whole tables, and filtered reads are counted
+
+	if transfer.SourceTable == "" {
+		return
+	}
+
+	if !filtered {
+		rowEstimate, ok, err := getRowEstimate(transfer.SourceSchema, transfer.SourceTable, source)
+		if err != nil {
+			infoLog.Printf("transfer %v could not get a row estimate from the catalog, counting rows instead :: %v", transfer.Id, err)
+		}
+		if ok {
+			transfer.Progress.setEstimatedRows(rowEstimate)
+			return
+		}
+	}
+
+	countQuery := strings.Replace(query, "SELECT *", "SELECT COUNT(*)", 1)
+
+	var rowCount int64
+	err := source.queryRowContext(ctx, countQuery).Scan(&rowCount)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the transfer finished or was cancelled before the count did
+			return
+		}
+		infoLog.Printf("transfer %v could not count source rows, progress won't have an estimate :: %v", transfer.Id, err)
+		return
+	}
+
+	transfer.Progress.setEstimatedRows(rowCount)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// transfers wait in the queue until a slot is free. there's a limit on how many transfers run
+// at once, and optional limits on how many use the same source host or target host, so a
+// database doesn't get more extracts or loads than it can take. higher priority transfers are
+// let in first, then the oldest. a transfer held back by its hosts doesn't hold up the ones
+// behind it. change data capture transfers run until they're cancelled, so rather than hold
+// a slot forever they only count against their own limit. zero means no limit
+
+var (
+	maxConcurrentTransfers    int
+	maxTransfersPerSourceHost int
+	maxTransfersPerTargetHost int
+	maxCdcTransfers           int
+
+	hostSettingRegex = regexp.MustCompile(`(?i)(?:^|[;\s])(?:server|host|hostname|data source|address|addr)\s*=\s*(?:tcp:)?([^;\s,\\]+)`)
+	dsnHostRegex     = regexp.MustCompile(`@(?:tcp\()?([^@:/?()]+)[^@]*$`)
+)
+
+var transferQueue = newTransferQueue()
+
+type TransferQueue struct {
+	mu          sync.Mutex
+	waiting     []*queuedTransfer
+	running     int
+	cdcRunning  int
+	sourceHosts map[string]int
+	targetHosts map[string]int
+	sequence    uint64
+}
+
+type queuedTransfer struct {
+	priority   int
+	sequence   uint64
+	cdc        bool
+	sourceHost string
+	targetHost string
+	ready      chan struct{}
+}
+
+func newTransferQueue() *TransferQueue {
+	return &TransferQueue{
+		sourceHosts: make(map[string]int),
+		targetHosts: make(map[string]int),
+	}
+}
+
+func (q *TransferQueue) acquire(transfer Transfer) (release func(), err error) {
+	// waits for a slot, or for the transfer to be cancelled while it's queued
+
+	entry := &queuedTransfer{
+		priority:   transfer.Priority,
+		cdc:        transfer.Cdc,
+		sourceHost: getConnectionHost(transfer.SourceConnectionInfo),
+		targetHost: getConnectionHost(transfer.TargetConnectionInfo),
+		ready:      make(chan struct{}),
+	}
+
+	release = func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.release(entry)
+	}
+
+	q.mu.Lock()
+	q.sequence++
+	entry.sequence = q.sequence
+	q.waiting = append(q.waiting, entry)
+	q.admit()
+	waiting := len(q.waiting)
+	running, kind := q.running, "transfers"
+	if entry.cdc {
+		running, kind = q.cdcRunning, "change data capture transfers"
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return release, nil
+	default:
+		infoLog.Printf("transfer %v is queued, %v %v are running and %v transfers are queued", transfer.Id, running, kind, waiting)
+	}
+
+	select {
+	case <-entry.ready:
+		return release, nil
+	case <-transfer.Context.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		// let in as it was cancelled, so the slot is given back
+		q.release(entry)
+	default:
+		for i := range q.waiting {
+			if q.waiting[i] == entry {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil, transfer.Context.Err()
+}
+
+func (q *TransferQueue) admit() {
+	// lets in every waiting transfer there's a slot for. the caller holds the lock
+
+	sort.SliceStable(q.waiting, func(i, j int) bool {
+		if q.waiting[i].priority != q.waiting[j].priority {
+			return q.waiting[i].priority > q.waiting[j].priority
+		}
+		return q.waiting[i].sequence < q.waiting[j].sequence
+	})
+
+	waiting := []*queuedTransfer{}
+
+	for _, entry := range q.waiting {
+		if !q.canRun(entry) {
+			waiting = append(waiting, entry)
+			continue
+		}
+		if entry.cdc {
+			q.cdcRunning++
+		} else {
+			q.running++
+			q.sourceHosts[entry.sourceHost]++
+			q.targetHosts[entry.targetHost]++
+		}
+		close(entry.ready)
+	}
+
+	q.waiting = waiting
+}
+
+func (q *TransferQueue) release(entry *queuedTransfer) {
+	// frees a running transfer's slot and lets in whoever can use it. the caller holds the lock
+	if entry.cdc {
+		q.cdcRunning--
+		q.admit()
+		return
+	}
+
+	q.running--
+	q.sourceHosts[entry.sourceHost]--
+	if q.sourceHosts[entry.sourceHost] == 0 {
+		delete(q.sourceHosts, entry.sourceHost)
+	}
+	q.targetHosts[entry.targetHost]--
+	if q.targetHosts[entry.targetHost] == 0 {
+		delete(q.targetHosts, entry.targetHost)
+	}
+	q.admit()
+}
+
+func (q *TransferQueue) canRun(entry *queuedTransfer) bool {
+	if entry.cdc {
+		return maxCdcTransfers == 0 || q.cdcRunning < maxCdcTransfers
+	}
+	return (maxConcurrentTransfers == 0 || q.running < maxConcurrentTransfers) &&
+		(maxTransfersPerSourceHost == 0 || q.sourceHosts[entry.sourceHost] < maxTransfersPerSourceHost) &&
+		(maxTransfersPerTargetHost == 0 || q.targetHosts[entry.targetHost] < maxTransfersPerTargetHost)
+}
+
+func getConnectionHost(connectionInfo ConnectionInfo) string {
+	// finds the host in a url, a key value connection string, or a dsn like mysql's and
+	// snowflake's. file systems don't have one, so their path is used
+
+	connectionString := connectionInfo.ConnectionString
+
+	parsedUrl, err := url.Parse(connectionString)
+	if err == nil && parsedUrl.Hostname() != "" {
+		return strings.ToLower(parsedUrl.Hostname())
+	}
+
+	match := hostSettingRegex.FindStringSubmatch(connectionString)
+	if match != nil {
+		return strings.ToLower(match[1])
+	}
+
+	match = dsnHostRegex.FindStringSubmatch(connectionString)
+	if match != nil {
+		return strings.ToLower(match[1])
+	}
+
+	return connectionString
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func routes() http.Handler {
@@ -20,8 +21,25 @@ func routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/transfers/show/:id", showTransferHandler)
 	router.HandlerFunc(http.MethodGet, "/transfers/list", listTransfersHandler)
 	router.HandlerFunc(http.MethodPatch, "/transfers/cancel/:id", cancelTransferHandler)
+	router.HandlerFunc(http.MethodGet, "/transfers/stream", streamTransfersHandler)
+	router.HandlerFunc(http.MethodGet, "/transfers/stream/:id", streamTransferHandler)
+
+	router.HandlerFunc(http.MethodPost, "/connections/create", createConnectionHandler)
+	router.HandlerFunc(http.MethodGet, "/connections/show/:name", showConnectionHandler)
+	router.HandlerFunc(http.MethodGet, "/connections/list", listConnectionsHandler)
+	router.HandlerFunc(http.MethodPatch, "/connections/update/:name", updateConnectionHandler)
+	router.HandlerFunc(http.MethodDelete, "/connections/delete/:name", deleteConnectionHandler)
+
+	router.HandlerFunc(http.MethodPost, "/schedules/create", createScheduleHandler)
+	router.HandlerFunc(http.MethodGet, "/schedules/show/:name", showScheduleHandler)
+	router.HandlerFunc(http.MethodGet, "/schedules/list", listSchedulesHandler)
+	router.HandlerFunc(http.MethodPatch, "/schedules/update/:name", updateScheduleHandler)
+	router.HandlerFunc(http.MethodDelete, "/schedules/delete/:name", deleteScheduleHandler)
+
+	router.HandlerFunc(http.MethodPost, "/cdc/oracle/uninstall", uninstallOracleTrackingHandler)
 
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.Handler(http.MethodGet, "/metrics", promhttp.Handler())
 
 	return recoverPanic(router)
 }
@@ -0,0 +1,706 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/robfig/cron/v3"
+)
+
+// schedules create a transfer from a template each time they fire, on a cron expression or
+// an interval. a schedule that fires while its last transfer hasn't stopped skips the run, or
+// queues it to start when that transfer stops, and every run's transfer and outcome is kept
+// with the schedule. templates can hold connection strings, so schedules are encrypted with
+// the same key as stored connections
+
+var (
+	scheduleRunsKept    = 100
+	minScheduleInterval = time.Second
+)
+
+type Schedule struct {
+	Name      string        `json:"name"`
+	Cron      string        `json:"cron,omitempty"`
+	Interval  string        `json:"interval,omitempty"`
+	Overlap   string        `json:"overlap"`
+	Paused    bool          `json:"paused"`
+	Transfer  TransferInput `json:"transfer"`
+	NextRunAt *time.Time    `json:"next-run-at,omitempty"`
+	QueuedAt  *time.Time    `json:"queued-at,omitempty"`
+	Runs      []ScheduleRun `json:"runs"`
+	CreatedAt time.Time     `json:"created-at"`
+	UpdatedAt time.Time     `json:"updated-at"`
+
+	// the cron entry that fires the schedule, and the transfer of the run that hasn't stopped
+	entryId        cron.EntryID
+	activeTransfer string
+}
+
+type ScheduleRun struct {
+	FiredAt    time.Time `json:"fired-at"`
+	TransferId string    `json:"transfer-id,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StoppedAt  string    `json:"stopped-at,omitempty"`
+}
+
+func (run *ScheduleRun) update(transfer Transfer) {
+	run.Status = transfer.Status
+	run.Error = transfer.Error
+	run.StoppedAt = transfer.StoppedAt
+}
+
+func (schedule *Schedule) addRun(run ScheduleRun) {
+	schedule.Runs = append(schedule.Runs, run)
+	if len(schedule.Runs) > scheduleRunsKept {
+		schedule.Runs = schedule.Runs[len(schedule.Runs)-scheduleRunsKept:]
+	}
+}
+
+func (schedule Schedule) getCronSchedule() (cron.Schedule, error) {
+
+	if schedule.Cron != "" {
+		return cron.ParseStandard(schedule.Cron)
+	}
+
+	interval, err := time.ParseDuration(schedule.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return cron.Every(interval), nil
+}
+
+func (schedule Schedule) redacted() Schedule {
+	schedule.Transfer = schedule.Transfer.redacted()
+	return schedule
+}
+
+func (input TransferInput) redacted() TransferInput {
+	input.SourceConnectionString = redactConnectionString(input.SourceConnectionString)
+	input.TargetConnectionString = redactConnectionString(input.TargetConnectionString)
+	input.StagingUrl = redactConnectionString(input.StagingUrl)
+	if input.TargetPassword != "" {
+		input.TargetPassword = redactedSecret
+	}
+	return input
+}
+
+func (input TransferInput) getSecrets() (secrets []string) {
+	secrets = append(secrets, getConnectionStringSecrets(input.SourceConnectionString)...)
+	secrets = append(secrets, getConnectionStringSecrets(input.TargetConnectionString)...)
+	secrets = append(secrets, getConnectionStringSecrets(input.StagingUrl)...)
+	return append(secrets, input.TargetPassword)
+}
+
+func sealSchedule(key []byte, schedule Schedule) (sealed []byte, err error) {
+	// schedules are authenticated with their name, like connections, and a prefix that keeps a
+	// sealed schedule from passing as a connection of the same name
+
+	plaintext, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling schedule :: %v", err)
+	}
+
+	return seal(key, plaintext, []byte("schedules/"+schedule.Name))
+}
+
+func openSchedule(key []byte, name string, sealed []byte) (schedule Schedule, err error) {
+
+	plaintext, err := unseal(key, sealed, []byte("schedules/"+name))
+	if err != nil {
+		return schedule, fmt.Errorf("error opening schedule :: %v", err)
+	}
+
+	err = json.Unmarshal(plaintext, &schedule)
+	if err != nil {
+		return schedule, fmt.Errorf("error unmarshalling schedule :: %v", err)
+	}
+
+	return schedule, nil
+}
+
+// ******************
+// ** schedule map **
+// ******************
+
+var scheduleMap = NewSafeScheduleMap()
+
+type SafeScheduleMap struct {
+	mu        sync.Mutex
+	m         map[string]Schedule
+	store     TransferStore
+	key       []byte
+	scheduler *cron.Cron
+}
+
+func NewSafeScheduleMap() *SafeScheduleMap {
+	return &SafeScheduleMap{
+		m:         make(map[string]Schedule),
+		store:     newMemoryTransferStore(),
+		scheduler: cron.New(),
+	}
+}
+
+func (sm *SafeScheduleMap) LoadStore(store TransferStore, key []byte) (err error) {
+	// loads schedules from a store and starts firing them. runs that hadn't stopped when
+	// sqlpipe stopped get their outcome from the transfer map, so it must be loaded first.
+	// a queued run doesn't survive a restart, the schedule fires again on time
+
+	sealedSchedules, err := store.getSchedules()
+	if err != nil {
+		return fmt.Errorf("error getting schedules from store :: %v", err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.store = store
+	sm.key = key
+
+	defer sm.scheduler.Start()
+
+	if key == nil {
+		if len(sealedSchedules) > 0 {
+			warningLog.Printf("%v stored schedules can't run until %v is set", len(sealedSchedules), connectionKeyEnv)
+		}
+		return nil
+	}
+
+	for name, sealed := range sealedSchedules {
+		schedule, err := openSchedule(key, name, sealed)
+		if err != nil {
+			warningLog.Printf("skipping stored schedule %v, it may have been stored with a different %v :: %v", name, connectionKeyEnv, err)
+			continue
+		}
+
+		for i, run := range schedule.Runs {
+			if run.Status != StatusQueued && run.Status != StatusRunning {
+				continue
+			}
+			transfer, ok := transferMap.Get(run.TransferId)
+			if !ok {
+				transfer.Status = StatusError
+				transfer.Error = fmt.Sprintf("sqlpipe stopped while transfer %v was %v", run.TransferId, run.Status)
+			}
+			schedule.Runs[i].update(transfer)
+		}
+		schedule.QueuedAt = nil
+
+		redactor.set("schedules/"+name, schedule.Transfer.getSecrets()...)
+		sm.schedule(&schedule)
+		sm.m[name] = schedule
+		sm.persist(schedule)
+	}
+
+	infoLog.Printf("loaded %v schedules from store", len(sm.m))
+
+	return nil
+}
+
+func (sm *SafeScheduleMap) schedule(schedule *Schedule) {
+	// replaces the schedule's cron entry, paused schedules don't get one
+
+	if schedule.entryId != 0 {
+		sm.scheduler.Remove(schedule.entryId)
+		schedule.entryId = 0
+	}
+
+	if schedule.Paused {
+		return
+	}
+
+	cronSchedule, err := schedule.getCronSchedule()
+	if err != nil {
+		errorLog.Printf("error scheduling %v :: %v", schedule.Name, err)
+		return
+	}
+
+	name := schedule.Name
+	schedule.entryId = sm.scheduler.Schedule(cronSchedule, cron.FuncJob(func() { sm.fire(name) }))
+}
+
+func (sm *SafeScheduleMap) persist(schedule Schedule) {
+	// persisting runs is best effort, like persisting transfers
+
+	sealed, err := sealSchedule(sm.key, schedule)
+	if err == nil {
+		err = sm.store.saveSchedule(schedule.Name, sealed)
+	}
+	if err != nil {
+		errorLog.Printf("error saving schedule %v to store :: %v", schedule.Name, err)
+	}
+}
+
+func (sm *SafeScheduleMap) fire(name string) {
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	schedule, ok := sm.m[name]
+	if !ok || schedule.Paused {
+		return
+	}
+
+	firedAt := time.Now()
+
+	schedule, start := schedule.fired(firedAt)
+	switch {
+	case start:
+		schedule = sm.startRun(schedule, firedAt)
+	case schedule.Overlap == ScheduleOverlapQueue:
+		infoLog.Printf("schedule %v queued a run until transfer %v stops", name, schedule.activeTransfer)
+	default:
+		warningLog.Printf("schedule %v skipped a run, transfer %v has not stopped", name, schedule.activeTransfer)
+	}
+
+	sm.m[name] = schedule
+	sm.persist(schedule)
+}
+
+func (schedule Schedule) fired(firedAt time.Time) (updated Schedule, start bool) {
+	// decides what a firing does. a run starts if the last transfer has stopped, otherwise
+	// it's queued or recorded as skipped, depending on the schedule's overlap
+
+	switch {
+	case schedule.activeTransfer == "":
+		return schedule, true
+	case schedule.Overlap == ScheduleOverlapQueue:
+		// firings while a run is queued are the same run, so they aren't queued again
+		if schedule.QueuedAt == nil {
+			schedule.QueuedAt = &firedAt
+		}
+	default:
+		schedule.addRun(ScheduleRun{
+			FiredAt:   firedAt,
+			Status:    ScheduleRunSkipped,
+			Error:     fmt.Sprintf("transfer %v had not stopped", schedule.activeTransfer),
+			StoppedAt: firedAt.Format(time.RFC3339Nano),
+		})
+	}
+
+	return schedule, false
+}
+
+func (sm *SafeScheduleMap) startRun(schedule Schedule, firedAt time.Time) Schedule {
+	// creates the schedule's transfer and runs it. the caller holds the lock and saves the schedule
+
+	run := ScheduleRun{FiredAt: firedAt}
+
+	v := newValidator()
+	transfer := newTransfer(v, schedule.Transfer)
+	transfer.ScheduleName = schedule.Name
+
+	var err error
+	if !v.valid() {
+		err = fmt.Errorf("error validating transfer :: %v", v.errors)
+	} else {
+		transfer, err = createTransfer(transfer)
+	}
+
+	if err != nil {
+		run.Status = StatusError
+		run.Error = redactor.redact(err.Error())
+		run.StoppedAt = time.Now().Format(time.RFC3339Nano)
+		schedule.addRun(run)
+		errorLog.Printf("schedule %v could not create a transfer :: %v", schedule.Name, run.Error)
+		return schedule
+	}
+
+	run.TransferId = transfer.Id
+	run.Status = transfer.Status
+	schedule.addRun(run)
+	schedule.activeTransfer = transfer.Id
+
+	infoLog.Printf(`schedule %v created transfer %v from %v to %v`,
+		schedule.Name, transfer.Id, transfer.SourceConnectionInfo.Name, transfer.TargetConnectionInfo.Name)
+
+	go func() {
+		executeTransfer(transfer)
+		sm.finishRun(schedule.Name, transfer.Id)
+	}()
+
+	return schedule
+}
+
+func (sm *SafeScheduleMap) finishRun(name, transferId string) {
+	// records the outcome of a schedule's transfer, and starts a run that was queued behind it
+
+	transfer, _ := transferMap.Get(transferId)
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	schedule, ok := sm.m[name]
+	if !ok {
+		return
+	}
+
+	for i := len(schedule.Runs) - 1; i >= 0; i-- {
+		if schedule.Runs[i].TransferId == transferId {
+			schedule.Runs[i].update(transfer)
+			break
+		}
+	}
+
+	if schedule.activeTransfer == transferId {
+		schedule.activeTransfer = ""
+	}
+
+	if schedule.QueuedAt != nil && schedule.activeTransfer == "" {
+		firedAt := *schedule.QueuedAt
+		schedule.QueuedAt = nil
+		schedule = sm.startRun(schedule, firedAt)
+	}
+
+	sm.m[name] = schedule
+	sm.persist(schedule)
+}
+
+func (sm *SafeScheduleMap) view(schedule Schedule) Schedule {
+	// a copy of the schedule with its next run, and the status of its transfer if it hasn't stopped
+
+	schedule.Runs = append([]ScheduleRun{}, schedule.Runs...)
+
+	for i := len(schedule.Runs) - 1; i >= 0 && schedule.activeTransfer != ""; i-- {
+		if schedule.Runs[i].TransferId == schedule.activeTransfer {
+			transfer, ok := transferMap.Get(schedule.activeTransfer)
+			if ok {
+				schedule.Runs[i].update(transfer)
+			}
+			break
+		}
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.entryId != 0 {
+		next := sm.scheduler.Entry(schedule.entryId).Next
+		if !next.IsZero() {
+			schedule.NextRunAt = &next
+		}
+	}
+
+	return schedule
+}
+
+func (sm *SafeScheduleMap) Get(name string) (Schedule, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	schedule, ok := sm.m[name]
+	if !ok {
+		return schedule, false
+	}
+	return sm.view(schedule), true
+}
+
+func (sm *SafeScheduleMap) GetRedactedMap() map[string]Schedule {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	schedules := make(map[string]Schedule, len(sm.m))
+	for name, schedule := range sm.m {
+		schedules[name] = sm.view(schedule).redacted()
+	}
+	return schedules
+}
+
+func (sm *SafeScheduleMap) Set(schedule Schedule) (err error) {
+	// creates or updates a schedule. its runs are kept from the stored copy, since they can
+	// change while a request is being handled
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.key == nil {
+		return fmt.Errorf("%v must be set to store schedules", connectionKeyEnv)
+	}
+
+	stored := sm.m[schedule.Name]
+	schedule.Runs = stored.Runs
+	schedule.QueuedAt = stored.QueuedAt
+	schedule.activeTransfer = stored.activeTransfer
+	schedule.entryId = stored.entryId
+	schedule.NextRunAt = nil
+	if schedule.Paused {
+		schedule.QueuedAt = nil
+	}
+
+	sealed, err := sealSchedule(sm.key, schedule)
+	if err != nil {
+		return err
+	}
+
+	err = sm.store.saveSchedule(schedule.Name, sealed)
+	if err != nil {
+		return fmt.Errorf("error saving schedule %v to store :: %v", schedule.Name, err)
+	}
+
+	redactor.set("schedules/"+schedule.Name, schedule.Transfer.getSecrets()...)
+	sm.schedule(&schedule)
+	sm.m[schedule.Name] = schedule
+
+	return nil
+}
+
+func (sm *SafeScheduleMap) Delete(name string) (err error) {
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	err = sm.store.deleteSchedule(name)
+	if err != nil {
+		return fmt.Errorf("error deleting schedule %v from store :: %v", name, err)
+	}
+
+	sm.scheduler.Remove(sm.m[name].entryId)
+	redactor.remove("schedules/" + name)
+	delete(sm.m, name)
+
+	return nil
+}
+
+func (sm *SafeScheduleMap) Stop() {
+	// waits for firings in progress, which take the lock, so it isn't held here
+	<-sm.scheduler.Stop().Done()
+}
+
+func (sm *SafeScheduleMap) CanStore() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.key != nil
+}
+
+// ****************
+// ** validation **
+// ****************
+
+func validateSchedule(v validator, schedule Schedule) {
+
+	v.check(schedule.Name != "", "name", "must be provided")
+	v.check(nameRegex.MatchString(schedule.Name), "name", "must only contain letters, numbers, underscores, periods, and dashes")
+	v.check(schedule.Cron != "" || schedule.Interval != "", "cron", "must be provided if interval is not")
+	v.check(schedule.Cron == "" || schedule.Interval == "", "interval", "must not be provided if cron is provided")
+
+	if schedule.Cron != "" {
+		_, err := cron.ParseStandard(schedule.Cron)
+		v.check(err == nil, "cron", fmt.Sprintf("must be a cron expression, like 0 2 * * * or @hourly :: %v", err))
+	}
+
+	if schedule.Interval != "" {
+		interval, err := time.ParseDuration(schedule.Interval)
+		v.check(err == nil, "interval", "must be a duration, like 30m or 6h")
+		v.check(err != nil || interval >= minScheduleInterval, "interval", fmt.Sprintf("must be at least %v", minScheduleInterval))
+	}
+
+	v.check(permittedValue(schedule.Overlap, ScheduleOverlaps...), "overlap", fmt.Sprintf("must be one of %v", ScheduleOverlaps))
+	v.check(!schedule.Transfer.Cdc, "transfer.cdc", "must not be true, cdc transfers run until they are cancelled")
+
+	// the template is checked the way each run's transfer will be
+	transferValidator := newValidator()
+	newTransfer(transferValidator, schedule.Transfer)
+	for key, message := range transferValidator.errors {
+		v.addError("transfer."+key, message)
+	}
+}
+
+// **************
+// ** handlers **
+// **************
+
+func createScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string        `json:"name"`
+		Cron     string        `json:"cron"`
+		Interval string        `json:"interval"`
+		Overlap  string        `json:"overlap"`
+		Paused   bool          `json:"paused"`
+		Transfer TransferInput `json:"transfer"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		clientErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if !scheduleMap.CanStore() {
+		clientErrorResponse(w, r, http.StatusServiceUnavailable, fmt.Errorf("%v must be set to store schedules", connectionKeyEnv))
+		return
+	}
+
+	if input.Overlap == "" {
+		input.Overlap = ScheduleOverlapSkip
+	}
+
+	schedule := Schedule{
+		Name:      input.Name,
+		Cron:      input.Cron,
+		Interval:  input.Interval,
+		Overlap:   input.Overlap,
+		Paused:    input.Paused,
+		Transfer:  input.Transfer,
+		Runs:      []ScheduleRun{},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	v := newValidator()
+	validateSchedule(v, schedule)
+
+	_, exists := scheduleMap.Get(schedule.Name)
+	v.check(!exists, "name", "a schedule with this name already exists")
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	err = scheduleMap.Set(schedule)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v created schedule %v", r.RemoteAddr, schedule.Name)
+
+	schedule, _ = scheduleMap.Get(schedule.Name)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/schedules/show/%s", schedule.Name))
+
+	err = writeJSON(w, http.StatusCreated, envelope{"schedule": schedule.redacted()}, headers)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func showScheduleHandler(w http.ResponseWriter, r *http.Request) {
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	schedule, ok := scheduleMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, envelope{"schedule": schedule.redacted()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func listSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+
+	err := writeJSON(w, http.StatusOK, envelope{"schedules": scheduleMap.GetRedactedMap()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func updateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// fields left out of the body keep their stored values. a transfer replaces the whole
+	// template, so its connection strings must be given in full, not as shown
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	schedule, ok := scheduleMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Cron     *string        `json:"cron"`
+		Interval *string        `json:"interval"`
+		Overlap  *string        `json:"overlap"`
+		Paused   *bool          `json:"paused"`
+		Transfer *TransferInput `json:"transfer"`
+	}
+
+	err := readJSON(w, r, &input)
+	if err != nil {
+		clientErrorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// a schedule fires on a cron expression or an interval, so setting one clears the other
+	if input.Cron != nil {
+		schedule.Cron = *input.Cron
+		if schedule.Cron != "" && input.Interval == nil {
+			schedule.Interval = ""
+		}
+	}
+	if input.Interval != nil {
+		schedule.Interval = *input.Interval
+		if schedule.Interval != "" && input.Cron == nil {
+			schedule.Cron = ""
+		}
+	}
+	if input.Overlap != nil {
+		schedule.Overlap = *input.Overlap
+	}
+	if input.Paused != nil {
+		schedule.Paused = *input.Paused
+	}
+	if input.Transfer != nil {
+		schedule.Transfer = *input.Transfer
+	}
+	schedule.UpdatedAt = time.Now()
+
+	v := newValidator()
+	validateSchedule(v, schedule)
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	err = scheduleMap.Set(schedule)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v updated schedule %v", r.RemoteAddr, schedule.Name)
+
+	schedule, _ = scheduleMap.Get(schedule.Name)
+
+	err = writeJSON(w, http.StatusOK, envelope{"schedule": schedule.redacted()}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func deleteScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	// a transfer the schedule already started keeps running
+
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	_, ok := scheduleMap.Get(name)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	err := scheduleMap.Delete(name)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf("ip %v deleted schedule %v", r.RemoteAddr, name)
+
+	err = writeJSON(w, http.StatusOK, envelope{"message": fmt.Sprintf("schedule %v deleted", name)}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
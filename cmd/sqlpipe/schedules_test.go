@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleFired(t *testing.T) {
+	firedAt := time.Date(2023, 11, 2, 10, 15, 0, 0, time.UTC)
+	queuedAt := firedAt.Add(-time.Minute)
+
+	tests := []struct {
+		name           string
+		overlap        string
+		activeTransfer string
+		queuedAt       *time.Time
+		wantStart      bool
+		wantQueuedAt   *time.Time
+		wantSkipped    bool
+	}{
+		{"skip with no transfer running", ScheduleOverlapSkip, "", nil, true, nil, false},
+		{"queue with no transfer running", ScheduleOverlapQueue, "", nil, true, nil, false},
+		{"skip with a transfer running", ScheduleOverlapSkip, "transfer-1", nil, false, nil, true},
+		{"queue with a transfer running", ScheduleOverlapQueue, "transfer-1", nil, false, &firedAt, false},
+		{"queue with a run already queued", ScheduleOverlapQueue, "transfer-1", &queuedAt, false, &queuedAt, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule := Schedule{
+				Name:           "nightly",
+				Overlap:        test.overlap,
+				QueuedAt:       test.queuedAt,
+				activeTransfer: test.activeTransfer,
+			}
+
+			got, start := schedule.fired(firedAt)
+
+			if start != test.wantStart {
+				t.Fatalf("got start %v, want %v", start, test.wantStart)
+			}
+			if (got.QueuedAt == nil) != (test.wantQueuedAt == nil) || got.QueuedAt != nil && !got.QueuedAt.Equal(*test.wantQueuedAt) {
+				t.Fatalf("got queued at %v, want %v", got.QueuedAt, test.wantQueuedAt)
+			}
+			if got.activeTransfer != test.activeTransfer {
+				t.Fatalf("got active transfer %v, want %v", got.activeTransfer, test.activeTransfer)
+			}
+
+			if !test.wantSkipped {
+				if len(got.Runs) != 0 {
+					t.Fatalf("got runs %+v, want none", got.Runs)
+				}
+				return
+			}
+
+			if len(got.Runs) != 1 {
+				t.Fatalf("got %v runs, want 1", len(got.Runs))
+			}
+			run := got.Runs[0]
+			if run.Status != ScheduleRunSkipped || !run.FiredAt.Equal(firedAt) || run.TransferId != "" {
+				t.Fatalf("got run %+v, want a skipped run fired at %v", run, firedAt)
+			}
+		})
+	}
+}
+
+func TestScheduleFiredKeepsRunLimit(t *testing.T) {
+	schedule := Schedule{Overlap: ScheduleOverlapSkip, activeTransfer: "transfer-1"}
+
+	start := time.Date(2023, 11, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < scheduleRunsKept+5; i++ {
+		schedule, _ = schedule.fired(start.Add(time.Duration(i) * time.Minute))
+	}
+
+	if len(schedule.Runs) != scheduleRunsKept {
+		t.Fatalf("got %v runs, want %v", len(schedule.Runs), scheduleRunsKept)
+	}
+	if want := start.Add(5 * time.Minute); !schedule.Runs[0].FiredAt.Equal(want) {
+		t.Fatalf("oldest run fired at %v, want %v", schedule.Runs[0].FiredAt, want)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// schema transfers move this many tables at once, unless concurrent-tables says otherwise
+const defaultConcurrentTables = 4
+
+func runSchemaTransfer(transfer Transfer) (err error) {
+	// moves every table in the source schema that matches the include and exclude patterns.
+	// each table gets its own child transfer, which waits in the queue like any other, and
+	// the parent tracks how the children did
+
+	// the parent doesn't move rows itself, progress is reported by each child
+	transfer.Progress = nil
+	transfer = transferMap.SetStatus(transfer.Id, StatusRunning, transfer)
+
+	source, err := newSystem(transfer.SourceConnectionInfo)
+	if err != nil {
+		return fmt.Errorf("error creating source system :: %v", err)
+	}
+
+	allTables, err := getTables(transfer.SourceSchema, source)
+	source.closeConnectionPool(true)
+	if err != nil {
+		return fmt.Errorf("error getting source tables :: %v", err)
+	}
+
+	tables := filterTables(allTables, transfer.IncludeTables, transfer.ExcludeTables)
+	if len(tables) == 0 {
+		return fmt.Errorf("none of the %v tables in schema %v matched include-tables and exclude-tables", len(allTables), transfer.SourceSchema)
+	}
+
+	concurrentTables := transfer.ConcurrentTables
+	if concurrentTables == 0 {
+		concurrentTables = defaultConcurrentTables
+	}
+	// a database file can only be written by one load at a time
+	if singleWriterTargets[transfer.TargetConnectionInfo.Type] {
+		concurrentTables = 1
+	}
+
+	infoLog.Printf("transfer %v moving %v of %v tables in schema %v, %v at a time", transfer.Id, len(tables), len(allTables), transfer.SourceSchema, concurrentTables)
+
+	// a child is only created once there's room for it, so a cancel skips the tables left
+	tableSlots := make(chan struct{}, concurrentTables)
+	wg := sync.WaitGroup{}
+
+	for _, table := range tables {
+
+		select {
+		case tableSlots <- struct{}{}:
+		case <-transfer.Context.Done():
+		}
+
+		if transfer.Context.Err() != nil {
+			break
+		}
+
+		child, childErr := newChildTransfer(transfer, table)
+		if childErr != nil {
+			err = fmt.Errorf("error creating child transfer for table %v :: %v", table, childErr)
+			break
+		}
+
+		// updated in place, so a cancel, or the other children, aren't overwritten
+		transferMap.Update(transfer.Id, func(current *Transfer) {
+			current.ChildIds = append(current.ChildIds, child.Id)
+		})
+		transferMap.Set(child.Id, child)
+
+		infoLog.Printf("transfer %v started child transfer %v for table %v", transfer.Id, child.Id, table)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-tableSlots }()
+
+			runChildTransfer(child)
+
+			finished, _ := transferMap.Get(child.Id)
+
+			transferMap.Update(transfer.Id, func(current *Transfer) {
+				// a new map each time, so copies of the transfer that are being read aren't modified
+				childStatusCounts := map[string]int{}
+				for status, count := range current.ChildStatusCounts {
+					childStatusCounts[status] = count
+				}
+				childStatusCounts[finished.Status]++
+				current.ChildStatusCounts = childStatusCounts
+			})
+		}()
+	}
+
+	wg.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	if transfer.Context.Err() != nil {
+		return nil
+	}
+
+	transfer, _ = transferMap.Get(transfer.Id)
+
+	failed := len(tables) - transfer.ChildStatusCounts[StatusComplete]
+	if failed > 0 {
+		return fmt.Errorf("%v of %v child transfers did not complete", failed, len(tables))
+	}
+
+	transferMap.SetStatus(transfer.Id, StatusComplete, transfer)
+	infoLog.Printf("transfer %v complete", transfer.Id)
+
+	return nil
+}
+
+func filterTables(tables, includeTables, excludeTables []string) (filteredTables []string) {
+	// patterns are matched without regard to case, since some systems uppercase table names
+
+	filteredTables = []string{}
+
+	for _, table := range tables {
+		if matchesAnyPattern(table, includeTables) && !matchesAnyPattern(table, excludeTables) {
+			filteredTables = append(filteredTables, table)
+		}
+	}
+
+	return filteredTables
+}
+
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func newChildTransfer(parent Transfer, table string) (child Transfer, err error) {
+
+	id := uuid.New().String()
+
+	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs(id)
+	if err != nil {
+		return child, fmt.Errorf("error creating transfer tmp dirs :: %v", err)
+	}
+
+	// cancelling the parent cancels the child that is running
+	ctx, cancel := context.WithCancel(parent.Context)
+
+	child = parent
+	child.Id = id
+	child.CreatedAt = time.Now()
+	child.StoppedAt = ""
+	child.Status = StatusQueued
+	child.Error = ""
+	child.TmpDir = tmpDir
+	child.PipeFileDir = pipeFileDir
+	child.FinalCsvDir = finalCsvDir
+	child.Context = ctx
+	child.Cancel = cancel
+	child.SourceTable = table
+	child.TargetTable = table
+	child.IncludeTables = nil
+	child.ExcludeTables = nil
+	child.ParentId = parent.Id
+	child.ChildIds = nil
+	child.ChildStatusCounts = nil
+	child.ConcurrentTables = 0
+	child.Progress = newTransferProgress()
+
+	return child, nil
+}
+
+func runChildTransfer(child Transfer) {
+
+	if !child.KeepFiles {
+		defer func() {
+			err := os.RemoveAll(child.TmpDir)
+			if err != nil {
+				errorLog.Printf("error removing temp dir %v :: %v", child.TmpDir, err)
+				return
+			}
+			infoLog.Printf("temp dir %v removed", child.TmpDir)
+		}()
+	}
+
+	release, err := transferQueue.acquire(child)
+	if err != nil {
+		// the parent was cancelled while this child was queued
+		current, _ := transferMap.Get(child.Id)
+		transferMap.CancelAndSetStatus(child.Id, current, StatusCancelled)
+		return
+	}
+	defer release()
+
+	err = runTransfer(child)
+	if err != nil {
+		current, set := transferMap.SetErrorUnlessStopped(child.Id, fmt.Sprintf("error running transfer %v :: %v", child.Id, err))
+		if set {
+			errorLog.Println(current.Error)
+			return
+		}
+	}
+
+	current, _ := transferMap.Get(child.Id)
+
+	switch {
+	case current.Status == StatusRunning:
+		// the parent was cancelled while this child was running
+		transferMap.CancelAndSetStatus(child.Id, current, StatusCancelled)
+	default:
+		child.Cancel()
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterTables(t *testing.T) {
+	tables := []string{"orders", "ORDER_ITEMS", "Order_Archive", "customers", "tmp_orders", "TMP_LOAD", "order1"}
+
+	tests := []struct {
+		name          string
+		includeTables []string
+		excludeTables []string
+		want          []string
+	}{
+		{"everything", []string{"*"}, nil, tables},
+		{"no include patterns", nil, nil, []string{}},
+		{"exact name", []string{"customers"}, nil, []string{"customers"}},
+		{"lowercase pattern matches uppercase tables", []string{"order_*"}, nil, []string{"ORDER_ITEMS", "Order_Archive"}},
+		{"uppercase pattern matches lowercase tables", []string{"ORDERS", "CUSTOMER?"}, nil, []string{"orders", "customers"}},
+		{"single character wildcard", []string{"order?"}, nil, []string{"orders", "order1"}},
+		{"character class is lowercased too", []string{"[A-C]*"}, nil, []string{"customers"}},
+		{"negated character class", []string{"order[^_]"}, nil, []string{"orders", "order1"}},
+		{"escaped wildcard only matches itself", []string{`order\*`}, nil, []string{}},
+		{"exclude wins over include", []string{"*"}, []string{"tmp_*"}, []string{"orders", "ORDER_ITEMS", "Order_Archive", "customers", "order1"}},
+		{"exclude is case insensitive", []string{"*order*"}, []string{"TMP_*", "order_archive"}, []string{"orders", "ORDER_ITEMS", "order1"}},
+		{"several include patterns keep table order", []string{"tmp_*", "customers"}, nil, []string{"customers", "tmp_orders", "TMP_LOAD"}},
+		{"invalid pattern matches nothing", []string{"order["}, nil, []string{}},
+		{"pattern must match the whole name", []string{"order"}, nil, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := filterTables(tables, test.includeTables, test.excludeTables)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
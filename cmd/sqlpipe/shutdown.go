@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// on sigterm or sigint the api server stops creating transfers and firing schedules. queued
+// transfers are cancelled right away, since they haven't started, and so are cdc transfers,
+// which never finish and can resume from the position they recorded. running transfers get
+// the grace period to finish, then they're cancelled too. transfers stopped this way are
+// cancelled with the reason, and their temp dirs are removed before the server stops
+
+var (
+	shutdownGracePeriod time.Duration
+	shutdownCleanupWait = 10 * time.Second
+	errShuttingDown     = errors.New("sqlpipe is shutting down")
+)
+
+var activeTransfers = &ActiveTransfers{}
+
+type ActiveTransfers struct {
+	mu           sync.Mutex
+	shuttingDown bool
+	wg           sync.WaitGroup
+}
+
+func (a *ActiveTransfers) add() (err error) {
+	// a transfer is active from when it's created until executeTransfer returns
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.shuttingDown {
+		return errShuttingDown
+	}
+	a.wg.Add(1)
+	return nil
+}
+
+func (a *ActiveTransfers) done() {
+	a.wg.Done()
+}
+
+func (a *ActiveTransfers) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.shuttingDown = true
+}
+
+func (a *ActiveTransfers) wait(timeout time.Duration) (stopped bool) {
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func serveUntilShutdown(srv *http.Server) {
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorLog.Fatal(err)
+		}
+	}()
+
+	received := <-signals
+
+	// a second signal stops sqlpipe without waiting
+	signal.Stop(signals)
+
+	infoLog.Printf("received %v, shutting down", received)
+
+	shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		errorLog.Printf("error shutting down api server :: %v", err)
+	}
+
+	infoLog.Println("shut down")
+}
+
+func shutdown() {
+	// the api keeps serving while transfers stop, so their status can still be checked
+
+	activeTransfers.close()
+	scheduleMap.Stop()
+
+	unstopped := transferMap.GetUnstopped()
+
+	running := 0
+	for _, transfer := range unstopped {
+		switch {
+		case transfer.ParentId != "":
+			// child transfers stop with their parent
+		case transfer.Status == StatusQueued:
+			cancelForShutdown(transfer.Id, fmt.Sprintf("sqlpipe shut down before transfer %v started", transfer.Id))
+		case transfer.Cdc:
+			cancelForShutdown(transfer.Id, fmt.Sprintf("sqlpipe shut down while transfer %v was capturing changes", transfer.Id))
+		default:
+			running++
+		}
+	}
+
+	if running > 0 {
+		infoLog.Printf("waiting up to %v for %v running transfers to finish", shutdownGracePeriod, running)
+	}
+
+	if !activeTransfers.wait(shutdownGracePeriod) {
+		for _, transfer := range transferMap.GetUnstopped() {
+			cancelForShutdown(transfer.Id, fmt.Sprintf("sqlpipe shut down before transfer %v finished, after waiting %v", transfer.Id, shutdownGracePeriod))
+		}
+		if !activeTransfers.wait(shutdownCleanupWait) {
+			warningLog.Printf("cancelled transfers did not stop within %v", shutdownCleanupWait)
+		}
+	}
+
+	// transfers remove their own temp dirs when they stop, these are from ones that didn't
+	for _, transfer := range unstopped {
+		if transfer.KeepFiles || transfer.TmpDir == "" {
+			continue
+		}
+		_, err := os.Stat(transfer.TmpDir)
+		if err != nil {
+			continue
+		}
+		err = os.RemoveAll(transfer.TmpDir)
+		if err != nil {
+			errorLog.Printf("error removing temp dir %v :: %v", transfer.TmpDir, err)
+			continue
+		}
+		infoLog.Printf("temp dir %v removed", transfer.TmpDir)
+	}
+}
+
+func cancelForShutdown(id, reason string) {
+	// starts from the latest copy, and leaves transfers that stopped on their own alone
+
+	transfer, ok := transferMap.Get(id)
+	if !ok || (transfer.Status != StatusQueued && transfer.Status != StatusRunning) {
+		return
+	}
+
+	transfer.Error = reason
+	if transfer.Cdc && transfer.CdcPosition != "" {
+		transfer.Error += fmt.Sprintf(", it can resume from cdc-position %v", transfer.CdcPosition)
+	}
+
+	transferMap.CancelAndSetStatus(id, transfer, StatusCancelled)
+	warningLog.Println(transfer.Error)
+}
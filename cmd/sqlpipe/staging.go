@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// transfers with a staging url move each pipe file to the bucket as soon as it's written, and
+// download it again when the target is ready for it. reading the source isn't held back by a
+// slow target, and the pipe files waiting to be loaded take up space in the bucket instead of
+// on local disk. staged files are deleted once they're downloaded, unless keep-files is true
+
+var stagingQueueFiles = 1000
+
+func stagePipeFiles(pipeFilesIn <-chan PipeFileInfo, transfer Transfer) <-chan PipeFileInfo {
+
+	if transfer.StagingUrl == "" {
+		return pipeFilesIn
+	}
+
+	location, _, err := newS3Location(transfer.StagingUrl)
+	if err != nil {
+		transfer.Error = fmt.Sprintf("error opening staging location :: %v", err)
+		transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+		errorLog.Println(transfer.Error)
+		pipeFilesOut := make(chan PipeFileInfo)
+		close(pipeFilesOut)
+		return pipeFilesOut
+	}
+
+	// pipe files are only as far behind as the queue is long, each one about 10 mb
+	stagedPipeFiles := make(chan PipeFileInfo, stagingQueueFiles)
+	pipeFilesOut := make(chan PipeFileInfo)
+
+	getKey := func(localPath string) string {
+		return location.getKey(transfer.Id, "pipe-files", filepath.Base(localPath))
+	}
+
+	go func() {
+
+		defer close(stagedPipeFiles)
+
+		for pipeFileInfo := range pipeFilesIn {
+
+			for _, localPath := range []string{pipeFileInfo.FilePath, pipeFileInfo.PkFilePath} {
+				if localPath == "" {
+					continue
+				}
+
+				err := location.upload(transfer.Context, getKey(localPath), localPath)
+				if err != nil {
+					transfer.Error = fmt.Sprintf("error staging pipe file :: %v", err)
+					transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+					errorLog.Println(transfer.Error)
+					return
+				}
+
+				err = os.Remove(localPath)
+				if err != nil {
+					transfer.Error = fmt.Sprintf("error removing staged pipe file :: %v", err)
+					transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+					errorLog.Println(transfer.Error)
+					return
+				}
+			}
+
+			select {
+			case stagedPipeFiles <- pipeFileInfo:
+			case <-transfer.Context.Done():
+				return
+			}
+		}
+
+		infoLog.Printf("transfer %v finished staging pipe files in %v", transfer.Id, location.getUrl(transfer.Id))
+	}()
+
+	go func() {
+
+		defer close(pipeFilesOut)
+
+		// a transfer that stops early leaves files in the queue that nothing will download, so
+		// they're deleted here. the transfer's context is done by then, so it isn't used
+		defer func() {
+			for pipeFileInfo := range stagedPipeFiles {
+				for _, localPath := range []string{pipeFileInfo.FilePath, pipeFileInfo.PkFilePath} {
+					if localPath == "" || transfer.KeepFiles {
+						continue
+					}
+					err := location.delete(context.Background(), getKey(localPath))
+					if err != nil {
+						warningLog.Printf("transfer %v :: %v", transfer.Id, err)
+					}
+				}
+			}
+		}()
+
+		for pipeFileInfo := range stagedPipeFiles {
+
+			for _, localPath := range []string{pipeFileInfo.FilePath, pipeFileInfo.PkFilePath} {
+				if localPath == "" {
+					continue
+				}
+
+				found, err := location.download(transfer.Context, getKey(localPath), localPath)
+				if err == nil && !found {
+					err = fmt.Errorf("%v is missing from the staging location", location.getUrl(transfer.Id, "pipe-files", filepath.Base(localPath)))
+				}
+				if err != nil {
+					transfer.Error = fmt.Sprintf("error downloading staged pipe file :: %v", err)
+					transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+					errorLog.Println(transfer.Error)
+					return
+				}
+
+				if !transfer.KeepFiles {
+					err = location.delete(transfer.Context, getKey(localPath))
+					if err != nil {
+						transfer.Error = fmt.Sprintf("error deleting staged pipe file :: %v", err)
+						transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+						errorLog.Println(transfer.Error)
+						return
+					}
+				}
+			}
+
+			select {
+			case pipeFilesOut <- pipeFileInfo:
+			case <-transfer.Context.Done():
+				return
+			}
+		}
+	}()
+
+	return pipeFilesOut
+}
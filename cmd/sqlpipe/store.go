@@ -0,0 +1,611 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type TransferStore interface {
+	// The transfer store persists transfers and their status changes, so that history
+	// survives a restart of the api server. It also keeps stored connections and schedules,
+	// which are encrypted before they reach it
+
+	saveTransfer(transfer Transfer) (err error)
+	saveStatusChange(statusChange StatusChange) (err error)
+	getTransfers() (transfers map[string]Transfer, err error)
+	getStatusChanges(transferId string) (statusChanges []StatusChange, err error)
+	createConnection(name string, sealedConnection []byte) (created bool, err error)
+	saveConnection(name string, sealedConnection []byte) (err error)
+	deleteConnection(name string) (err error)
+	getConnections() (sealedConnections map[string][]byte, err error)
+	saveSchedule(name string, sealedSchedule []byte) (err error)
+	deleteSchedule(name string) (err error)
+	getSchedules() (sealedSchedules map[string][]byte, err error)
+	close() (err error)
+}
+
+type StatusChange struct {
+	TransferId string    `json:"transfer-id"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	ChangedAt  time.Time `json:"changed-at"`
+}
+
+func newTransferStore(storeType, storePath, storeConnectionString string) (store TransferStore, err error) {
+	// creates a new transfer store
+
+	switch storeType {
+	case StoreTypeBolt:
+		return newBoltTransferStore(storePath)
+	case StoreTypePostgreSQL:
+		return newPostgresqlTransferStore(storeConnectionString)
+	case StoreTypeMemory:
+		return newMemoryTransferStore(), nil
+	default:
+		return store, fmt.Errorf("unsupported store type %v", storeType)
+	}
+}
+
+// ******************
+// ** memory store **
+// ******************
+
+type MemoryTransferStore struct {
+	mu            sync.Mutex
+	statusChanges map[string][]StatusChange
+	connections   map[string][]byte
+	schedules     map[string][]byte
+}
+
+func newMemoryTransferStore() *MemoryTransferStore {
+	return &MemoryTransferStore{
+		statusChanges: make(map[string][]StatusChange),
+		connections:   make(map[string][]byte),
+		schedules:     make(map[string][]byte),
+	}
+}
+
+func (store *MemoryTransferStore) saveTransfer(transfer Transfer) (err error) {
+	return nil
+}
+
+func (store *MemoryTransferStore) saveStatusChange(statusChange StatusChange) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.statusChanges[statusChange.TransferId] = append(store.statusChanges[statusChange.TransferId], statusChange)
+	return nil
+}
+
+func (store *MemoryTransferStore) getTransfers() (transfers map[string]Transfer, err error) {
+	return make(map[string]Transfer), nil
+}
+
+func (store *MemoryTransferStore) getStatusChanges(transferId string) (statusChanges []StatusChange, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return append([]StatusChange{}, store.statusChanges[transferId]...), nil
+}
+
+func (store *MemoryTransferStore) createConnection(name string, sealedConnection []byte) (created bool, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.connections[name]; ok {
+		return false, nil
+	}
+	store.connections[name] = sealedConnection
+	return true, nil
+}
+
+func (store *MemoryTransferStore) saveConnection(name string, sealedConnection []byte) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.connections[name] = sealedConnection
+	return nil
+}
+
+func (store *MemoryTransferStore) deleteConnection(name string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.connections, name)
+	return nil
+}
+
+func (store *MemoryTransferStore) getConnections() (sealedConnections map[string][]byte, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	sealedConnections = make(map[string][]byte, len(store.connections))
+	for name, sealedConnection := range store.connections {
+		sealedConnections[name] = sealedConnection
+	}
+	return sealedConnections, nil
+}
+
+func (store *MemoryTransferStore) saveSchedule(name string, sealedSchedule []byte) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.schedules[name] = sealedSchedule
+	return nil
+}
+
+func (store *MemoryTransferStore) deleteSchedule(name string) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.schedules, name)
+	return nil
+}
+
+func (store *MemoryTransferStore) getSchedules() (sealedSchedules map[string][]byte, err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	sealedSchedules = make(map[string][]byte, len(store.schedules))
+	for name, sealedSchedule := range store.schedules {
+		sealedSchedules[name] = sealedSchedule
+	}
+	return sealedSchedules, nil
+}
+
+func (store *MemoryTransferStore) close() (err error) {
+	return nil
+}
+
+// ****************
+// ** bolt store **
+// ****************
+
+var (
+	boltTransfersBucket     = []byte("transfers")
+	boltStatusChangesBucket = []byte("status-changes")
+	boltConnectionsBucket   = []byte("connections")
+	boltSchedulesBucket     = []byte("schedules")
+)
+
+type BoltTransferStore struct {
+	db *bolt.DB
+}
+
+func newBoltTransferStore(storePath string) (store BoltTransferStore, err error) {
+
+	db, err := bolt.Open(storePath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return store, fmt.Errorf("error opening bolt store %v :: %v", storePath, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltTransfersBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(boltStatusChangesBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(boltConnectionsBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(boltSchedulesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return store, fmt.Errorf("error creating bolt store buckets :: %v", err)
+	}
+
+	infoLog.Printf("opened bolt transfer store %v", storePath)
+
+	store.db = db
+	return store, nil
+}
+
+func (store BoltTransferStore) saveTransfer(transfer Transfer) (err error) {
+
+	transferJson, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("error marshalling transfer :: %v", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTransfersBucket).Put([]byte(transfer.Id), transferJson)
+	})
+}
+
+func (store BoltTransferStore) saveStatusChange(statusChange StatusChange) (err error) {
+
+	statusChangeJson, err := json.Marshal(statusChange)
+	if err != nil {
+		return fmt.Errorf("error marshalling status change :: %v", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltStatusChangesBucket)
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		// keys sort by transfer, then by the order the changes happened in
+		key := []byte(fmt.Sprintf("%v/%020d", statusChange.TransferId, seq))
+
+		return bucket.Put(key, statusChangeJson)
+	})
+}
+
+func (store BoltTransferStore) getTransfers() (transfers map[string]Transfer, err error) {
+
+	transfers = make(map[string]Transfer)
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTransfersBucket).ForEach(func(k, v []byte) error {
+			var transfer Transfer
+			err := json.Unmarshal(v, &transfer)
+			if err != nil {
+				return fmt.Errorf("error unmarshalling transfer %s :: %v", k, err)
+			}
+			transfers[transfer.Id] = transfer
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading transfers from bolt store :: %v", err)
+	}
+
+	return transfers, nil
+}
+
+func (store BoltTransferStore) getStatusChanges(transferId string) (statusChanges []StatusChange, err error) {
+
+	statusChanges = []StatusChange{}
+	prefix := []byte(transferId + "/")
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltStatusChangesBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var statusChange StatusChange
+			err := json.Unmarshal(v, &statusChange)
+			if err != nil {
+				return fmt.Errorf("error unmarshalling status change %s :: %v", k, err)
+			}
+			statusChanges = append(statusChanges, statusChange)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading status changes from bolt store :: %v", err)
+	}
+
+	return statusChanges, nil
+}
+
+func (store BoltTransferStore) createConnection(name string, sealedConnection []byte) (created bool, err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltConnectionsBucket)
+		if bucket.Get([]byte(name)) != nil {
+			return nil
+		}
+		created = true
+		return bucket.Put([]byte(name), sealedConnection)
+	})
+	return created, err
+}
+
+func (store BoltTransferStore) saveConnection(name string, sealedConnection []byte) (err error) {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltConnectionsBucket).Put([]byte(name), sealedConnection)
+	})
+}
+
+func (store BoltTransferStore) deleteConnection(name string) (err error) {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltConnectionsBucket).Delete([]byte(name))
+	})
+}
+
+func (store BoltTransferStore) getConnections() (sealedConnections map[string][]byte, err error) {
+
+	sealedConnections = make(map[string][]byte)
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltConnectionsBucket).ForEach(func(k, v []byte) error {
+			// bolt's values are only valid during the transaction
+			sealedConnections[string(k)] = append([]byte{}, v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading connections from bolt store :: %v", err)
+	}
+
+	return sealedConnections, nil
+}
+
+func (store BoltTransferStore) saveSchedule(name string, sealedSchedule []byte) (err error) {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedulesBucket).Put([]byte(name), sealedSchedule)
+	})
+}
+
+func (store BoltTransferStore) deleteSchedule(name string) (err error) {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedulesBucket).Delete([]byte(name))
+	})
+}
+
+func (store BoltTransferStore) getSchedules() (sealedSchedules map[string][]byte, err error) {
+
+	sealedSchedules = make(map[string][]byte)
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedulesBucket).ForEach(func(k, v []byte) error {
+			sealedSchedules[string(k)] = append([]byte{}, v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading schedules from bolt store :: %v", err)
+	}
+
+	return sealedSchedules, nil
+}
+
+func (store BoltTransferStore) close() (err error) {
+	return store.db.Close()
+}
+
+// **********************
+// ** postgresql store **
+// **********************
+
+type PostgresqlTransferStore struct {
+	db *sql.DB
+}
+
+func newPostgresqlTransferStore(connectionString string) (store PostgresqlTransferStore, err error) {
+
+	db, err := openConnectionPool("transfer store", connectionString, DriverPostgreSQL)
+	if err != nil {
+		return store, fmt.Errorf("error opening postgresql store :: %v", err)
+	}
+
+	_, err = db.Exec(`create table if not exists sqlpipe_transfers (
+		id text primary key,
+		transfer jsonb not null,
+		updated_at timestamptz not null
+	)`)
+	if err != nil {
+		db.Close()
+		return store, fmt.Errorf("error creating sqlpipe_transfers table :: %v", err)
+	}
+
+	_, err = db.Exec(`create table if not exists sqlpipe_status_changes (
+		id bigserial primary key,
+		transfer_id text not null,
+		status text not null,
+		error text not null,
+		changed_at timestamptz not null
+	)`)
+	if err != nil {
+		db.Close()
+		return store, fmt.Errorf("error creating sqlpipe_status_changes table :: %v", err)
+	}
+
+	_, err = db.Exec(`create table if not exists sqlpipe_connections (
+		name text primary key,
+		connection bytea not null,
+		updated_at timestamptz not null
+	)`)
+	if err != nil {
+		db.Close()
+		return store, fmt.Errorf("error creating sqlpipe_connections table :: %v", err)
+	}
+
+	_, err = db.Exec(`create table if not exists sqlpipe_schedules (
+		name text primary key,
+		schedule bytea not null,
+		updated_at timestamptz not null
+	)`)
+	if err != nil {
+		db.Close()
+		return store, fmt.Errorf("error creating sqlpipe_schedules table :: %v", err)
+	}
+
+	infoLog.Println("opened postgresql transfer store")
+
+	store.db = db
+	return store, nil
+}
+
+func (store PostgresqlTransferStore) saveTransfer(transfer Transfer) (err error) {
+
+	transferJson, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("error marshalling transfer :: %v", err)
+	}
+
+	_, err = store.db.Exec(`insert into sqlpipe_transfers (id, transfer, updated_at) values ($1, $2, $3)
+		on conflict (id) do update set transfer = excluded.transfer, updated_at = excluded.updated_at`,
+		transfer.Id, transferJson, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving transfer to postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) saveStatusChange(statusChange StatusChange) (err error) {
+
+	_, err = store.db.Exec(`insert into sqlpipe_status_changes (transfer_id, status, error, changed_at) values ($1, $2, $3, $4)`,
+		statusChange.TransferId, statusChange.Status, statusChange.Error, statusChange.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("error saving status change to postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) getTransfers() (transfers map[string]Transfer, err error) {
+
+	rows, err := store.db.Query(`select transfer from sqlpipe_transfers`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transfers from postgresql store :: %v", err)
+	}
+	defer rows.Close()
+
+	transfers = make(map[string]Transfer)
+
+	for rows.Next() {
+		var transferJson []byte
+		err = rows.Scan(&transferJson)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning transfer :: %v", err)
+		}
+
+		var transfer Transfer
+		err = json.Unmarshal(transferJson, &transfer)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling transfer :: %v", err)
+		}
+		transfers[transfer.Id] = transfer
+	}
+
+	return transfers, rows.Err()
+}
+
+func (store PostgresqlTransferStore) getStatusChanges(transferId string) (statusChanges []StatusChange, err error) {
+
+	rows, err := store.db.Query(`select transfer_id, status, error, changed_at from sqlpipe_status_changes where transfer_id = $1 order by id`, transferId)
+	if err != nil {
+		return nil, fmt.Errorf("error reading status changes from postgresql store :: %v", err)
+	}
+	defer rows.Close()
+
+	statusChanges = []StatusChange{}
+
+	for rows.Next() {
+		var statusChange StatusChange
+		err = rows.Scan(&statusChange.TransferId, &statusChange.Status, &statusChange.Error, &statusChange.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning status change :: %v", err)
+		}
+		statusChanges = append(statusChanges, statusChange)
+	}
+
+	return statusChanges, rows.Err()
+}
+
+func (store PostgresqlTransferStore) createConnection(name string, sealedConnection []byte) (created bool, err error) {
+
+	result, err := store.db.Exec(`insert into sqlpipe_connections (name, connection, updated_at) values ($1, $2, $3)
+		on conflict (name) do nothing`,
+		name, sealedConnection, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error creating connection in postgresql store :: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking created connection in postgresql store :: %v", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (store PostgresqlTransferStore) saveConnection(name string, sealedConnection []byte) (err error) {
+
+	_, err = store.db.Exec(`insert into sqlpipe_connections (name, connection, updated_at) values ($1, $2, $3)
+		on conflict (name) do update set connection = excluded.connection, updated_at = excluded.updated_at`,
+		name, sealedConnection, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving connection to postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) deleteConnection(name string) (err error) {
+
+	_, err = store.db.Exec(`delete from sqlpipe_connections where name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("error deleting connection from postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) getConnections() (sealedConnections map[string][]byte, err error) {
+
+	rows, err := store.db.Query(`select name, connection from sqlpipe_connections`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading connections from postgresql store :: %v", err)
+	}
+	defer rows.Close()
+
+	sealedConnections = make(map[string][]byte)
+
+	for rows.Next() {
+		var name string
+		var sealedConnection []byte
+		err = rows.Scan(&name, &sealedConnection)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning connection :: %v", err)
+		}
+		sealedConnections[name] = sealedConnection
+	}
+
+	return sealedConnections, rows.Err()
+}
+
+func (store PostgresqlTransferStore) saveSchedule(name string, sealedSchedule []byte) (err error) {
+
+	_, err = store.db.Exec(`insert into sqlpipe_schedules (name, schedule, updated_at) values ($1, $2, $3)
+		on conflict (name) do update set schedule = excluded.schedule, updated_at = excluded.updated_at`,
+		name, sealedSchedule, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving schedule to postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) deleteSchedule(name string) (err error) {
+
+	_, err = store.db.Exec(`delete from sqlpipe_schedules where name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("error deleting schedule from postgresql store :: %v", err)
+	}
+
+	return nil
+}
+
+func (store PostgresqlTransferStore) getSchedules() (sealedSchedules map[string][]byte, err error) {
+
+	rows, err := store.db.Query(`select name, schedule from sqlpipe_schedules`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schedules from postgresql store :: %v", err)
+	}
+	defer rows.Close()
+
+	sealedSchedules = make(map[string][]byte)
+
+	for rows.Next() {
+		var name string
+		var sealedSchedule []byte
+		err = rows.Scan(&name, &sealedSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning schedule :: %v", err)
+		}
+		sealedSchedules[name] = sealedSchedule
+	}
+
+	return sealedSchedules, rows.Err()
+}
+
+func (store PostgresqlTransferStore) close() (err error) {
+	return store.db.Close()
+}
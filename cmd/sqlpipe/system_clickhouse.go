@@ -0,0 +1,786 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Clickhouse struct {
+	Name        string
+	Connection  *sql.DB
+	TableEngine string
+	OrderBy     string
+}
+
+func (system Clickhouse) getSystemName() (name string) {
+	return system.Name
+}
+
+func newClickhouse(connectionInfo ConnectionInfo) (clickhouse Clickhouse, err error) {
+	db, err := openConnectionPool(connectionInfo.Name, connectionInfo.ConnectionString, DriverClickHouse)
+	if err != nil {
+		return clickhouse, fmt.Errorf("error opening clickhouse db :: %v", err)
+	}
+
+	clickhouse.Connection = db
+	clickhouse.Name = connectionInfo.Name
+	clickhouse.TableEngine = connectionInfo.TableEngine
+	clickhouse.OrderBy = connectionInfo.OrderBy
+
+	return clickhouse, nil
+}
+
+func (system Clickhouse) closeConnectionPool(printError bool) (err error) {
+	err = system.Connection.Close()
+	if err != nil && printError {
+		errorLog.Printf("error closing %v connection pool :: %v", system.Name, err)
+	}
+	return err
+}
+
+func (system Clickhouse) query(query string) (rows *sql.Rows, err error) {
+	rows, err = system.Connection.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.Name, query, err)
+	}
+	return rows, nil
+}
+
+func (system Clickhouse) queryRow(query string) (row *sql.Row) {
+	return system.Connection.QueryRow(query)
+}
+
+func (system Clickhouse) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	return system.Connection.QueryRowContext(ctx, query)
+}
+
+func (system Clickhouse) exec(query string) (err error) {
+	_, err = system.Connection.Exec(query)
+	if err != nil {
+		return fmt.Errorf("error running ddl/dml on %v :: %v :: %v", system.Name, query, err)
+	}
+	return nil
+}
+
+func (system Clickhouse) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Clickhouse) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Clickhouse) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	// clickhouse renames tables with rename table rather than alter table
+
+	query := fmt.Sprintf("rename table %v to %v",
+		getSchemaPeriodTable(schema, table, system, true),
+		getSchemaPeriodTable(schema, newTable, system, true),
+	)
+
+	err = system.exec(query)
+	if err != nil {
+		return true, fmt.Errorf("error renaming table %v to %v :: %v", table, newTable, err)
+	}
+
+	return true, nil
+}
+
+func (system Clickhouse) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// exchange tables swaps both tables at once in atomic databases, which are the default.
+	// afterwards the staging table holds the old rows, so it is dropped rather than oldTable
+
+	escapedSchemaPeriodStagingTable := getSchemaPeriodTable(schema, stagingTable, system, true)
+
+	err = system.exec(fmt.Sprintf("exchange tables %v and %v", escapedSchemaPeriodStagingTable, getSchemaPeriodTable(schema, table, system, true)))
+	if err != nil {
+		return true, fmt.Errorf("error exchanging tables :: %v", err)
+	}
+
+	err = system.exec(fmt.Sprintf("drop table %v", escapedSchemaPeriodStagingTable))
+	if err != nil {
+		return true, fmt.Errorf("error dropping old target table :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Clickhouse) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+	// clickhouse has no merge or on conflict, so target rows with staged primary keys are
+	// deleted and the staged rows inserted. readers can see the rows missing in between
+
+	escapedPrimaryKeys, escapedColumns, _ := getUpsertColumns(columnInfos, system)
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+	escapedSchemaPeriodStagingTable := getSchemaPeriodTable(schema, stagingTable, system, true)
+
+	err = system.exec(fmt.Sprintf("delete from %v where (%v) in (select %v from %v)",
+		escapedSchemaPeriodTable,
+		strings.Join(escapedPrimaryKeys, ", "),
+		strings.Join(escapedPrimaryKeys, ", "),
+		escapedSchemaPeriodStagingTable,
+	))
+	if err != nil {
+		return true, fmt.Errorf("error deleting upserted rows :: %v", err)
+	}
+
+	err = system.exec(fmt.Sprintf("insert into %v (%v) select %v from %v",
+		escapedSchemaPeriodTable,
+		strings.Join(escapedColumns, ", "),
+		strings.Join(escapedColumns, ", "),
+		escapedSchemaPeriodStagingTable,
+	))
+	if err != nil {
+		return true, fmt.Errorf("error inserting upserted rows :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Clickhouse) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+	// every clickhouse table needs an engine, and merge tree tables need a sorting key.
+	// tables are sorted by their primary key unless another order by is given, and columns
+	// in the sorting key can't be nullable
+
+	engine := system.TableEngine
+	if engine == "" {
+		engine = "MergeTree"
+	}
+
+	escapedPrimaryKeys := []string{}
+	for i := range columnInfos {
+		if columnInfos[i].IsPrimaryKey {
+			escapedPrimaryKeys = append(escapedPrimaryKeys, escapeIfNeeded(columnInfos[i].Name, system))
+		}
+	}
+
+	orderBy := system.OrderBy
+	if orderBy == "" {
+		orderBy = "tuple()"
+		if len(escapedPrimaryKeys) > 0 {
+			orderBy = fmt.Sprintf("(%v)", strings.Join(escapedPrimaryKeys, ", "))
+		}
+	}
+
+	sortingKeyWords := map[string]bool{}
+	for _, word := range strings.FieldsFunc(orderBy, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+	}) {
+		sortingKeyWords[word] = true
+	}
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	columns := []string{}
+	for i := range columnInfos {
+		createType, err := system.pipeTypeToCreateType(columnInfos[i])
+		if err != nil {
+			return true, fmt.Errorf("error getting create type for column %v :: %v", columnInfos[i].Name, err)
+		}
+
+		// columns are nullable unless the source says they aren't
+		notNull := (columnInfos[i].NullableOk && !columnInfos[i].Nullable) || columnInfos[i].IsPrimaryKey || sortingKeyWords[columnInfos[i].Name]
+		if !notNull {
+			createType = fmt.Sprintf("Nullable(%v)", createType)
+		}
+
+		columns = append(columns, fmt.Sprintf("%v %v", escapeIfNeeded(columnInfos[i].Name, system), createType))
+	}
+
+	query := fmt.Sprintf("create table if not exists %v (%v) engine = %v", escapedSchemaPeriodTable, strings.Join(columns, ", "), engine)
+	if strings.Contains(engine, "MergeTree") {
+		query = fmt.Sprintf("%v order by %v", query, orderBy)
+	}
+
+	err = system.exec(query)
+	if err != nil {
+		return true, fmt.Errorf("error running create table %v :: %v", escapedSchemaPeriodTable, err)
+	}
+
+	infoLog.Printf("created table %v if not exists in %v", escapedSchemaPeriodTable, system.getSystemName())
+
+	return true, nil
+}
+
+func (system Clickhouse) createSchemaIfNotExistsOverride(schema string) (overridden bool, err error) {
+	// clickhouse calls schemas databases
+
+	err = system.exec(fmt.Sprintf("create database if not exists %v", escapeIfNeeded(schema, system)))
+	if err != nil {
+		return true, fmt.Errorf("error creating database %v :: %v", schema, err)
+	}
+
+	return true, nil
+}
+
+func (system Clickhouse) dbTypeToPipeType(
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+
+	baseType := getClickhouseBaseType(databaseTypeName)
+	if i := strings.Index(baseType, "("); i >= 0 {
+		baseType = baseType[:i]
+	}
+
+	switch baseType {
+	case "Bool":
+		return "bool", nil
+	case "Int8", "UInt8", "Int16":
+		return "int16", nil
+	case "UInt16", "Int32":
+		return "int32", nil
+	case "UInt32", "Int64":
+		return "int64", nil
+	case "UInt64", "Int128", "UInt128", "Int256", "UInt256":
+		return "decimal", nil
+	case "Float32":
+		return "float32", nil
+	case "Float64":
+		return "float64", nil
+	case "Decimal", "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		return "decimal", nil
+	case "String", "FixedString", "Enum8", "Enum16", "IPv4", "IPv6":
+		return "ntext", nil
+	case "Date", "Date32":
+		return "date", nil
+	case "DateTime", "DateTime64":
+		return "datetimetz", nil
+	case "UUID":
+		return "uuid", nil
+	case "JSON", "Object":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("unsupported database type for clickhouse: %v", databaseTypeName)
+	}
+}
+
+func (system Clickhouse) driverTypeToPipeType(
+	columnType *sql.ColumnType,
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	return system.dbTypeToPipeType(databaseTypeName)
+}
+
+func (system Clickhouse) pipeTypeToCreateType(columnInfo ColumnInfo) (createType string, err error) {
+	switch columnInfo.PipeType {
+	case "nvarchar":
+		return "String", nil
+	case "varchar":
+		return "String", nil
+	case "ntext":
+		return "String", nil
+	case "text":
+		return "String", nil
+	case "int64":
+		return "Int64", nil
+	case "int32":
+		return "Int32", nil
+	case "int16":
+		return "Int16", nil
+	case "float64":
+		return "Float64", nil
+	case "float32":
+		return "Float32", nil
+	case "decimal":
+		// clickhouse decimals hold up to 76 digits
+		if columnInfo.DecimalOk && columnInfo.Precision > 0 && columnInfo.Precision <= 76 && columnInfo.Scale >= 0 && columnInfo.Scale <= columnInfo.Precision {
+			return fmt.Sprintf("Decimal(%v, %v)", columnInfo.Precision, columnInfo.Scale), nil
+		}
+		return "Float64", nil
+	case "money":
+		return "Decimal(19, 4)", nil
+	case "datetime":
+		return "DateTime64(6)", nil
+	case "datetimetz":
+		return "DateTime64(6, 'UTC')", nil
+	case "date":
+		return "Date32", nil
+	case "time":
+		return "String", nil
+	case "varbinary":
+		return "String", nil
+	case "blob":
+		return "String", nil
+	case "uuid":
+		return "UUID", nil
+	case "bool":
+		return "Bool", nil
+	case "json":
+		return "String", nil
+	case "xml":
+		return "String", nil
+	case "varbit":
+		return "String", nil
+	default:
+		return "", fmt.Errorf("unsupported pipeType for clickhouse: %v", columnInfo.PipeType)
+	}
+}
+
+func (system Clickhouse) createPipeFilesOverride(pipeFileChannelIn chan PipeFileInfo, columnInfo []ColumnInfo, transfer Transfer, rows *sql.Rows,
+) (pipeFileInfoChannel chan PipeFileInfo, overridden bool) {
+	return pipeFileChannelIn, false
+}
+
+func (system Clickhouse) getPipeFileFormatters() (
+	pipeFileFormatters map[string]func(interface{}) (pipeFileValue string, err error),
+) {
+	// clickhouse is only a target, but its values are read back for verification
+	return map[string]func(interface{}) (pipeFileValue string, err error){
+		"nvarchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"ntext": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"text": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"int64": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int32": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int16": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"float64": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"float32": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"decimal": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"money": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"datetime": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetime clickhousePipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"datetimetz": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetimetz clickhousePipeFileFormatters")
+			}
+			return valTime.UTC().Format(time.RFC3339Nano), nil
+		},
+		"date": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to date clickhousePipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"time": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varbinary": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"blob": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"uuid": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"bool": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%t", v), nil
+		},
+		"json": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"xml": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varbit": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+	}
+}
+
+// backslashes are escapes in clickhouse string literals
+var clickhouseStringReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+var clickhouseDateTimeFormat = "2006-01-02 15:04:05.999999"
+var clickhouseTimeFormat = "15:04:05.999999"
+
+func (system Clickhouse) getSqlFormatters() (
+	sqlFormatters map[string]func(string) (sqlValue string, err error),
+) {
+	finalCsvFormatters := system.getFinalCsvFormatters()
+
+	quoted := func(pipeType string) func(string) (string, error) {
+		return func(v string) (sqlValue string, err error) {
+			value, err := finalCsvFormatters[pipeType](v)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("'%v'", clickhouseStringReplacer.Replace(value)), nil
+		}
+	}
+
+	return map[string]func(string) (sqlValue string, err error){
+		"nvarchar":   quoted("nvarchar"),
+		"varchar":    quoted("varchar"),
+		"ntext":      quoted("ntext"),
+		"text":       quoted("text"),
+		"int64":      finalCsvFormatters["int64"],
+		"int32":      finalCsvFormatters["int32"],
+		"int16":      finalCsvFormatters["int16"],
+		"float64":    finalCsvFormatters["float64"],
+		"float32":    finalCsvFormatters["float32"],
+		"decimal":    finalCsvFormatters["decimal"],
+		"money":      finalCsvFormatters["money"],
+		"datetime":   quoted("datetime"),
+		"datetimetz": quoted("datetimetz"),
+		"date":       quoted("date"),
+		"time":       quoted("time"),
+		"varbinary": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("unhex('%v')", v), nil
+		},
+		"blob": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("unhex('%v')", v), nil
+		},
+		"uuid":   quoted("uuid"),
+		"bool":   finalCsvFormatters["bool"],
+		"json":   quoted("json"),
+		"xml":    quoted("xml"),
+		"varbit": quoted("varbit"),
+	}
+}
+
+func (system Clickhouse) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Clickhouse) convertPipeFilesOverride(pipeFilePath <-chan PipeFileInfo, finalCsvInfoChannelIn chan FinalCsvInfo, transfer Transfer, columnInfos []ColumnInfo,
+) (finalCsvInfoChannel chan FinalCsvInfo, overridden bool) {
+	return finalCsvInfoChannelIn, false
+}
+
+func (system Clickhouse) getFinalCsvFormatters() (
+	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error),
+) {
+	return map[string]func(string) (string, error){
+		"nvarchar": func(v string) (string, error) {
+			return v, nil
+		},
+		"varchar": func(v string) (string, error) {
+			return v, nil
+		},
+		"ntext": func(v string) (string, error) {
+			return v, nil
+		},
+		"text": func(v string) (string, error) {
+			return v, nil
+		},
+		"int64": func(v string) (string, error) {
+			return v, nil
+		},
+		"int32": func(v string) (string, error) {
+			return v, nil
+		},
+		"int16": func(v string) (string, error) {
+			return v, nil
+		},
+		"float64": func(v string) (string, error) {
+			return v, nil
+		},
+		"float32": func(v string) (string, error) {
+			return v, nil
+		},
+		"decimal": func(v string) (string, error) {
+			return v, nil
+		},
+		"money": func(v string) (string, error) {
+			return v, nil
+		},
+		"datetime": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing datetime value to clickhouse csv :: %v", err)
+			}
+			return valTime.Format(clickhouseDateTimeFormat), nil
+		},
+		"datetimetz": func(v string) (string, error) {
+			// datetimetz columns are created in utc, so values are written in utc
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing datetimetz value to clickhouse csv :: %v", err)
+			}
+			return valTime.UTC().Format(clickhouseDateTimeFormat), nil
+		},
+		"date": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing date value to clickhouse csv :: %v", err)
+			}
+			return valTime.Format("2006-01-02"), nil
+		},
+		"time": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing time value to clickhouse csv :: %v", err)
+			}
+			return valTime.Format(clickhouseTimeFormat), nil
+		},
+		"varbinary": func(v string) (string, error) {
+			// clickhouse strings hold raw bytes
+			valBytes, err := hex.DecodeString(v)
+			if err != nil {
+				return "", fmt.Errorf("error writing varbinary value to clickhouse csv :: %v", err)
+			}
+			return string(valBytes), nil
+		},
+		"blob": func(v string) (string, error) {
+			valBytes, err := hex.DecodeString(v)
+			if err != nil {
+				return "", fmt.Errorf("error writing blob value to clickhouse csv :: %v", err)
+			}
+			return string(valBytes), nil
+		},
+		"uuid": func(v string) (string, error) {
+			return v, nil
+		},
+		"bool": func(v string) (string, error) {
+			return v, nil
+		},
+		"json": func(v string) (string, error) {
+			return v, nil
+		},
+		"xml": func(v string) (string, error) {
+			return v, nil
+		},
+		"varbit": func(v string) (string, error) {
+			return v, nil
+		},
+	}
+}
+
+func (system Clickhouse) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Clickhouse) runInsertCmd(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	schema, table string,
+) (
+	err error,
+) {
+	// streams a final csv to clickhouse over http as the body of an insert
+
+	finalCsvFile, err := os.Open(finalCsvInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening final csv :: %v", err)
+	}
+	defer finalCsvFile.Close()
+
+	conn, err := system.Connection.Conn(transfer.Context)
+	if err != nil {
+		return fmt.Errorf("error getting clickhouse connection :: %v", err)
+	}
+	defer conn.Close()
+
+	insert := fmt.Sprintf("insert into %v", getSchemaPeriodTable(schema, table, system, true))
+
+	// empty fields are empty strings rather than column defaults, which would make them null
+	settings := url.Values{
+		"format_csv_null_representation":    {transfer.Null},
+		"input_format_csv_empty_as_default": {"0"},
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		clickhouseConn, ok := driverConn.(*clickhouseConn)
+		if !ok {
+			return errors.New("clickhouse connection is not an http connection")
+		}
+
+		return clickhouseConn.insertCsv(transfer.Context, insert, settings, finalCsvFile)
+	})
+	if err != nil {
+		recordLoaderFailure(transfer, "clickhouse")
+		return fmt.Errorf("failed to insert csv into clickhouse :: %v", err)
+	}
+
+	return nil
+}
+
+func (system Clickhouse) schemaRequired() bool {
+	return true
+}
+
+func (system Clickhouse) isReservedKeyword(keyword string) bool {
+	// clickhouse reserves few words, but escaping the ones postgresql reserves is harmless
+	if _, ok := postgresqlReservedKeywords[strings.ToLower(keyword)]; ok {
+		return true
+	}
+
+	return false
+}
+
+func (system Clickhouse) escape(objectName string) (escaped string) {
+	return fmt.Sprintf("`%v`", strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(objectName))
+}
+
+func (system Clickhouse) IsTableNotFoundError(err error) bool {
+	return strings.Contains(err.Error(), "UNKNOWN_TABLE")
+}
+
+func (system Clickhouse) getIncrementalTimeOverride(schema, table, incrementalColumn string, initialLoad bool) (time.Time, bool, bool, error) {
+	// the max of a column that isn't nullable is the epoch when a table has no rows, so rows are counted too
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	var maxTime sql.NullTime
+	var rowCount int64
+
+	err := system.queryRow(fmt.Sprintf("select max(%v), count() from %v", escapeIfNeeded(incrementalColumn, system), escapedSchemaPeriodTable)).Scan(&maxTime, &rowCount)
+	if err != nil {
+		if system.IsTableNotFoundError(err) {
+			return time.Time{}, true, true, nil
+		}
+		return time.Time{}, true, false, fmt.Errorf("error getting max %v from %v :: %v", incrementalColumn, escapedSchemaPeriodTable, err)
+	}
+
+	if !maxTime.Valid || rowCount == 0 {
+		return time.Time{}, true, true, nil
+	}
+
+	return maxTime.Time, true, false, nil
+}
+
+func (system Clickhouse) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	// clickhouse sums integers in 64 bits and wraps around on overflow, and length counts bytes
+
+	escapedColumn := escapeIfNeeded(columnInfo.Name, system)
+
+	switch kind {
+	case ChecksumSum:
+		switch columnInfo.PipeType {
+		case "int16", "int32", "int64":
+			return fmt.Sprintf("sum(toInt256(%v))", escapedColumn), true
+		}
+		return "", false
+	case ChecksumSumLengths:
+		return fmt.Sprintf("sum(lengthUTF8(%v))", escapedColumn), true
+	default:
+		return "", false
+	}
+}
+
+func (system Clickhouse) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	return nil, errors.New("clickhouse sources are not supported")
+}
+
+func (system Clickhouse) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("change data capture is not supported for clickhouse sources")
+}
+
+func (system Clickhouse) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			name AS col_name,
+			type AS col_type,
+			coalesce(numeric_precision, -1) AS col_precision,
+			coalesce(numeric_scale, -1) AS col_scale,
+			coalesce(character_octet_length, -1) AS col_length,
+			toBool(is_in_primary_key) AS col_is_primary
+		FROM
+			system.columns
+		WHERE
+			database = '%v'
+			AND table = '%v'
+		ORDER BY
+			position`, clickhouseStringReplacer.Replace(schema), clickhouseStringReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting table column infos rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Clickhouse) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			name
+		FROM
+			system.tables
+		WHERE
+			database = '%v'
+			AND NOT is_temporary
+			AND engine NOT LIKE '%%View'
+		ORDER BY
+			name`, clickhouseStringReplacer.Replace(schema))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Clickhouse) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			total_rows
+		FROM
+			system.tables
+		WHERE
+			database = '%v'
+			AND name = '%v'`, clickhouseStringReplacer.Replace(schema), clickhouseStringReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Clickhouse) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			name
+		FROM
+			system.columns
+		WHERE
+			database = '%v'
+			AND table = '%v'
+			AND is_in_primary_key
+		ORDER BY
+			position`, clickhouseStringReplacer.Replace(schema), clickhouseStringReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary keys rows :: %v", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,688 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Duckdb struct {
+	Name       string
+	Connection *sql.DB
+}
+
+func (system Duckdb) getSystemName() (name string) {
+	return system.Name
+}
+
+func newDuckdb(connectionInfo ConnectionInfo) (duckdb Duckdb, err error) {
+	db, err := openConnectionPool(connectionInfo.Name, connectionInfo.ConnectionString, DriverDuckDB)
+	if err != nil {
+		return duckdb, fmt.Errorf("error opening duckdb db :: %v", err)
+	}
+
+	duckdb.Connection = db
+	duckdb.Name = connectionInfo.Name
+
+	return duckdb, nil
+}
+
+func (system Duckdb) closeConnectionPool(printError bool) (err error) {
+	err = system.Connection.Close()
+	if err != nil && printError {
+		errorLog.Printf("error closing %v connection pool :: %v", system.Name, err)
+	}
+	return err
+}
+
+func (system Duckdb) query(query string) (rows *sql.Rows, err error) {
+	rows, err = system.Connection.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.Name, query, err)
+	}
+	return rows, nil
+}
+
+func (system Duckdb) queryRow(query string) (row *sql.Row) {
+	return system.Connection.QueryRow(query)
+}
+
+func (system Duckdb) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	return system.Connection.QueryRowContext(ctx, query)
+}
+
+func (system Duckdb) exec(query string) (err error) {
+	_, err = system.Connection.Exec(query)
+	if err != nil {
+		return fmt.Errorf("error running ddl/dml on %v :: %v :: %v", system.Name, query, err)
+	}
+	return nil
+}
+
+func (system Duckdb) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// the renames and the drop are sent as one script, so they commit together
+
+	script := fmt.Sprintf("begin transaction; alter table %v rename to %v; alter table %v rename to %v; drop table %v; commit;",
+		getSchemaPeriodTable(schema, table, system, true),
+		escapeIfNeeded(oldTable, system),
+		getSchemaPeriodTable(schema, stagingTable, system, true),
+		escapeIfNeeded(table, system),
+		getSchemaPeriodTable(schema, oldTable, system, true),
+	)
+
+	err = system.exec(script)
+	if err != nil {
+		return true, fmt.Errorf("error swapping tables :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Duckdb) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+
+	escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns := getUpsertColumns(columnInfos, system)
+
+	onConflict := "do nothing"
+	if len(escapedNonKeyColumns) > 0 {
+		set := []string{}
+		for _, column := range escapedNonKeyColumns {
+			set = append(set, fmt.Sprintf("%v = excluded.%v", column, column))
+		}
+		onConflict = fmt.Sprintf("do update set %v", strings.Join(set, ", "))
+	}
+
+	query := fmt.Sprintf("insert into %v (%v) select %v from %v on conflict (%v) %v",
+		getSchemaPeriodTable(schema, table, system, true),
+		strings.Join(escapedColumns, ", "),
+		strings.Join(escapedColumns, ", "),
+		getSchemaPeriodTable(schema, stagingTable, system, true),
+		strings.Join(escapedPrimaryKeys, ", "),
+		onConflict,
+	)
+
+	return true, system.exec(query)
+}
+
+func (system Duckdb) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) createSchemaIfNotExistsOverride(schema string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) dbTypeToPipeType(
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	// the information schema and the driver both name types the way describe does, like
+	// DECIMAL(18,3), INTEGER[], or STRUCT(a INTEGER). nested types are moved as json
+
+	if strings.HasSuffix(databaseTypeName, "]") {
+		return "json", nil
+	}
+
+	switch getDuckdbBaseType(databaseTypeName) {
+	case "BOOLEAN":
+		return "bool", nil
+	case "TINYINT", "SMALLINT", "UTINYINT":
+		return "int16", nil
+	case "INTEGER", "USMALLINT":
+		return "int32", nil
+	case "BIGINT", "UINTEGER":
+		return "int64", nil
+	case "UBIGINT", "HUGEINT", "UHUGEINT":
+		return "decimal", nil
+	case "FLOAT":
+		return "float32", nil
+	case "DOUBLE":
+		return "float64", nil
+	case "DECIMAL":
+		return "decimal", nil
+	case "VARCHAR":
+		return "ntext", nil
+	case "DATE":
+		return "date", nil
+	case "TIME":
+		return "time", nil
+	case "TIMESTAMP", "TIMESTAMP_S", "TIMESTAMP_MS", "TIMESTAMP_NS", "DATETIME":
+		return "datetime", nil
+	case "TIMESTAMP WITH TIME ZONE", "TIMESTAMPTZ":
+		return "datetimetz", nil
+	case "UUID":
+		return "uuid", nil
+	case "JSON":
+		return "json", nil
+	case "BLOB":
+		return "blob", nil
+	case "BIT":
+		return "varbit", nil
+	case "STRUCT", "MAP":
+		return "json", nil
+	case "ENUM", "INTERVAL", "TIME WITH TIME ZONE", "TIMETZ", "UNION":
+		return "ntext", nil
+	default:
+		return "", fmt.Errorf("unsupported database type for duckdb: %v", databaseTypeName)
+	}
+}
+
+func (system Duckdb) driverTypeToPipeType(
+	columnType *sql.ColumnType,
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	return system.dbTypeToPipeType(databaseTypeName)
+}
+
+func (system Duckdb) pipeTypeToCreateType(columnInfo ColumnInfo) (createType string, err error) {
+	switch columnInfo.PipeType {
+	case "nvarchar":
+		return "varchar", nil
+	case "varchar":
+		return "varchar", nil
+	case "ntext":
+		return "varchar", nil
+	case "text":
+		return "varchar", nil
+	case "int64":
+		return "bigint", nil
+	case "int32":
+		return "integer", nil
+	case "int16":
+		return "smallint", nil
+	case "float64":
+		return "double", nil
+	case "float32":
+		return "float", nil
+	case "decimal":
+		// duckdb decimals hold up to 38 digits
+		if columnInfo.DecimalOk && columnInfo.Precision > 0 && columnInfo.Precision <= 38 && columnInfo.Scale >= 0 {
+			return fmt.Sprintf("decimal(%v,%v)", columnInfo.Precision, columnInfo.Scale), nil
+		}
+		return "double", nil
+	case "money":
+		return "decimal(19,4)", nil
+	case "datetime":
+		return "timestamp", nil
+	case "datetimetz":
+		return "timestamptz", nil
+	case "date":
+		return "date", nil
+	case "time":
+		return "time", nil
+	case "varbinary":
+		return "blob", nil
+	case "blob":
+		return "blob", nil
+	case "uuid":
+		return "uuid", nil
+	case "bool":
+		return "boolean", nil
+	case "json":
+		// the json type lives in the json extension, which isn't linked in
+		return "varchar", nil
+	case "xml":
+		return "varchar", nil
+	case "varbit":
+		return "bit", nil
+	default:
+		return "", fmt.Errorf("unsupported pipeType for duckdb: %v", columnInfo.PipeType)
+	}
+}
+
+func (system Duckdb) createPipeFilesOverride(pipeFileChannelIn chan PipeFileInfo, columnInfo []ColumnInfo, transfer Transfer, rows *sql.Rows,
+) (pipeFileInfoChannel chan PipeFileInfo, overridden bool) {
+	return pipeFileChannelIn, false
+}
+
+func (system Duckdb) getPipeFileFormatters() (
+	pipeFileFormatters map[string]func(interface{}) (pipeFileValue string, err error),
+) {
+	return map[string]func(interface{}) (pipeFileValue string, err error){
+		"nvarchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"ntext": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"text": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"int64": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int32": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int16": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"float64": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float64 duckdbPipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 64), nil
+		},
+		"float32": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float32 duckdbPipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 32), nil
+		},
+		"decimal": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"money": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"datetime": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetime duckdbPipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"datetimetz": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetimetz duckdbPipeFileFormatters")
+			}
+			return valTime.UTC().Format(time.RFC3339Nano), nil
+		},
+		"date": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to date duckdbPipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"time": func(v interface{}) (pipeFileValue string, err error) {
+			timeString, ok := v.(string)
+			if !ok {
+				return "", errors.New("unable to cast value to string in duckdbPipeFileFormatters")
+			}
+
+			timeVal, err := time.Parse(duckdbTimeFormat, timeString)
+			if err != nil {
+				return "", fmt.Errorf("error parsing time value %v in duckdbPipeFileFormatters", timeString)
+			}
+
+			return timeVal.Format(time.RFC3339Nano), nil
+		},
+		"varbinary": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"blob": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"uuid": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"bool": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%t", v), nil
+		},
+		"json": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"xml": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varbit": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+	}
+}
+
+var duckdbTimestampFormat = "2006-01-02 15:04:05.999999"
+var duckdbTimestampTzFormat = "2006-01-02 15:04:05.999999-07:00"
+var duckdbTimeFormat = "15:04:05.999999"
+
+func (system Duckdb) getSqlFormatters() (
+	sqlFormatters map[string]func(string) (sqlValue string, err error),
+) {
+	finalCsvFormatters := system.getFinalCsvFormatters()
+
+	quoted := func(pipeType string) func(string) (string, error) {
+		return func(v string) (sqlValue string, err error) {
+			value, err := finalCsvFormatters[pipeType](v)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("'%v'", singleQuoteReplacer.Replace(value)), nil
+		}
+	}
+
+	return map[string]func(string) (sqlValue string, err error){
+		"nvarchar":   quoted("nvarchar"),
+		"varchar":    quoted("varchar"),
+		"ntext":      quoted("ntext"),
+		"text":       quoted("text"),
+		"int64":      finalCsvFormatters["int64"],
+		"int32":      finalCsvFormatters["int32"],
+		"int16":      finalCsvFormatters["int16"],
+		"float64":    finalCsvFormatters["float64"],
+		"float32":    finalCsvFormatters["float32"],
+		"decimal":    finalCsvFormatters["decimal"],
+		"money":      finalCsvFormatters["money"],
+		"datetime":   quoted("datetime"),
+		"datetimetz": quoted("datetimetz"),
+		"date":       quoted("date"),
+		"time":       quoted("time"),
+		"varbinary": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("unhex('%v')", v), nil
+		},
+		"blob": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("unhex('%v')", v), nil
+		},
+		"uuid":   quoted("uuid"),
+		"bool":   finalCsvFormatters["bool"],
+		"json":   quoted("json"),
+		"xml":    quoted("xml"),
+		"varbit": quoted("varbit"),
+	}
+}
+
+func (system Duckdb) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) convertPipeFilesOverride(pipeFilePath <-chan PipeFileInfo, finalCsvInfoChannelIn chan FinalCsvInfo, transfer Transfer, columnInfos []ColumnInfo,
+) (finalCsvInfoChannel chan FinalCsvInfo, overridden bool) {
+	return finalCsvInfoChannelIn, false
+}
+
+func (system Duckdb) getFinalCsvFormatters() (
+	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error),
+) {
+	return map[string]func(string) (string, error){
+		"nvarchar": func(v string) (string, error) {
+			return v, nil
+		},
+		"varchar": func(v string) (string, error) {
+			return v, nil
+		},
+		"ntext": func(v string) (string, error) {
+			return v, nil
+		},
+		"text": func(v string) (string, error) {
+			return v, nil
+		},
+		"int64": func(v string) (string, error) {
+			return v, nil
+		},
+		"int32": func(v string) (string, error) {
+			return v, nil
+		},
+		"int16": func(v string) (string, error) {
+			return v, nil
+		},
+		"float64": func(v string) (string, error) {
+			return v, nil
+		},
+		"float32": func(v string) (string, error) {
+			return v, nil
+		},
+		"decimal": func(v string) (string, error) {
+			return v, nil
+		},
+		"money": func(v string) (string, error) {
+			return v, nil
+		},
+		"datetime": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing datetime value to duckdb csv :: %v", err)
+			}
+			return valTime.Format(duckdbTimestampFormat), nil
+		},
+		"datetimetz": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing datetimetz value to duckdb csv :: %v", err)
+			}
+			return valTime.UTC().Format(duckdbTimestampTzFormat), nil
+		},
+		"date": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing date value to duckdb csv :: %v", err)
+			}
+			return valTime.Format("2006-01-02"), nil
+		},
+		"time": func(v string) (string, error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error writing time value to duckdb csv :: %v", err)
+			}
+			return valTime.Format(duckdbTimeFormat), nil
+		},
+		"varbinary": func(v string) (string, error) {
+			return duckdbBlobLiteral(v)
+		},
+		"blob": func(v string) (string, error) {
+			return duckdbBlobLiteral(v)
+		},
+		"uuid": func(v string) (string, error) {
+			return v, nil
+		},
+		"bool": func(v string) (string, error) {
+			return v, nil
+		},
+		"json": func(v string) (string, error) {
+			return v, nil
+		},
+		"xml": func(v string) (string, error) {
+			return v, nil
+		},
+		"varbit": func(v string) (string, error) {
+			return v, nil
+		},
+	}
+}
+
+func duckdbBlobLiteral(hexValue string) (literal string, err error) {
+	// duckdb reads blobs from text with every byte escaped, like \xAB\xCD
+	if len(hexValue)%2 != 0 {
+		return "", fmt.Errorf("error writing blob value to duckdb csv :: odd length hex value")
+	}
+
+	literalBuilder := strings.Builder{}
+	for i := 0; i < len(hexValue); i += 2 {
+		literalBuilder.WriteString(`\x`)
+		literalBuilder.WriteString(hexValue[i : i+2])
+	}
+
+	return literalBuilder.String(), nil
+}
+
+func (system Duckdb) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Duckdb) runInsertCmd(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	schema, table string,
+) (
+	err error,
+) {
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	copyCmd := fmt.Sprintf(`COPY %v FROM '%v' (FORMAT csv, HEADER false, DELIMITER ',', QUOTE '"', ESCAPE '"', NULLSTR '%v')`,
+		escapedSchemaPeriodTable, singleQuoteReplacer.Replace(finalCsvInfo.FilePath), singleQuoteReplacer.Replace(transfer.Null))
+
+	_, err = system.Connection.ExecContext(transfer.Context, copyCmd)
+	if err != nil {
+		recordLoaderFailure(transfer, "duckdb")
+		return fmt.Errorf("failed to copy csv to duckdb :: %v", err)
+	}
+
+	return nil
+}
+
+func getDuckdbBaseType(columnType string) string {
+	// strips the precision and scale from types like DECIMAL(18,3)
+	if i := strings.Index(columnType, "("); i >= 0 {
+		columnType = columnType[:i]
+	}
+	return strings.ToUpper(strings.TrimSpace(columnType))
+}
+
+func (system Duckdb) schemaRequired() bool {
+	return true
+}
+
+func (system Duckdb) isReservedKeyword(keyword string) bool {
+	// duckdb reserves the same words postgresql does
+	if _, ok := postgresqlReservedKeywords[strings.ToLower(keyword)]; ok {
+		return true
+	}
+
+	return false
+}
+
+func (system Duckdb) escape(objectName string) (escaped string) {
+	return fmt.Sprintf(`"%v"`, strings.ReplaceAll(objectName, `"`, `""`))
+}
+
+func (system Duckdb) IsTableNotFoundError(err error) bool {
+	return strings.Contains(err.Error(), "does not exist")
+}
+
+func (system Duckdb) getIncrementalTimeOverride(schema, table, incrementalColumn string, initialLoad bool) (time.Time, bool, bool, error) {
+	return time.Time{}, false, initialLoad, nil
+}
+
+func (system Duckdb) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	return "", false
+}
+
+func (system Duckdb) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// every statement in a duckdb transaction sees the snapshot it started with
+	return system.Connection.BeginTx(ctx, nil)
+}
+
+func (system Duckdb) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("change data capture is not supported for duckdb sources")
+}
+
+func (system Duckdb) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		WITH PrimaryKeys AS (
+			SELECT
+				unnest(constraint_column_names) AS column_name
+			FROM
+				duckdb_constraints()
+			WHERE
+				constraint_type = 'PRIMARY KEY'
+				AND schema_name = '%v'
+				AND table_name = '%v'
+		)
+
+		SELECT
+			columns.column_name AS col_name,
+			columns.data_type AS col_type,
+			coalesce(columns.numeric_precision, -1) AS col_precision,
+			coalesce(columns.numeric_scale, -1) AS col_scale,
+			coalesce(columns.character_maximum_length, -1) AS col_length,
+			pk.column_name IS NOT NULL AS col_is_primary
+		FROM
+			information_schema.columns
+			LEFT JOIN PrimaryKeys pk ON columns.column_name = pk.column_name
+		WHERE columns.table_schema = '%v'
+			AND columns.table_name = '%v'
+		ORDER BY
+			columns.ordinal_position`,
+		singleQuoteReplacer.Replace(schema), singleQuoteReplacer.Replace(table),
+		singleQuoteReplacer.Replace(schema), singleQuoteReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting table column infos rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Duckdb) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			table_name
+		FROM
+			information_schema.tables
+		WHERE
+			table_schema = '%v'
+			AND table_type = 'BASE TABLE'
+		ORDER BY
+			table_name`, singleQuoteReplacer.Replace(schema))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Duckdb) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			estimated_size
+		FROM
+			duckdb_tables()
+		WHERE
+			schema_name = '%v'
+			AND table_name = '%v'`, singleQuoteReplacer.Replace(schema), singleQuoteReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Duckdb) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			unnest(constraint_column_names) AS column_name
+		FROM
+			duckdb_constraints()
+		WHERE
+			constraint_type = 'PRIMARY KEY'
+			AND schema_name = '%v'
+			AND table_name = '%v'`, singleQuoteReplacer.Replace(schema), singleQuoteReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary keys rows :: %v", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,190 @@
+//go:build cgo
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+func TestDuckdbToDuckdbTransfer(t *testing.T) {
+	dir := t.TempDir()
+	globalTmpDir = dir
+	sourcePath := filepath.Join(dir, "source.duckdb")
+	targetPath := filepath.Join(dir, "target.duckdb")
+
+	source, err := sql.Open(DriverDuckDB, sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	_, err = source.Exec(`create table main.orders (
+		id integer primary key,
+		customer varchar,
+		quantity smallint,
+		price float,
+		total decimal(10,2),
+		shipped boolean,
+		ordered_at timestamp,
+		ordered_at_tz timestamptz,
+		ordered_on date,
+		ordered_time time,
+		receipt blob,
+		tags varchar[],
+		details struct(weight decimal(6,3), carrier varchar),
+		attributes map(varchar, integer),
+		reference uuid
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = source.Exec(`insert into main.orders values
+		(1, 'alice', 3, 2.5, 7.50, true, '2023-11-02 10:15:30', '2023-11-02 10:15:30+02', '2023-11-02', '10:15:30', '\x01\x02\xFF'::blob, ['a', 'b'], {'weight': -0.25, 'carrier': 'ups'}, map {'gift': 1}, '6f1c8a38-3c4e-4f0e-9d2c-0c1f3a0d6b7e'),
+		(2, 'bob', 1, 10, 10.25, false, '2023-11-03 08:00:00.123', '2023-11-03 08:00:00.123+00', '2023-11-03', '08:00:00.5', null, [], {'weight': 12, 'carrier': null}, map {}, null),
+		(3, null, null, null, null, null, null, null, null, null, null, null, null, null, null)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs("duckdb-to-duckdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transfer := Transfer{
+		Id:                           "duckdb-to-duckdb",
+		CreatedAt:                    time.Now(),
+		Progress:                     newTransferProgress(),
+		Status:                       StatusQueued,
+		TmpDir:                       tmpDir,
+		PipeFileDir:                  pipeFileDir,
+		FinalCsvDir:                  finalCsvDir,
+		Delimiter:                    "{dlm}",
+		Newline:                      "{nwln}",
+		Null:                         "{nll}",
+		Context:                      ctx,
+		Cancel:                       cancel,
+		SourceConnectionInfo:         ConnectionInfo{Name: "source", Type: TypeDuckDB, ConnectionString: sourcePath},
+		TargetConnectionInfo:         ConnectionInfo{Name: "target", Type: TypeDuckDB, ConnectionString: targetPath},
+		SourceSchema:                 "main",
+		SourceTable:                  "orders",
+		TargetSchema:                 "main",
+		TargetTable:                  "orders",
+		CreateTargetTableIfNotExists: true,
+		Loader:                       LoaderNative,
+		WriteMode:                    WriteModeAppend,
+		Verification:                 VerificationChecksum,
+	}
+
+	v := newValidator()
+	validateTransfer(v, transfer)
+	if !v.valid() {
+		t.Fatalf("transfer failed validation :: %v", v.errors)
+	}
+
+	transferMap.Set(transfer.Id, transfer)
+
+	err = runTransfer(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer, _ = transferMap.Get(transfer.Id)
+	if transfer.Status != StatusComplete {
+		t.Fatalf("transfer status is %v, not %v :: %v", transfer.Status, StatusComplete, transfer.Error)
+	}
+	if transfer.VerificationResult == nil || !transfer.VerificationResult.Passed {
+		t.Fatalf("transfer verification did not pass :: %+v", transfer.VerificationResult)
+	}
+
+	target, err := sql.Open(DriverDuckDB, targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	query := `select * from main.orders order by id`
+	want := readSqliteRows(t, source, query)
+	got := readSqliteRows(t, target, query)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("target rows do not match source rows\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestDuckdbPoolsShareADatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.duckdb")
+
+	first, err := sql.Open(DriverDuckDB, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := sql.Open(DriverDuckDB, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = first.Exec(`create table numbers (n integer)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = second.Exec(`insert into numbers values (1), (2)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// closing one pool leaves the database open for the other
+	err = second.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	err = first.QueryRow(`select count(*) from numbers`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("got %v rows, want 2", count)
+	}
+}
+
+func TestGetDuckdbDecimalString(t *testing.T) {
+	tests := []struct {
+		name  string
+		value int64
+		scale uint8
+		want  string
+	}{
+		{"no scale", 1234, 0, "1234"},
+		{"scaled", 1234, 2, "12.34"},
+		{"leading zeros", 5, 3, "0.005"},
+		{"as many digits as the scale", 250, 3, "0.250"},
+		{"negative", -250, 3, "-0.250"},
+		{"negative whole number", -7, 0, "-7"},
+		{"zero", 0, 2, "0.00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getDuckdbDecimalString(duckdb.Decimal{Width: 18, Scale: test.scale, Value: big.NewInt(test.value)})
+			if got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
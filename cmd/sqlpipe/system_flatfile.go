@@ -0,0 +1,757 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a flat file system is a directory of csv or json lines files, one per table. files are read
+// through the flat file driver, and written here straight from pipe files, with the settings
+// in the connection string. unlike parquet files, flat files can be appended to, so appends
+// write rows to the end of the load table's file, and a csv header is only written when the
+// file is empty
+
+type FlatFile struct {
+	Name       string
+	Connection *sql.DB
+	Format     string
+	Directory  string
+	Options    flatFileOptions
+}
+
+func (system FlatFile) getSystemName() (name string) {
+	return system.Name
+}
+
+func newFlatFile(connectionInfo ConnectionInfo) (flatFile FlatFile, err error) {
+
+	directory, options, err := parseFlatFileConnectionString(connectionInfo.Type, connectionInfo.ConnectionString)
+	if err != nil {
+		return flatFile, err
+	}
+
+	// the directory is created for targets, and a source in a missing directory has no tables
+	err = os.MkdirAll(directory, 0755)
+	if err != nil {
+		return flatFile, fmt.Errorf("error creating %v directory :: %v", connectionInfo.Type, err)
+	}
+
+	driverName := DriverCsv
+	if connectionInfo.Type == TypeJsonl {
+		driverName = DriverJsonl
+	}
+
+	db, err := openConnectionPool(connectionInfo.Name, connectionInfo.ConnectionString, driverName)
+	if err != nil {
+		return flatFile, fmt.Errorf("error opening %v directory :: %v", connectionInfo.Type, err)
+	}
+
+	flatFile.Connection = db
+	flatFile.Name = connectionInfo.Name
+	flatFile.Format = connectionInfo.Type
+	flatFile.Directory = directory
+	flatFile.Options = options
+
+	return flatFile, nil
+}
+
+func (system FlatFile) closeConnectionPool(printError bool) (err error) {
+	err = system.Connection.Close()
+	if err != nil && printError {
+		errorLog.Printf("error closing %v connection pool :: %v", system.Name, err)
+	}
+	return err
+}
+
+func (system FlatFile) query(query string) (rows *sql.Rows, err error) {
+	rows, err = system.Connection.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.Name, query, err)
+	}
+	return rows, nil
+}
+
+func (system FlatFile) queryRow(query string) (row *sql.Row) {
+	return system.Connection.QueryRow(query)
+}
+
+func (system FlatFile) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	return system.Connection.QueryRowContext(ctx, query)
+}
+
+func (system FlatFile) exec(query string) (err error) {
+	_, err = system.Connection.Exec(query)
+	if err != nil {
+		return fmt.Errorf("error running ddl/dml on %v :: %v :: %v", system.Name, query, err)
+	}
+	return nil
+}
+
+func (system FlatFile) getFilePath(table string) (path string, err error) {
+	return getTableFilePath(system.Directory, table, "."+system.Format)
+}
+
+func (system FlatFile) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return true, fmt.Errorf("error removing %v file :: %v", system.Format, err)
+	}
+
+	return true, nil
+}
+
+func (system FlatFile) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	// an empty file has no rows, and its csv header is written again with the next rows
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.Truncate(path, 0)
+	if err != nil {
+		return true, fmt.Errorf("error truncating %v file :: %v", system.Format, err)
+	}
+
+	return true, nil
+}
+
+func (system FlatFile) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	newPath, err := system.getFilePath(newTable)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.Rename(path, newPath)
+	if err != nil {
+		return true, fmt.Errorf("error renaming %v file :: %v", system.Format, err)
+	}
+
+	return true, nil
+}
+
+func (system FlatFile) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// a rename replaces the target file in one step, so there's no old table to drop
+	return system.renameTableOverride(schema, stagingTable, table)
+}
+
+func (system FlatFile) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+	return true, fmt.Errorf("upserts are not supported for %v targets", system.Format)
+}
+
+func (system FlatFile) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+	// creating a table writes a file with only a csv header, or nothing for json lines
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return true, fmt.Errorf("error checking for %v file :: %v", system.Format, err)
+	}
+
+	err = replaceFile(path, func(sink *os.File) error {
+		writer := newFlatFileWriter(sink, columnInfos, system)
+		if system.Format == TypeCsv && system.Options.header {
+			writer.writeHeader()
+		}
+		return writer.flush()
+	})
+	if err != nil {
+		return true, fmt.Errorf("error creating %v file :: %v", system.Format, err)
+	}
+
+	infoLog.Printf("%v file %v created", system.Format, path)
+
+	return true, nil
+}
+
+func (system FlatFile) createSchemaIfNotExistsOverride(schema string) (overridden bool, err error) {
+	return true, nil
+}
+
+func (system FlatFile) dbTypeToPipeType(
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	// the driver names types after pipe types, like int64, or decimal(10,2)
+
+	pipeType = getFlatFileBaseType(databaseTypeName)
+	if !permittedValue(pipeType, flatFileColumnTypes...) {
+		return "", fmt.Errorf("unsupported database type for %v: %v", system.Format, databaseTypeName)
+	}
+
+	return pipeType, nil
+}
+
+func (system FlatFile) driverTypeToPipeType(
+	columnType *sql.ColumnType,
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	return system.dbTypeToPipeType(databaseTypeName)
+}
+
+func (system FlatFile) pipeTypeToCreateType(columnInfo ColumnInfo) (createType string, err error) {
+	// flat files have no types of their own, so columns keep their pipe types
+
+	if !permittedValue(columnInfo.PipeType, flatFileColumnTypes...) {
+		return "", fmt.Errorf("unsupported pipeType for %v: %v", system.Format, columnInfo.PipeType)
+	}
+
+	return columnInfo.PipeType, nil
+}
+
+func (system FlatFile) createPipeFilesOverride(pipeFileChannelIn chan PipeFileInfo, columnInfo []ColumnInfo, transfer Transfer, rows *sql.Rows,
+) (pipeFileInfoChannel chan PipeFileInfo, overridden bool) {
+	return pipeFileChannelIn, false
+}
+
+func (system FlatFile) getPipeFileFormatters() (
+	pipeFileFormatters map[string]func(interface{}) (pipeFileValue string, err error),
+) {
+	return map[string]func(interface{}) (pipeFileValue string, err error){
+		"nvarchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"ntext": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"text": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"int64": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int32": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int16": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"float64": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float64 flatFilePipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 64), nil
+		},
+		"float32": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float32 flatFilePipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 32), nil
+		},
+		"decimal": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"money": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"datetime": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetime flatFilePipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"datetimetz": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetimetz flatFilePipeFileFormatters")
+			}
+			return valTime.UTC().Format(time.RFC3339Nano), nil
+		},
+		"date": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to date flatFilePipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"time": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to time flatFilePipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"varbinary": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"blob": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"uuid": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"bool": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%t", v), nil
+		},
+		"json": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"xml": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varbit": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+	}
+}
+
+func (system FlatFile) getSqlFormatters() (
+	sqlFormatters map[string]func(string) (sqlValue string, err error),
+) {
+	// the driver doesn't filter rows, so these are only here to satisfy the system interface
+
+	quoted := func(v string) (sqlValue string, err error) {
+		return fmt.Sprintf("'%v'", singleQuoteReplacer.Replace(v)), nil
+	}
+
+	sqlFormatters = map[string]func(string) (sqlValue string, err error){}
+	for pipeType := range system.getFinalCsvFormatters() {
+		sqlFormatters[pipeType] = quoted
+	}
+
+	return sqlFormatters
+}
+
+func (system FlatFile) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+	// appends the rows of every pipe file to the load table's file
+
+	path, err := system.getFilePath(getLoadTable(transfer))
+	if err != nil {
+		return true, err
+	}
+
+	err = system.checkHeader(path, columnInfo)
+	if err != nil {
+		return true, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return true, fmt.Errorf("error opening %v file :: %v", system.Format, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return true, fmt.Errorf("error reading %v file :: %v", system.Format, err)
+	}
+
+	writer := newFlatFileWriter(file, columnInfo, system)
+
+	if system.Format == TypeCsv && system.Options.header && info.Size() == 0 {
+		writer.writeHeader()
+	}
+
+	for pipeFileInfo := range pipeFileInfoChannel {
+
+		select {
+		case <-transfer.Context.Done():
+			return true, errors.New("context cancelled")
+		default:
+		}
+
+		loadStart := time.Now()
+
+		err = writer.writePipeFile(pipeFileInfo, transfer)
+		if err != nil {
+			return true, fmt.Errorf("error writing pipe file to %v :: %v", system.Format, err)
+		}
+
+		recordStageDuration(transfer, StageLoad, loadStart)
+		recordFinalCsvInserted(transfer)
+		transfer.Progress.addLoadedFile(pipeFileInfo.Rows)
+
+		if pipeFileInfo.PkFilePath != "" {
+			os.Remove(pipeFileInfo.PkFilePath)
+		}
+
+		if !transfer.KeepFiles {
+			err = os.Remove(pipeFileInfo.FilePath)
+			if err != nil {
+				return true, fmt.Errorf("error removing pipe file :: %v", err)
+			}
+		}
+	}
+
+	err = file.Close()
+	if err != nil {
+		return true, fmt.Errorf("error closing %v file :: %v", system.Format, err)
+	}
+
+	infoLog.Printf("transfer %v finished writing %v file %v", transfer.Id, system.Format, path)
+
+	return true, nil
+}
+
+func (system FlatFile) checkHeader(path string, columnInfos []ColumnInfo) (err error) {
+	// rows appended to a csv with a header must have the same columns, in the same order
+
+	if system.Format != TypeCsv || !system.Options.header {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error opening csv file :: %v", err)
+	}
+	defer file.Close()
+
+	reader := &flatFileReader{reader: bufio.NewReader(file), delimiter: system.Options.delimiter, quote: system.Options.quote}
+
+	headers, _, err := reader.readCsv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading csv header :: %v", err)
+	}
+
+	columnNames := []string{}
+	for _, columnInfo := range columnInfos {
+		columnNames = append(columnNames, columnInfo.Name)
+	}
+
+	if strings.Join(headers, "\x00") != strings.Join(columnNames, "\x00") {
+		return fmt.Errorf("csv file %v has columns %v, which don't match the transfer's columns %v", path, headers, columnNames)
+	}
+
+	return nil
+}
+
+type flatFileWriter struct {
+	writer      *bufio.Writer
+	format      string
+	options     flatFileOptions
+	columnInfos []ColumnInfo
+	formatters  map[string]func(string) (string, error)
+	jsonKeys    []string
+}
+
+func newFlatFileWriter(sink io.Writer, columnInfos []ColumnInfo, system FlatFile) (writer *flatFileWriter) {
+
+	writer = &flatFileWriter{
+		writer:      bufio.NewWriterSize(sink, 1024*1024),
+		format:      system.Format,
+		options:     system.Options,
+		columnInfos: columnInfos,
+		formatters:  system.getFinalCsvFormatters(),
+	}
+
+	// keys are encoded once, with the comma or brace that comes before them
+	for i, columnInfo := range columnInfos {
+		prefix := ","
+		if i == 0 {
+			prefix = "{"
+		}
+		writer.jsonKeys = append(writer.jsonKeys, prefix+getFlatFileJsonString(columnInfo.Name)+":")
+	}
+
+	return writer
+}
+
+func getFlatFileJsonString(value string) string {
+	// json.Marshal escapes <, >, and &, which only matters for html
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func (writer *flatFileWriter) writeHeader() {
+	for i, columnInfo := range writer.columnInfos {
+		if i > 0 {
+			writer.writer.WriteRune(writer.options.delimiter)
+		}
+		writer.writeCsvField(columnInfo.Name)
+	}
+	writer.writer.WriteByte('\n')
+}
+
+func (writer *flatFileWriter) writeCsvField(value string) {
+	// quotes values that would otherwise be read as a null, or split into more fields or rows
+
+	if value != writer.options.null && !strings.ContainsAny(value, string([]rune{writer.options.delimiter, writer.options.quote, '\r', '\n'})) {
+		writer.writer.WriteString(value)
+		return
+	}
+
+	quote := string(writer.options.quote)
+	writer.writer.WriteString(quote)
+	writer.writer.WriteString(strings.ReplaceAll(value, quote, quote+quote))
+	writer.writer.WriteString(quote)
+}
+
+func (writer *flatFileWriter) writeJsonValue(value, pipeType string) {
+	// numbers, bools, and json are written as json values, if they are valid ones, and
+	// everything else is written as a string
+
+	switch pipeType {
+	case "int64", "int32", "int16", "float64", "float32", "decimal", "money":
+		if value != "" && (value[0] == '-' || value[0] >= '0' && value[0] <= '9') && json.Valid([]byte(value)) {
+			writer.writer.WriteString(value)
+			return
+		}
+	case "bool":
+		if value == "true" || value == "false" {
+			writer.writer.WriteString(value)
+			return
+		}
+	case "json":
+		if json.Valid([]byte(value)) {
+			compacted := &bytes.Buffer{}
+			if json.Compact(compacted, []byte(value)) == nil {
+				writer.writer.Write(compacted.Bytes())
+				return
+			}
+		}
+	}
+
+	writer.writer.WriteString(getFlatFileJsonString(value))
+}
+
+func (writer *flatFileWriter) writePipeFile(pipeFileInfo PipeFileInfo, transfer Transfer) (err error) {
+
+	pipeFile, err := os.Open(pipeFileInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening pipe file :: %v", err)
+	}
+	defer pipeFile.Close()
+
+	csvReader := csv.NewReader(pipeFile)
+
+	for {
+		row, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return writer.flush()
+			}
+			return fmt.Errorf("error reading pipe file :: %v", err)
+		}
+
+		for i := range row {
+			pipeType := writer.columnInfos[i].PipeType
+			null := row[i] == transfer.Null
+
+			value := row[i]
+			if !null {
+				value, err = writer.formatters[pipeType](row[i])
+				if err != nil {
+					return fmt.Errorf("error formatting value for column %v :: %v", writer.columnInfos[i].Name, err)
+				}
+			}
+
+			if writer.format == TypeJsonl {
+				writer.writer.WriteString(writer.jsonKeys[i])
+				if null {
+					writer.writer.WriteString("null")
+				} else {
+					writer.writeJsonValue(value, pipeType)
+				}
+				continue
+			}
+
+			if i > 0 {
+				writer.writer.WriteRune(writer.options.delimiter)
+			}
+			if null {
+				writer.writer.WriteString(writer.options.null)
+			} else {
+				writer.writeCsvField(value)
+			}
+		}
+
+		if writer.format == TypeJsonl {
+			writer.writer.WriteByte('}')
+		}
+		writer.writer.WriteByte('\n')
+	}
+}
+
+func (writer *flatFileWriter) flush() (err error) {
+	err = writer.writer.Flush()
+	if err != nil {
+		return fmt.Errorf("error writing %v file :: %v", writer.format, err)
+	}
+	return nil
+}
+
+func (system FlatFile) convertPipeFilesOverride(pipeFilePath <-chan PipeFileInfo, finalCsvInfoChannelIn chan FinalCsvInfo, transfer Transfer, columnInfos []ColumnInfo,
+) (finalCsvInfoChannel chan FinalCsvInfo, overridden bool) {
+	return finalCsvInfoChannelIn, false
+}
+
+func formatFlatFileTime(layout string, utc bool) func(string) (string, error) {
+	// values that aren't rfc 3339 times are written as they are
+	return func(v string) (string, error) {
+		valTime, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return v, nil
+		}
+		if utc {
+			valTime = valTime.UTC()
+		}
+		return valTime.Format(layout), nil
+	}
+}
+
+func (system FlatFile) getFinalCsvFormatters() (
+	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error),
+) {
+	// pipe files are written to flat files without final csvs, so these format pipe values the
+	// way the driver reads them back, and times lose the parts their types don't have
+
+	same := func(v string) (string, error) {
+		return v, nil
+	}
+
+	return map[string]func(string) (string, error){
+		"nvarchar":   same,
+		"varchar":    same,
+		"ntext":      same,
+		"text":       same,
+		"int64":      same,
+		"int32":      same,
+		"int16":      same,
+		"float64":    same,
+		"float32":    same,
+		"decimal":    same,
+		"money":      same,
+		"datetime":   formatFlatFileTime("2006-01-02 15:04:05.999999999", false),
+		"datetimetz": formatFlatFileTime(time.RFC3339Nano, true),
+		"date":       formatFlatFileTime(flatFileDateLayout, false),
+		"time":       formatFlatFileTime(flatFileTimeLayout, false),
+		"varbinary":  same,
+		"blob":       same,
+		"uuid":       same,
+		"bool":       same,
+		"json":       same,
+		"xml":        same,
+		"varbit":     same,
+	}
+}
+
+func (system FlatFile) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system FlatFile) runInsertCmd(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	schema, table string,
+) (
+	err error,
+) {
+	return fmt.Errorf("%v targets are written from pipe files, not final csvs", system.Format)
+}
+
+func (system FlatFile) schemaRequired() bool {
+	return false
+}
+
+func (system FlatFile) isReservedKeyword(keyword string) bool {
+	return false
+}
+
+func (system FlatFile) escape(objectName string) (escaped string) {
+	return fmt.Sprintf(`"%v"`, strings.ReplaceAll(objectName, `"`, `""`))
+}
+
+func (system FlatFile) IsTableNotFoundError(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || strings.Contains(err.Error(), "no such file or directory")
+}
+
+func (system FlatFile) getIncrementalTimeOverride(schema, table, incrementalColumn string, initialLoad bool) (time.Time, bool, bool, error) {
+	return time.Time{}, true, initialLoad, fmt.Errorf("incremental transfers are not supported for %v targets", system.Format)
+}
+
+func (system FlatFile) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	return "", false
+}
+
+func (system FlatFile) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	return nil, fmt.Errorf("%v sources can't be read in a snapshot", system.Format)
+}
+
+func (system FlatFile) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, fmt.Errorf("change data capture is not supported for %v sources", system.Format)
+}
+
+func (system FlatFile) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+
+	rows, err = system.query(fmt.Sprintf("describe %v", system.escape(table)))
+	if err != nil {
+		return nil, fmt.Errorf("error getting table column infos rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system FlatFile) getTablesRows(schema string) (rows *sql.Rows, err error) {
+
+	rows, err = system.query("show tables")
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system FlatFile) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	// flat files have no statistics, so rows are counted
+
+	rows, err = system.query(fmt.Sprintf("select count(*) from %v", system.escape(table)))
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system FlatFile) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	return nil, fmt.Errorf("%v files do not have primary keys", system.Format)
+}
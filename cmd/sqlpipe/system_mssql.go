@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"errors"
@@ -9,8 +10,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/msdsn"
 )
 
 type Mssql struct {
@@ -53,6 +58,11 @@ func (system Mssql) queryRow(query string) (row *sql.Row) {
 	return row
 }
 
+func (system Mssql) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	row = system.Connection.QueryRowContext(ctx, query)
+	return row
+}
+
 func (system Mssql) exec(query string) (err error) {
 	_, err = system.Connection.Exec(query)
 	if err != nil {
@@ -65,7 +75,51 @@ func (system Mssql) dropTableIfExistsOverride(schema, table string) (overridden
 	return false, nil
 }
 
-func (system Mssql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, incremental bool) (overridden bool, err error) {
+func (system Mssql) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Mssql) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	err = system.exec(fmt.Sprintf("exec sp_rename '%v', '%v'", escapedSchemaPeriodTable, newTable))
+	if err != nil {
+		return true, fmt.Errorf("error renaming table %v to %v :: %v", escapedSchemaPeriodTable, newTable, err)
+	}
+
+	return true, nil
+}
+
+func (system Mssql) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// both renames run in one transaction, so readers see the old table until the commit
+
+	query := fmt.Sprintf("set xact_abort on; begin transaction; exec sp_rename '%v', '%v'; exec sp_rename '%v', '%v'; commit transaction;",
+		getSchemaPeriodTable(schema, table, system, true),
+		oldTable,
+		getSchemaPeriodTable(schema, stagingTable, system, true),
+		table,
+	)
+
+	err = system.exec(query)
+	if err != nil {
+		return true, fmt.Errorf("error swapping tables :: %v", err)
+	}
+
+	err = dropTableIfExists(schema, oldTable, system)
+	if err != nil {
+		return true, fmt.Errorf("error dropping old target table :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Mssql) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+	// sql server requires merge statements to end with a semicolon
+	return true, system.exec(getMergeQuery(schema, stagingTable, table, columnInfos, system) + ";")
+}
+
+func (system Mssql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
 
 	unescapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
 	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
@@ -106,7 +160,7 @@ func (system Mssql) createTableIfNotExistsOverride(schema, table string, columnI
 		queryBuilder.WriteString(createType)
 	}
 
-	if incremental && len(escapedPrimaryKeys) > 0 {
+	if addPrimaryKey && len(escapedPrimaryKeys) > 0 {
 		queryBuilder.WriteString(", primary key (")
 		queryBuilder.WriteString(strings.Join(escapedPrimaryKeys, ","))
 		queryBuilder.WriteString(")")
@@ -505,7 +559,132 @@ func (system Mssql) getPipeFileFormatters() (
 }
 
 func (system Mssql) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
-	return false, nil
+	// the native loader bulk copies pipe files straight into mssql, skipping the bcp csvs
+
+	if transfer.Loader != LoaderNative {
+		return false, nil
+	}
+
+	for i := range columnInfo {
+		switch columnInfo[i].PipeType {
+		case "money", "xml":
+			return true, fmt.Errorf("the native mssql loader does not support %v columns, use the external loader", columnInfo[i].PipeType)
+		}
+	}
+
+	for pipeFileInfo := range pipeFileInfoChannel {
+
+		select {
+		case <-transfer.Context.Done():
+			return true, errors.New("context cancelled")
+		default:
+		}
+
+		loadStart := time.Now()
+
+		err = system.bulkCopy(pipeFileInfo, transfer, columnInfo)
+		if err != nil {
+			return true, fmt.Errorf("error bulk copying pipe file :: %v", err)
+		}
+
+		recordStageDuration(transfer, StageLoad, loadStart)
+		recordFinalCsvInserted(transfer)
+		transfer.Progress.addLoadedFile(pipeFileInfo.Rows)
+
+		if pipeFileInfo.PkFilePath != "" {
+			os.Remove(pipeFileInfo.PkFilePath)
+		}
+
+		if !transfer.KeepFiles {
+			err = os.Remove(pipeFileInfo.FilePath)
+			if err != nil {
+				return true, fmt.Errorf("error removing pipe file :: %v", err)
+			}
+		}
+	}
+
+	infoLog.Printf("transfer %v finished bulk copying pipe files", transfer.Id)
+
+	return true, nil
+}
+
+func (system Mssql) bulkCopy(pipeFileInfo PipeFileInfo, transfer Transfer, columnInfos []ColumnInfo) (err error) {
+
+	pipeFile, err := os.Open(pipeFileInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening pipe file :: %v", err)
+	}
+	defer pipeFile.Close()
+
+	conn, err := system.Connection.Conn(transfer.Context)
+	if err != nil {
+		return fmt.Errorf("error getting mssql connection :: %v", err)
+	}
+	defer conn.Close()
+
+	columnNames := make([]string, len(columnInfos))
+	for i := range columnInfos {
+		columnNames[i] = columnInfos[i].Name
+	}
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(transfer.TargetSchema, getLoadTable(transfer), system, true)
+
+	stmt, err := conn.PrepareContext(transfer.Context, mssql.CopyIn(escapedSchemaPeriodTable, mssql.BulkOptions{}, columnNames...))
+	if err != nil {
+		return fmt.Errorf("error preparing bulk copy :: %v", err)
+	}
+	defer stmt.Close()
+
+	csvReader := csv.NewReader(pipeFile)
+	values := make([]interface{}, len(columnInfos))
+
+	for {
+		row, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error reading pipe file :: %v", err)
+		}
+
+		for i := range row {
+			if row[i] == transfer.Null {
+				values[i] = nil
+				continue
+			}
+
+			if columnInfos[i].PipeType == "uuid" {
+				var uniqueIdentifier mssql.UniqueIdentifier
+				err = uniqueIdentifier.Scan(row[i])
+				if err != nil {
+					return fmt.Errorf("error converting uuid value :: %v", err)
+				}
+				values[i], err = uniqueIdentifier.Value()
+				if err != nil {
+					return fmt.Errorf("error converting uuid value :: %v", err)
+				}
+				continue
+			}
+
+			values[i], err = pipeFileValueToGo(columnInfos[i].PipeType, row[i])
+			if err != nil {
+				return fmt.Errorf("error converting value for column %v :: %v", columnInfos[i].Name, err)
+			}
+		}
+
+		_, err = stmt.ExecContext(transfer.Context, values...)
+		if err != nil {
+			return fmt.Errorf("error adding row to bulk copy :: %v", err)
+		}
+	}
+
+	// an exec with no values flushes the bulk copy
+	_, err = stmt.ExecContext(transfer.Context)
+	if err != nil {
+		return fmt.Errorf("error flushing bulk copy :: %v", err)
+	}
+
+	return nil
 }
 
 func (system Mssql) convertPipeFilesOverride(
@@ -526,6 +705,8 @@ func (system Mssql) convertPipeFilesOverride(
 
 		for pipeFileInfo := range pipeFileInfoChannel {
 
+			conversionStart := time.Now()
+
 			pipeFile, err := os.Open(pipeFileInfo.FilePath)
 			if err != nil {
 				transfer.Error = fmt.Sprintf("error opening pipeFile :: %v", err)
@@ -616,6 +797,8 @@ func (system Mssql) convertPipeFilesOverride(
 				}
 			}
 
+			recordStageDuration(transfer, StageConversion, conversionStart)
+
 			select {
 			case <-transfer.Context.Done():
 				return
@@ -623,9 +806,11 @@ func (system Mssql) convertPipeFilesOverride(
 				finalCsvInfo := FinalCsvInfo{
 					FilePath:   finalCsvFile.Name(),
 					InsertInfo: finalCsvFile.Name(),
+					Rows:       pipeFileInfo.Rows,
 				}
 
 				finalCsvChannel <- finalCsvInfo
+				transfer.Progress.addFinalCsv()
 			}
 
 		}
@@ -749,6 +934,75 @@ func (system Mssql) getFinalCsvFormatters() map[string]func(string) (string, err
 	}
 }
 
+type bcpConnection struct {
+	// server is what bcp's -S takes, a host with an instance or port, then odbc settings
+	Server   string
+	Database string
+	Username string
+	Password string
+}
+
+func getBcpConnection(connectionInfo ConnectionInfo) (connection bcpConnection, err error) {
+	// reads what bcp needs from the connection string, in the url or ado format the driver
+	// takes. the target hostname, port, database, username, and password fields override it
+
+	config, err := msdsn.Parse(connectionInfo.ConnectionString)
+	if err != nil {
+		return connection, fmt.Errorf("error parsing mssql connection string :: %v", getUrlParseReason(err))
+	}
+
+	host := config.Host
+	port := config.Port
+	if connectionInfo.Hostname != "" {
+		host = connectionInfo.Hostname
+	}
+	if connectionInfo.Port != 0 {
+		port = uint64(connectionInfo.Port)
+	}
+
+	connection.Server = host
+	// hostnames used to carry the port and settings, like 127.0.0.1,1433;TrustServerCertificate=yes
+	if !strings.ContainsAny(host, ",;") {
+		if config.Instance != "" && connectionInfo.Hostname == "" {
+			connection.Server += `\` + config.Instance
+		}
+		if port != 0 {
+			connection.Server += fmt.Sprintf(",%v", port)
+		}
+	}
+
+	settings := strings.ToLower(connection.Server)
+	if trust, err := strconv.ParseBool(config.Parameters[msdsn.TrustServerCertificate]); err == nil && trust && !strings.Contains(settings, "trustservercertificate") {
+		connection.Server += ";TrustServerCertificate=yes"
+	}
+	if encrypt, ok := config.Parameters[msdsn.Encrypt]; ok && !strings.Contains(settings, "encrypt") {
+		switch strings.ToLower(encrypt) {
+		case "true", "yes", "mandatory":
+			connection.Server += ";Encrypt=yes"
+		case "false", "no", "optional", "disable":
+			connection.Server += ";Encrypt=no"
+		case "strict":
+			connection.Server += ";Encrypt=strict"
+		}
+	}
+
+	connection.Database = config.Database
+	connection.Username = config.User
+	connection.Password = config.Password
+
+	if connectionInfo.Database != "" {
+		connection.Database = connectionInfo.Database
+	}
+	if connectionInfo.Username != "" {
+		connection.Username = connectionInfo.Username
+	}
+	if connectionInfo.Password != "" {
+		connection.Password = connectionInfo.Password
+	}
+
+	return connection, nil
+}
+
 func (system Mssql) runInsertCmd(
 	finalCsvInfo FinalCsvInfo,
 	transfer Transfer,
@@ -764,18 +1018,23 @@ func (system Mssql) runInsertCmd(
 
 	escapedSchemaPeriodtable := getSchemaPeriodTable(schema, table, system, true)
 
+	connection, err := getBcpConnection(transfer.TargetConnectionInfo)
+	if err != nil {
+		return err
+	}
+
 	cmd := exec.CommandContext(
 		transfer.Context,
 		"bcp",
 		fmt.Sprintf("%v.%v",
-			transfer.TargetConnectionInfo.Database, escapedSchemaPeriodtable,
+			connection.Database, escapedSchemaPeriodtable,
 		),
 		"in",
 		finalCsvInfo.FilePath,
 		"-c",
-		"-S", transfer.TargetConnectionInfo.Hostname,
-		"-U", transfer.TargetConnectionInfo.Username,
-		"-P", transfer.TargetConnectionInfo.Password,
+		"-S", connection.Server,
+		"-U", connection.Username,
+		"-P", connection.Password,
 		"-t", transfer.Delimiter,
 		"-r", transfer.Newline,
 		"-e", filepath.Join(transfer.FinalCsvDir, fmt.Sprintf("%032b.err", fileNum)),
@@ -783,6 +1042,7 @@ func (system Mssql) runInsertCmd(
 
 	result, err := cmd.CombinedOutput()
 	if err != nil {
+		recordLoaderFailure(transfer, "bcp")
 		return fmt.Errorf(
 			"failed to upload csv to mssql :: stderr %v :: stdout %s", err, string(result),
 		)
@@ -1197,6 +1457,31 @@ func (system Mssql) getIncrementalTimeOverride(schema, table, incrementalColumn
 	return time.Time{}, false, initialLoad, nil
 }
 
+func (system Mssql) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	// sql server sums integers in the column's own type, which overflows on large tables
+
+	escapedColumn := escapeIfNeeded(columnInfo.Name, system)
+
+	switch kind {
+	case ChecksumSum:
+		switch columnInfo.PipeType {
+		case "int16", "int32", "int64":
+			return fmt.Sprintf("sum(cast(%v as decimal(38,0)))", escapedColumn), true
+		}
+		return "", false
+	case ChecksumSumLengths:
+		// len ignores trailing spaces, so a character is added and taken away again
+		return fmt.Sprintf("sum(cast(len(%v + 'x') - 1 as bigint))", escapedColumn), true
+	default:
+		return fmt.Sprintf("count_big(%v)", escapedColumn), true
+	}
+}
+
+func (system Mssql) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// the database must have allow_snapshot_isolation on, or the first read fails
+	return system.Connection.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSnapshot})
+}
+
 func (system Mssql) IsTableNotFoundError(err error) bool {
 	return strings.Contains(err.Error(), "Invalid object name")
 }
@@ -1359,3 +1644,44 @@ func (system Mssql) getTableColumnInfosRows(schema, table string) (rows *sql.Row
 
 	return rows, nil
 }
+
+func (system Mssql) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			TABLE_NAME
+		FROM
+			INFORMATION_SCHEMA.TABLES
+		WHERE
+			TABLE_SCHEMA = '%v'
+			AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY
+			TABLE_NAME;`, schema)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Mssql) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			SUM(p.rows)
+		FROM
+			sys.partitions p
+			JOIN sys.tables t ON t.object_id = p.object_id
+			JOIN sys.schemas s ON s.schema_id = t.schema_id
+		WHERE
+			s.name = '%v'
+			AND t.name = '%v'
+			AND p.index_id IN (0, 1);`, schema, table)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -50,6 +51,11 @@ func (system Mysql) queryRow(query string) (row *sql.Row) {
 	return row
 }
 
+func (system Mysql) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	row = system.Connection.QueryRowContext(ctx, query)
+	return row
+}
+
 func (system Mysql) exec(query string) (err error) {
 	_, err = system.Connection.Exec(query)
 	if err != nil {
@@ -62,7 +68,62 @@ func (system Mysql) dropTableIfExistsOverride(schema, table string) (overridden
 	return false, nil
 }
 
-func (system Mysql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, incremental bool) (overridden bool, err error) {
+func (system Mysql) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Mysql) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Mysql) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// renames in a single rename table statement happen atomically
+
+	query := fmt.Sprintf("rename table %v to %v, %v to %v",
+		escapeIfNeeded(table, system),
+		escapeIfNeeded(oldTable, system),
+		escapeIfNeeded(stagingTable, system),
+		escapeIfNeeded(table, system),
+	)
+
+	err = system.exec(query)
+	if err != nil {
+		return true, fmt.Errorf("error swapping tables :: %v", err)
+	}
+
+	err = dropTableIfExists(schema, oldTable, system)
+	if err != nil {
+		return true, fmt.Errorf("error dropping old target table :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Mysql) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+
+	escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns := getUpsertColumns(columnInfos, system)
+
+	// assigning a key to itself makes rows that are already there a no op
+	set := []string{fmt.Sprintf("%v = %v", escapedPrimaryKeys[0], escapedPrimaryKeys[0])}
+	if len(escapedNonKeyColumns) > 0 {
+		set = []string{}
+		for _, column := range escapedNonKeyColumns {
+			set = append(set, fmt.Sprintf("%v = values(%v)", column, column))
+		}
+	}
+
+	query := fmt.Sprintf("insert into %v (%v) select %v from %v on duplicate key update %v",
+		escapeIfNeeded(table, system),
+		strings.Join(escapedColumns, ", "),
+		strings.Join(escapedColumns, ", "),
+		escapeIfNeeded(stagingTable, system),
+		strings.Join(set, ", "),
+	)
+
+	return true, system.exec(query)
+}
+
+func (system Mysql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
 	return false, nil
 }
 
@@ -623,6 +684,19 @@ func (system Mysql) getIncrementalTimeOverride(schema, table, incrementalColumn
 	return time.Time{}, false, initialLoad, nil
 }
 
+func (system Mysql) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	// length counts bytes in mysql
+	if kind == ChecksumSumLengths {
+		return fmt.Sprintf("sum(char_length(%v))", escapeIfNeeded(columnInfo.Name, system)), true
+	}
+	return "", false
+}
+
+func (system Mysql) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// innodb's repeatable read takes a snapshot with the first read, and keeps it until commit
+	return system.Connection.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
 func (system Mysql) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
 
 	unescapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, false)
@@ -778,3 +852,52 @@ func (system Mysql) getTableColumnInfosRows(schema, table string) (rows *sql.Row
 
 	return rows, nil
 }
+
+func (system Mysql) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	// mysql doesn't have schemas, so tables come from the named database or the current one
+	tableSchema := "DATABASE()"
+	if schema != "" {
+		tableSchema = fmt.Sprintf("'%v'", schema)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			TABLE_NAME
+		FROM
+			information_schema.TABLES
+		WHERE
+			TABLE_SCHEMA = %v
+			AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY
+			TABLE_NAME;`, tableSchema)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Mysql) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	tableSchema := "DATABASE()"
+	if schema != "" {
+		tableSchema = fmt.Sprintf("'%v'", schema)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			TABLE_ROWS
+		FROM
+			information_schema.TABLES
+		WHERE
+			TABLE_SCHEMA = %v
+			AND TABLE_NAME = '%v';`, tableSchema, table)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
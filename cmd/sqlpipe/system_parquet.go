@@ -0,0 +1,979 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v12/parquet"
+	"github.com/apache/arrow/go/v12/parquet/compress"
+	"github.com/apache/arrow/go/v12/parquet/file"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+	"github.com/apache/arrow/go/v12/parquet/schema"
+	"github.com/google/uuid"
+)
+
+// a parquet system is a directory of parquet files, one per table. files are read through the
+// parquet driver, and written here straight from pipe files, so every pipe type gets a typed
+// parquet column. parquet files can't be changed in place, so a load writes a whole file, next
+// to the old one, and renames it over the old one when it's done
+
+var parquetDefaultRowGroupSize = 100000
+
+type Parquet struct {
+	Name         string
+	Connection   *sql.DB
+	Directory    string
+	RowGroupSize int
+}
+
+func (system Parquet) getSystemName() (name string) {
+	return system.Name
+}
+
+func newParquet(connectionInfo ConnectionInfo) (parquetSystem Parquet, err error) {
+
+	// the directory is created for targets, and a source in a missing directory has no tables
+	err = os.MkdirAll(connectionInfo.ConnectionString, 0755)
+	if err != nil {
+		return parquetSystem, fmt.Errorf("error creating parquet directory :: %v", err)
+	}
+
+	db, err := openConnectionPool(connectionInfo.Name, connectionInfo.ConnectionString, DriverParquet)
+	if err != nil {
+		return parquetSystem, fmt.Errorf("error opening parquet directory :: %v", err)
+	}
+
+	parquetSystem.Connection = db
+	parquetSystem.Name = connectionInfo.Name
+	parquetSystem.Directory = connectionInfo.ConnectionString
+	parquetSystem.RowGroupSize = connectionInfo.RowGroupSize
+
+	if parquetSystem.RowGroupSize == 0 {
+		parquetSystem.RowGroupSize = parquetDefaultRowGroupSize
+	}
+
+	return parquetSystem, nil
+}
+
+func (system Parquet) closeConnectionPool(printError bool) (err error) {
+	err = system.Connection.Close()
+	if err != nil && printError {
+		errorLog.Printf("error closing %v connection pool :: %v", system.Name, err)
+	}
+	return err
+}
+
+func (system Parquet) query(query string) (rows *sql.Rows, err error) {
+	rows, err = system.Connection.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.Name, query, err)
+	}
+	return rows, nil
+}
+
+func (system Parquet) queryRow(query string) (row *sql.Row) {
+	return system.Connection.QueryRow(query)
+}
+
+func (system Parquet) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	return system.Connection.QueryRowContext(ctx, query)
+}
+
+func (system Parquet) exec(query string) (err error) {
+	_, err = system.Connection.Exec(query)
+	if err != nil {
+		return fmt.Errorf("error running ddl/dml on %v :: %v :: %v", system.Name, query, err)
+	}
+	return nil
+}
+
+func (system Parquet) getFilePath(table string) (path string, err error) {
+	return getTableFilePath(system.Directory, table, parquetFileExtension)
+}
+
+func (system Parquet) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return true, fmt.Errorf("error removing parquet file :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Parquet) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	// replaces the file with one that has the same columns and no rows
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	reader, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return true, fmt.Errorf("error opening parquet file :: %v", err)
+	}
+	root := reader.MetaData().Schema.Root()
+	reader.Close()
+
+	err = replaceFile(path, func(sink *os.File) error {
+		return file.NewParquetWriter(sink, root).Close()
+	})
+	if err != nil {
+		return true, fmt.Errorf("error truncating parquet file :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Parquet) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	newPath, err := system.getFilePath(newTable)
+	if err != nil {
+		return true, err
+	}
+
+	err = os.Rename(path, newPath)
+	if err != nil {
+		return true, fmt.Errorf("error renaming parquet file :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Parquet) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// a rename replaces the target file in one step, so there's no old table to drop
+	return system.renameTableOverride(schema, stagingTable, table)
+}
+
+func (system Parquet) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+	return true, errors.New("upserts are not supported for parquet targets")
+}
+
+func (system Parquet) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+	// creating a table writes a file with its columns and no rows
+
+	path, err := system.getFilePath(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return true, fmt.Errorf("error checking for parquet file :: %v", err)
+	}
+
+	err = replaceFile(path, func(sink *os.File) error {
+		writer, err := newParquetFileWriter(sink, columnInfos, system)
+		if err != nil {
+			return err
+		}
+		return writer.close()
+	})
+	if err != nil {
+		return true, fmt.Errorf("error creating parquet file :: %v", err)
+	}
+
+	infoLog.Printf("parquet file %v created", path)
+
+	return true, nil
+}
+
+func (system Parquet) createSchemaIfNotExistsOverride(schema string) (overridden bool, err error) {
+	return true, nil
+}
+
+func (system Parquet) dbTypeToPipeType(
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	// the driver names types after each column's parquet logical type, or its physical type
+	// if it has none, like DECIMAL(18,3), TIMESTAMPTZ, or INT64
+
+	switch getParquetBaseType(databaseTypeName) {
+	case "BOOLEAN":
+		return "bool", nil
+	case "INT8", "INT16", "UINT8":
+		return "int16", nil
+	case "INT32", "UINT16":
+		return "int32", nil
+	case "INT64", "UINT32":
+		return "int64", nil
+	case "UINT64":
+		return "decimal", nil
+	case "FLOAT":
+		return "float32", nil
+	case "DOUBLE":
+		return "float64", nil
+	case "DECIMAL":
+		return "decimal", nil
+	case "STRING":
+		return "ntext", nil
+	case "JSON", "NESTED":
+		return "json", nil
+	case "UUID":
+		return "uuid", nil
+	case "BINARY":
+		return "blob", nil
+	case "DATE":
+		return "date", nil
+	case "TIME":
+		return "time", nil
+	case "TIMESTAMP", "INT96":
+		return "datetime", nil
+	case "TIMESTAMPTZ":
+		return "datetimetz", nil
+	default:
+		return "", fmt.Errorf("unsupported database type for parquet: %v", databaseTypeName)
+	}
+}
+
+func (system Parquet) driverTypeToPipeType(
+	columnType *sql.ColumnType,
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	return system.dbTypeToPipeType(databaseTypeName)
+}
+
+func (system Parquet) pipeTypeToCreateType(columnInfo ColumnInfo) (createType string, err error) {
+	// names the parquet type each pipe type is written as, the way the driver reads it back
+
+	switch columnInfo.PipeType {
+	case "nvarchar":
+		return "STRING", nil
+	case "varchar":
+		return "STRING", nil
+	case "ntext":
+		return "STRING", nil
+	case "text":
+		return "STRING", nil
+	case "int64":
+		return "INT64", nil
+	case "int32":
+		return "INT32", nil
+	case "int16":
+		return "INT16", nil
+	case "float64":
+		return "DOUBLE", nil
+	case "float32":
+		return "FLOAT", nil
+	case "decimal":
+		// parquet decimals are read back as 128 bit decimals, which hold up to 38 digits
+		if columnInfo.DecimalOk && columnInfo.Precision > 0 && columnInfo.Precision <= 38 && columnInfo.Scale >= 0 && columnInfo.Scale <= columnInfo.Precision {
+			return fmt.Sprintf("DECIMAL(%v,%v)", columnInfo.Precision, columnInfo.Scale), nil
+		}
+		return "DOUBLE", nil
+	case "money":
+		return "DECIMAL(19,4)", nil
+	case "datetime":
+		return "TIMESTAMP", nil
+	case "datetimetz":
+		return "TIMESTAMPTZ", nil
+	case "date":
+		return "DATE", nil
+	case "time":
+		return "TIME", nil
+	case "varbinary":
+		return "BINARY", nil
+	case "blob":
+		return "BINARY", nil
+	case "uuid":
+		return "UUID", nil
+	case "bool":
+		return "BOOLEAN", nil
+	case "json":
+		return "JSON", nil
+	case "xml":
+		return "STRING", nil
+	case "varbit":
+		return "STRING", nil
+	default:
+		return "", fmt.Errorf("unsupported pipeType for parquet: %v", columnInfo.PipeType)
+	}
+}
+
+func getParquetNode(name, createType string) (node *schema.PrimitiveNode, err error) {
+	// every column is optional, since pipe files can hold nulls for any column
+
+	optional := parquet.Repetitions.Optional
+
+	switch getParquetBaseType(createType) {
+	case "STRING":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.StringLogicalType{}, parquet.Types.ByteArray, -1, -1)
+	case "JSON":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.JSONLogicalType{}, parquet.Types.ByteArray, -1, -1)
+	case "INT64":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewIntLogicalType(64, true), parquet.Types.Int64, -1, -1)
+	case "INT32":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewIntLogicalType(32, true), parquet.Types.Int32, -1, -1)
+	case "INT16":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewIntLogicalType(16, true), parquet.Types.Int32, -1, -1)
+	case "DOUBLE":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NoLogicalType{}, parquet.Types.Double, -1, -1)
+	case "FLOAT":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NoLogicalType{}, parquet.Types.Float, -1, -1)
+	case "DECIMAL":
+		precision, scale, ok := getParquetPrecisionScale(createType)
+		if !ok {
+			return nil, fmt.Errorf("parquet type %v has no precision and scale", createType)
+		}
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewDecimalLogicalType(int32(precision), int32(scale)),
+			parquet.Types.FixedLenByteArray, int(pqarrow.DecimalSize(int32(precision))), -1)
+	case "TIMESTAMP":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewTimestampLogicalType(false, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "TIMESTAMPTZ":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewTimestampLogicalType(true, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "DATE":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.DateLogicalType{}, parquet.Types.Int32, -1, -1)
+	case "TIME":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NewTimeLogicalType(false, schema.TimeUnitMicros), parquet.Types.Int64, -1, -1)
+	case "BINARY":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NoLogicalType{}, parquet.Types.ByteArray, -1, -1)
+	case "UUID":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.UUIDLogicalType{}, parquet.Types.FixedLenByteArray, 16, -1)
+	case "BOOLEAN":
+		return schema.NewPrimitiveNodeLogical(name, optional, schema.NoLogicalType{}, parquet.Types.Boolean, -1, -1)
+	default:
+		return nil, fmt.Errorf("unsupported parquet type %v", createType)
+	}
+}
+
+func (system Parquet) createPipeFilesOverride(pipeFileChannelIn chan PipeFileInfo, columnInfo []ColumnInfo, transfer Transfer, rows *sql.Rows,
+) (pipeFileInfoChannel chan PipeFileInfo, overridden bool) {
+	return pipeFileChannelIn, false
+}
+
+func (system Parquet) getPipeFileFormatters() (
+	pipeFileFormatters map[string]func(interface{}) (pipeFileValue string, err error),
+) {
+	return map[string]func(interface{}) (pipeFileValue string, err error){
+		"nvarchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varchar": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"ntext": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"text": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"int64": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int32": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"int16": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%d", v), nil
+		},
+		"float64": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float64 parquetPipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 64), nil
+		},
+		"float32": func(v interface{}) (pipeFileValue string, err error) {
+			valFloat, ok := v.(float64)
+			if !ok {
+				return "", errors.New("non float64 value passed to float32 parquetPipeFileFormatters")
+			}
+			return strconv.FormatFloat(valFloat, 'g', -1, 32), nil
+		},
+		"decimal": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"money": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"datetime": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetime parquetPipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"datetimetz": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to datetimetz parquetPipeFileFormatters")
+			}
+			return valTime.UTC().Format(time.RFC3339Nano), nil
+		},
+		"date": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to date parquetPipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"time": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, ok := v.(time.Time)
+			if !ok {
+				return "", errors.New("non time.Time value passed to time parquetPipeFileFormatters")
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"varbinary": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"blob": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"uuid": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"bool": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%t", v), nil
+		},
+		"json": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"xml": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+		"varbit": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprint(v), nil
+		},
+	}
+}
+
+func (system Parquet) getSqlFormatters() (
+	sqlFormatters map[string]func(string) (sqlValue string, err error),
+) {
+	// the driver doesn't filter rows, so these are only here to satisfy the system interface
+
+	quoted := func(v string) (sqlValue string, err error) {
+		return fmt.Sprintf("'%v'", singleQuoteReplacer.Replace(v)), nil
+	}
+
+	sqlFormatters = map[string]func(string) (sqlValue string, err error){}
+	for pipeType := range system.getFinalCsvFormatters() {
+		sqlFormatters[pipeType] = quoted
+	}
+
+	return sqlFormatters
+}
+
+func (system Parquet) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+	// writes every pipe file into one parquet file, which replaces the load table's file when
+	// it's complete. the load table must not have rows yet, since parquet files can't be appended to
+
+	path, err := system.getFilePath(getLoadTable(transfer))
+	if err != nil {
+		return true, err
+	}
+
+	reader, err := file.OpenParquetFile(path, false)
+	if err == nil {
+		rows := reader.NumRows()
+		reader.Close()
+		if rows > 0 {
+			return true, fmt.Errorf("parquet file %v already has %v rows, and parquet files can't be appended to", path, rows)
+		}
+	}
+
+	err = replaceFile(path, func(sink *os.File) error {
+
+		writer, err := newParquetFileWriter(sink, columnInfo, system)
+		if err != nil {
+			return err
+		}
+
+		for pipeFileInfo := range pipeFileInfoChannel {
+
+			select {
+			case <-transfer.Context.Done():
+				return errors.New("context cancelled")
+			default:
+			}
+
+			loadStart := time.Now()
+
+			err = writer.writePipeFile(pipeFileInfo, transfer)
+			if err != nil {
+				return fmt.Errorf("error writing pipe file to parquet :: %v", err)
+			}
+
+			recordStageDuration(transfer, StageLoad, loadStart)
+			recordFinalCsvInserted(transfer)
+			transfer.Progress.addLoadedFile(pipeFileInfo.Rows)
+
+			if pipeFileInfo.PkFilePath != "" {
+				os.Remove(pipeFileInfo.PkFilePath)
+			}
+
+			if !transfer.KeepFiles {
+				err = os.Remove(pipeFileInfo.FilePath)
+				if err != nil {
+					return fmt.Errorf("error removing pipe file :: %v", err)
+				}
+			}
+		}
+
+		return writer.close()
+	})
+	if err != nil {
+		return true, err
+	}
+
+	infoLog.Printf("transfer %v finished writing parquet file %v", transfer.Id, path)
+
+	return true, nil
+}
+
+type parquetFileWriter struct {
+	writer       *file.Writer
+	columns      []*parquetColumn
+	rows         int
+	rowGroupSize int
+}
+
+func newParquetFileWriter(sink io.Writer, columnInfos []ColumnInfo, system Parquet) (writer *parquetFileWriter, err error) {
+
+	writer = &parquetFileWriter{rowGroupSize: system.RowGroupSize}
+	fields := schema.FieldList{}
+
+	for i := range columnInfos {
+		createType, err := system.pipeTypeToCreateType(columnInfos[i])
+		if err != nil {
+			return nil, err
+		}
+
+		node, err := getParquetNode(columnInfos[i].Name, createType)
+		if err != nil {
+			return nil, fmt.Errorf("error creating parquet column %v :: %v", columnInfos[i].Name, err)
+		}
+
+		fields = append(fields, node)
+		writer.columns = append(writer.columns, newParquetColumn(columnInfos[i], createType, node))
+	}
+
+	root, err := schema.NewGroupNode("schema", parquet.Repetitions.Required, fields, -1)
+	if err != nil {
+		return nil, fmt.Errorf("error creating parquet schema :: %v", err)
+	}
+
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	writer.writer = file.NewParquetWriter(sink, root, file.WithWriterProps(props))
+
+	return writer, nil
+}
+
+func (writer *parquetFileWriter) writePipeFile(pipeFileInfo PipeFileInfo, transfer Transfer) (err error) {
+
+	pipeFile, err := os.Open(pipeFileInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening pipe file :: %v", err)
+	}
+	defer pipeFile.Close()
+
+	csvReader := csv.NewReader(pipeFile)
+
+	for {
+		row, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading pipe file :: %v", err)
+		}
+
+		for i := range row {
+			err = writer.columns[i].add(row[i], row[i] == transfer.Null)
+			if err != nil {
+				return fmt.Errorf("error converting value for column %v :: %v", writer.columns[i].name, err)
+			}
+		}
+
+		writer.rows++
+
+		if writer.rows >= writer.rowGroupSize {
+			err = writer.writeRowGroup()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (writer *parquetFileWriter) writeRowGroup() (err error) {
+
+	rowGroupWriter := writer.writer.AppendRowGroup()
+
+	for _, column := range writer.columns {
+		columnWriter, err := rowGroupWriter.NextColumn()
+		if err != nil {
+			return fmt.Errorf("error starting parquet column %v :: %v", column.name, err)
+		}
+
+		err = column.write(columnWriter)
+		if err != nil {
+			return fmt.Errorf("error writing parquet column %v :: %v", column.name, err)
+		}
+	}
+
+	err = rowGroupWriter.Close()
+	if err != nil {
+		return fmt.Errorf("error closing parquet row group :: %v", err)
+	}
+
+	writer.rows = 0
+
+	return nil
+}
+
+func (writer *parquetFileWriter) close() (err error) {
+
+	if writer.rows > 0 {
+		err = writer.writeRowGroup()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.writer.Close()
+	if err != nil {
+		return fmt.Errorf("error closing parquet file :: %v", err)
+	}
+
+	return nil
+}
+
+type parquetColumn struct {
+	name       string
+	pipeType   string
+	createType string
+	scale      int64
+	size       int
+
+	// a row group's values, with a definition level of 0 for each null and 1 for each value
+	defLevels          []int16
+	bools              []bool
+	int32s             []int32
+	int64s             []int64
+	float32s           []float32
+	float64s           []float64
+	byteArrays         []parquet.ByteArray
+	fixedLenByteArrays []parquet.FixedLenByteArray
+}
+
+func newParquetColumn(columnInfo ColumnInfo, createType string, node *schema.PrimitiveNode) (column *parquetColumn) {
+
+	column = &parquetColumn{
+		name:       columnInfo.Name,
+		pipeType:   columnInfo.PipeType,
+		createType: getParquetBaseType(createType),
+		size:       node.TypeLength(),
+	}
+
+	_, column.scale, _ = getParquetPrecisionScale(createType)
+
+	return column
+}
+
+var parquetMoneyReplacer = strings.NewReplacer("$", "", ",", "")
+
+func (column *parquetColumn) add(value string, null bool) (err error) {
+
+	if null {
+		column.defLevels = append(column.defLevels, 0)
+		return nil
+	}
+
+	switch column.createType {
+	case "STRING", "JSON":
+		column.byteArrays = append(column.byteArrays, parquet.ByteArray(value))
+	case "BINARY":
+		bytes, err := hex.DecodeString(value)
+		if err != nil {
+			return err
+		}
+		column.byteArrays = append(column.byteArrays, bytes)
+	case "INT64":
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		column.int64s = append(column.int64s, parsed)
+	case "INT32", "INT16":
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		column.int32s = append(column.int32s, int32(parsed))
+	case "DOUBLE":
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		column.float64s = append(column.float64s, parsed)
+	case "FLOAT":
+		parsed, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return err
+		}
+		column.float32s = append(column.float32s, float32(parsed))
+	case "DECIMAL":
+		if column.pipeType == "money" {
+			value = parquetMoneyReplacer.Replace(value)
+		}
+		bytes, err := getParquetDecimalBytes(value, column.scale, column.size)
+		if err != nil {
+			return err
+		}
+		column.fixedLenByteArrays = append(column.fixedLenByteArrays, bytes)
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE", "TIME":
+		valTime, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return err
+		}
+		switch column.createType {
+		case "TIMESTAMP":
+			// timestamps without a time zone keep their wall clock time
+			wallTime := time.Date(valTime.Year(), valTime.Month(), valTime.Day(), valTime.Hour(), valTime.Minute(), valTime.Second(), valTime.Nanosecond(), time.UTC)
+			column.int64s = append(column.int64s, wallTime.UnixMicro())
+		case "TIMESTAMPTZ":
+			column.int64s = append(column.int64s, valTime.UnixMicro())
+		case "DATE":
+			days := time.Date(valTime.Year(), valTime.Month(), valTime.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+			column.int32s = append(column.int32s, int32(days))
+		case "TIME":
+			micros := int64(valTime.Hour())*int64(time.Hour/time.Microsecond) +
+				int64(valTime.Minute())*int64(time.Minute/time.Microsecond) +
+				int64(valTime.Second())*int64(time.Second/time.Microsecond) +
+				int64(valTime.Nanosecond())/int64(time.Microsecond)
+			column.int64s = append(column.int64s, micros)
+		}
+	case "UUID":
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return err
+		}
+		column.fixedLenByteArrays = append(column.fixedLenByteArrays, parquet.FixedLenByteArray(id[:]))
+	case "BOOLEAN":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		column.bools = append(column.bools, parsed)
+	default:
+		return fmt.Errorf("unsupported parquet type %v", column.createType)
+	}
+
+	column.defLevels = append(column.defLevels, 1)
+
+	return nil
+}
+
+func getParquetDecimalBytes(value string, scale int64, size int) (bytes []byte, err error) {
+	// parquet decimals are the unscaled value as a big endian two's complement integer,
+	// so 12.345 with a scale of 2 is 1235
+
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("%v is not a decimal", value)
+	}
+
+	rat.Mul(rat, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)))
+
+	// rounds half away from zero when the value has more digits than the scale
+	unscaled, remainder := new(big.Int).QuoRem(rat.Num(), rat.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(rat.Denom()) >= 0 {
+		unscaled.Add(unscaled, big.NewInt(int64(rat.Sign())))
+	}
+
+	if unscaled.BitLen() >= size*8 {
+		return nil, fmt.Errorf("%v is too big for its parquet decimal column", value)
+	}
+
+	if unscaled.Sign() < 0 {
+		unscaled.Add(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+
+	return unscaled.FillBytes(make([]byte, size)), nil
+}
+
+func (column *parquetColumn) write(columnWriter file.ColumnChunkWriter) (err error) {
+
+	switch columnWriter := columnWriter.(type) {
+	case *file.BooleanColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.bools, column.defLevels, nil)
+	case *file.Int32ColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.int32s, column.defLevels, nil)
+	case *file.Int64ColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.int64s, column.defLevels, nil)
+	case *file.Float32ColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.float32s, column.defLevels, nil)
+	case *file.Float64ColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.float64s, column.defLevels, nil)
+	case *file.ByteArrayColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.byteArrays, column.defLevels, nil)
+	case *file.FixedLenByteArrayColumnChunkWriter:
+		_, err = columnWriter.WriteBatch(column.fixedLenByteArrays, column.defLevels, nil)
+	default:
+		return fmt.Errorf("unexpected parquet column writer %T", columnWriter)
+	}
+
+	column.defLevels = column.defLevels[:0]
+	column.bools = column.bools[:0]
+	column.int32s = column.int32s[:0]
+	column.int64s = column.int64s[:0]
+	column.float32s = column.float32s[:0]
+	column.float64s = column.float64s[:0]
+	column.byteArrays = column.byteArrays[:0]
+	column.fixedLenByteArrays = column.fixedLenByteArrays[:0]
+
+	return err
+}
+
+func (system Parquet) convertPipeFilesOverride(pipeFilePath <-chan PipeFileInfo, finalCsvInfoChannelIn chan FinalCsvInfo, transfer Transfer, columnInfos []ColumnInfo,
+) (finalCsvInfoChannel chan FinalCsvInfo, overridden bool) {
+	return finalCsvInfoChannelIn, false
+}
+
+func (system Parquet) getFinalCsvFormatters() (
+	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error),
+) {
+	// pipe files are written to parquet without final csvs, so values pass through as they are
+
+	same := func(v string) (string, error) {
+		return v, nil
+	}
+
+	return map[string]func(string) (string, error){
+		"nvarchar":   same,
+		"varchar":    same,
+		"ntext":      same,
+		"text":       same,
+		"int64":      same,
+		"int32":      same,
+		"int16":      same,
+		"float64":    same,
+		"float32":    same,
+		"decimal":    same,
+		"money":      same,
+		"datetime":   same,
+		"datetimetz": same,
+		"date":       same,
+		"time":       same,
+		"varbinary":  same,
+		"blob":       same,
+		"uuid":       same,
+		"bool":       same,
+		"json":       same,
+		"xml":        same,
+		"varbit":     same,
+	}
+}
+
+func (system Parquet) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Parquet) runInsertCmd(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	schema, table string,
+) (
+	err error,
+) {
+	return errors.New("parquet targets are written from pipe files, not final csvs")
+}
+
+func (system Parquet) schemaRequired() bool {
+	return false
+}
+
+func (system Parquet) isReservedKeyword(keyword string) bool {
+	return false
+}
+
+func (system Parquet) escape(objectName string) (escaped string) {
+	return fmt.Sprintf(`"%v"`, strings.ReplaceAll(objectName, `"`, `""`))
+}
+
+func (system Parquet) IsTableNotFoundError(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || strings.Contains(err.Error(), "no such file or directory")
+}
+
+func (system Parquet) getIncrementalTimeOverride(schema, table, incrementalColumn string, initialLoad bool) (time.Time, bool, bool, error) {
+	return time.Time{}, true, initialLoad, errors.New("incremental transfers are not supported for parquet targets")
+}
+
+func (system Parquet) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	return "", false
+}
+
+func (system Parquet) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	return nil, errors.New("parquet sources can't be read in a snapshot")
+}
+
+func (system Parquet) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("change data capture is not supported for parquet sources")
+}
+
+func (system Parquet) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+
+	rows, err = system.query(fmt.Sprintf("describe %v", system.escape(table)))
+	if err != nil {
+		return nil, fmt.Errorf("error getting table column infos rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Parquet) getTablesRows(schema string) (rows *sql.Rows, err error) {
+
+	rows, err = system.query("show tables")
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Parquet) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	// parquet footers hold an exact row count, so there's nothing to estimate
+
+	rows, err = system.query(fmt.Sprintf("select count(*) from %v", system.escape(table)))
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Parquet) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	return nil, errors.New("parquet files do not have primary keys")
+}
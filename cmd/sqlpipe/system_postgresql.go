@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 type Postgresql struct {
@@ -50,6 +54,10 @@ func (system Postgresql) queryRow(query string) (row *sql.Row) {
 	return system.Connection.QueryRow(query)
 }
 
+func (system Postgresql) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	return system.Connection.QueryRowContext(ctx, query)
+}
+
 func (system Postgresql) exec(query string) (err error) {
 	_, err = system.Connection.Exec(query)
 	if err != nil {
@@ -62,6 +70,73 @@ func (system Postgresql) dropTableIfExistsOverride(schema, table string) (overri
 	return false, nil
 }
 
+func (system Postgresql) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Postgresql) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Postgresql) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// ddl is transactional in postgresql, so readers see the old table until the commit
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+	escapedSchemaPeriodStagingTable := getSchemaPeriodTable(schema, stagingTable, system, true)
+	escapedSchemaPeriodOldTable := getSchemaPeriodTable(schema, oldTable, system, true)
+
+	tx, err := system.Connection.Begin()
+	if err != nil {
+		return true, fmt.Errorf("error beginning swap transaction :: %v", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		fmt.Sprintf("alter table %v rename to %v", escapedSchemaPeriodTable, escapeIfNeeded(oldTable, system)),
+		fmt.Sprintf("alter table %v rename to %v", escapedSchemaPeriodStagingTable, escapeIfNeeded(table, system)),
+		fmt.Sprintf("drop table %v", escapedSchemaPeriodOldTable),
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(query)
+		if err != nil {
+			return true, fmt.Errorf("error running %v :: %v", query, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return true, fmt.Errorf("error committing swap transaction :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Postgresql) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+
+	escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns := getUpsertColumns(columnInfos, system)
+
+	onConflict := "do nothing"
+	if len(escapedNonKeyColumns) > 0 {
+		set := []string{}
+		for _, column := range escapedNonKeyColumns {
+			set = append(set, fmt.Sprintf("%v = excluded.%v", column, column))
+		}
+		onConflict = fmt.Sprintf("do update set %v", strings.Join(set, ", "))
+	}
+
+	query := fmt.Sprintf("insert into %v (%v) select %v from %v on conflict (%v) %v",
+		getSchemaPeriodTable(schema, table, system, true),
+		strings.Join(escapedColumns, ", "),
+		strings.Join(escapedColumns, ", "),
+		getSchemaPeriodTable(schema, stagingTable, system, true),
+		strings.Join(escapedPrimaryKeys, ", "),
+		onConflict,
+	)
+
+	return true, system.exec(query)
+}
+
 func (system Postgresql) driverTypeToPipeType(
 	columnType *sql.ColumnType,
 	databaseTypeName string,
@@ -613,6 +688,10 @@ func (system Postgresql) runInsertCmd(
 
 	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
 
+	if transfer.Loader == LoaderNative {
+		return system.copyFrom(finalCsvInfo, transfer, escapedSchemaPeriodTable)
+	}
+
 	copyCmd := fmt.Sprintf(`\copy %v FROM '%s' WITH 
 	(FORMAT csv, HEADER false, DELIMITER ',', QUOTE '"', ESCAPE '"', NULL '%v', ENCODING 'UTF8')`,
 		escapedSchemaPeriodTable, finalCsvInfo.FilePath, transfer.Null)
@@ -621,6 +700,7 @@ func (system Postgresql) runInsertCmd(
 
 	result, err := cmd.CombinedOutput()
 	if err != nil {
+		recordLoaderFailure(transfer, "psql")
 		return fmt.Errorf("failed to upload csv to postgresql :: stderr %v :: stdout %s",
 			err, string(result))
 	}
@@ -628,6 +708,47 @@ func (system Postgresql) runInsertCmd(
 	return nil
 }
 
+func (system Postgresql) copyFrom(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	escapedSchemaPeriodTable string,
+) (
+	err error,
+) {
+	// streams a final csv to postgresql over the copy protocol, so psql isn't needed
+
+	finalCsvFile, err := os.Open(finalCsvInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening final csv :: %v", err)
+	}
+	defer finalCsvFile.Close()
+
+	conn, err := system.Connection.Conn(transfer.Context)
+	if err != nil {
+		return fmt.Errorf("error getting postgresql connection :: %v", err)
+	}
+	defer conn.Close()
+
+	copyCmd := fmt.Sprintf(`COPY %v FROM STDIN WITH
+	(FORMAT csv, HEADER false, DELIMITER ',', QUOTE '"', ESCAPE '"', NULL '%v', ENCODING 'UTF8')`,
+		escapedSchemaPeriodTable, transfer.Null)
+
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("postgresql connection is not a pgx connection")
+		}
+
+		_, err := pgxConn.Conn().PgConn().CopyFrom(transfer.Context, finalCsvFile, copyCmd)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy csv to postgresql :: %v", err)
+	}
+
+	return nil
+}
+
 func (system Postgresql) schemaRequired() bool {
 	return true
 }
@@ -777,6 +898,45 @@ func (system Postgresql) getTableColumnInfosRows(schema, table string) (rows *sq
 	return rows, nil
 }
 
+func (system Postgresql) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			table_name
+		FROM
+			information_schema.tables
+		WHERE
+			table_schema = '%v'
+			AND table_type = 'BASE TABLE'
+		ORDER BY
+			table_name;`, schema)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Postgresql) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			c.reltuples::bigint
+		FROM
+			pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE
+			n.nspname = '%v'
+			AND c.relname = '%v';`, schema, table)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
 func (system Postgresql) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
 
 	unescapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, false)
@@ -808,7 +968,7 @@ func (system Postgresql) createSchemaIfNotExistsOverride(schema string) (overrid
 	return false, nil
 }
 
-func (system Postgresql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, incremental bool) (overridden bool, err error) {
+func (system Postgresql) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
 	return false, nil
 }
 
@@ -816,6 +976,19 @@ func (system Postgresql) getIncrementalTimeOverride(schema, table, incrementalCo
 	return time.Time{}, false, initialLoad, nil
 }
 
+func (system Postgresql) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	// some types that become text in other systems, like intervals and points, need a cast to get a length
+	if kind == ChecksumSumLengths {
+		return fmt.Sprintf("sum(length(%v::text))", escapeIfNeeded(columnInfo.Name, system)), true
+	}
+	return "", false
+}
+
+func (system Postgresql) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// every statement in a repeatable read transaction sees the snapshot its first one took
+	return system.Connection.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
 func (system Postgresql) IsTableNotFoundError(err error) bool {
 	return strings.Contains(err.Error(), "does not exist")
 }
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// an s3 target writes a file per table to a bucket prefix, as csv, json lines, or parquet. the
+// files are written by the csv, json lines, or parquet system, in a local directory that mirrors
+// the prefix. a table's file is downloaded the first time the transfer uses it, and uploaded after
+// every change, so write modes and verification work the way they do for local files. the
+// connection string is an s3 url, and its format setting picks the file type, csv by default.
+// settings s3 doesn't use are handed to the file system, like a csv delimiter
+
+var s3Formats = []string{TypeCsv, TypeJsonl, TypeParquet}
+
+type S3 struct {
+	System
+	Location  s3Location
+	Format    string
+	Directory string
+
+	// tables whose local file matches their object, or that have neither
+	synced *sync.Map
+}
+
+func parseS3ConnectionString(connectionString string) (location s3Location, format string, settings string, err error) {
+
+	location, values, err := newS3Location(connectionString)
+	if err != nil {
+		return location, "", "", err
+	}
+
+	format = TypeCsv
+	if values.Has("format") {
+		format = values.Get("format")
+		values.Del("format")
+	}
+
+	if !permittedValue(format, s3Formats...) {
+		return location, "", "", fmt.Errorf("s3 format setting must be one of %v", s3Formats)
+	}
+
+	if format == TypeParquet && len(values) > 0 {
+		return location, "", "", errors.New("s3 urls with the parquet format only take s3 settings")
+	}
+
+	return location, format, values.Encode(), nil
+}
+
+func newS3(connectionInfo ConnectionInfo) (s3System S3, err error) {
+
+	location, format, settings, err := parseS3ConnectionString(connectionInfo.ConnectionString)
+	if err != nil {
+		return s3System, err
+	}
+
+	directory, err := os.MkdirTemp(globalTmpDir, "s3-")
+	if err != nil {
+		return s3System, fmt.Errorf("error creating s3 mirror directory :: %v", err)
+	}
+
+	filesConnectionInfo := connectionInfo
+	filesConnectionInfo.Type = format
+	filesConnectionInfo.ConnectionString = directory
+	if settings != "" {
+		filesConnectionInfo.ConnectionString += "?" + settings
+	}
+
+	files, err := newSystem(filesConnectionInfo)
+	if err != nil {
+		os.RemoveAll(directory)
+		return s3System, fmt.Errorf("error creating %v system for s3 :: %v", format, err)
+	}
+
+	s3System.System = files
+	s3System.Location = location
+	s3System.Format = format
+	s3System.Directory = directory
+	s3System.synced = &sync.Map{}
+
+	return s3System, nil
+}
+
+func (system S3) closeConnectionPool(printError bool) (err error) {
+
+	err = system.System.closeConnectionPool(printError)
+
+	removeErr := os.RemoveAll(system.Directory)
+	if removeErr != nil && printError {
+		errorLog.Printf("error removing s3 mirror directory %v :: %v", system.Directory, removeErr)
+	}
+
+	return err
+}
+
+func (system S3) getFileName(table string) (fileName string, err error) {
+	// table names are checked the way the file system checks them, so keys can't leave the prefix
+	_, err = getTableFilePath(system.Directory, table, "."+system.Format)
+	if err != nil {
+		return "", err
+	}
+	return table + "." + system.Format, nil
+}
+
+func (system S3) fetch(table string) (err error) {
+	// downloads a table's object into the mirror directory, once per transfer
+
+	if _, ok := system.synced.Load(table); ok {
+		return nil
+	}
+
+	fileName, err := system.getFileName(table)
+	if err != nil {
+		return err
+	}
+
+	localPath := system.Directory + string(os.PathSeparator) + fileName
+
+	found, err := system.Location.download(context.Background(), system.Location.getKey(fileName), localPath)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		err = os.Remove(localPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing s3 mirror file :: %v", err)
+		}
+	}
+
+	system.synced.Store(table, true)
+
+	return nil
+}
+
+func (system S3) push(table string) (err error) {
+	// uploads a table's file, or deletes its object if the file is gone
+
+	fileName, err := system.getFileName(table)
+	if err != nil {
+		return err
+	}
+
+	localPath := system.Directory + string(os.PathSeparator) + fileName
+	key := system.Location.getKey(fileName)
+
+	_, err = os.Stat(localPath)
+	switch {
+	case err == nil:
+		err = system.Location.upload(context.Background(), key, localPath)
+	case errors.Is(err, os.ErrNotExist):
+		err = system.Location.delete(context.Background(), key)
+	default:
+		err = fmt.Errorf("error reading s3 mirror file :: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	system.synced.Store(table, true)
+
+	return nil
+}
+
+func (system S3) fetchQueryTable(query string) (err error) {
+	statement, table, err := parseFileStatement(query)
+	if err != nil || statement == fileStatementShowTables {
+		return nil
+	}
+	return system.fetch(table)
+}
+
+func (system S3) query(query string) (rows *sql.Rows, err error) {
+	err = system.fetchQueryTable(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.getSystemName(), query, err)
+	}
+	return system.System.query(query)
+}
+
+func (system S3) queryRow(query string) (row *sql.Row) {
+	err := system.fetchQueryTable(query)
+	if err != nil {
+		// the query then fails on the missing file, and returns an error through the row
+		errorLog.Printf("error downloading s3 file for %v :: %v", system.getSystemName(), err)
+	}
+	return system.System.queryRow(query)
+}
+
+func (system S3) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	err := system.fetchQueryTable(query)
+	if err != nil {
+		errorLog.Printf("error downloading s3 file for %v :: %v", system.getSystemName(), err)
+	}
+	return system.System.queryRowContext(ctx, query)
+}
+
+func (system S3) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+
+	overridden, err = system.System.dropTableIfExistsOverride(schema, table)
+	if err != nil {
+		return true, err
+	}
+
+	return true, system.push(table)
+}
+
+func (system S3) truncateTableOverride(schema, table string) (overridden bool, err error) {
+
+	err = system.fetch(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = system.System.truncateTableOverride(schema, table)
+	if err != nil {
+		return true, err
+	}
+
+	return true, system.push(table)
+}
+
+func (system S3) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	// s3 can't rename objects, so the renamed file is uploaded and the old object deleted
+
+	err = system.fetch(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = system.System.renameTableOverride(schema, table, newTable)
+	if err != nil {
+		return true, err
+	}
+
+	err = system.push(newTable)
+	if err != nil {
+		return true, err
+	}
+
+	return true, system.push(table)
+}
+
+func (system S3) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// uploading over the target's object replaces it in one step, so there's no old table to drop
+	return system.renameTableOverride(schema, stagingTable, table)
+}
+
+func (system S3) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+
+	err = system.fetch(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = system.System.createTableIfNotExistsOverride(schema, table, columnInfos, addPrimaryKey)
+	if err != nil {
+		return true, err
+	}
+
+	return true, system.push(table)
+}
+
+func (system S3) insertPipeFilesOverride(columnInfo []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+
+	table := getLoadTable(transfer)
+
+	err = system.fetch(table)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = system.System.insertPipeFilesOverride(columnInfo, transfer, pipeFileInfoChannel, vacuumTable)
+	if err != nil {
+		return true, err
+	}
+
+	err = system.push(table)
+	if err != nil {
+		return true, err
+	}
+
+	infoLog.Printf("transfer %v uploaded %v", transfer.Id, system.Location.getUrl(table+"."+system.Format))
+
+	return true, nil
+}
+
+func (system S3) IsTableNotFoundError(err error) bool {
+	return system.System.IsTableNotFoundError(err) || strings.Contains(err.Error(), "NoSuchKey")
+}
+
+func (system S3) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	// lists the mirror directory, which holds the tables this transfer has used. swaps upload
+	// over the target's object whether or not it's listed, so that's enough for a target
+	return system.System.getTablesRows(schema)
+}
+
+func (system S3) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+	return nil, errors.New("s3 is only supported as a target")
+}
+
+func (system S3) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	return nil, errors.New("s3 is only supported as a target")
+}
+
+func (system S3) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	return nil, errors.New("s3 is only supported as a target")
+}
+
+func (system S3) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("s3 is only supported as a target")
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -50,6 +53,11 @@ func (system Snowflake) queryRow(query string) (row *sql.Row) {
 	return row
 }
 
+func (system Snowflake) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	row = system.Connection.QueryRowContext(ctx, query)
+	return row
+}
+
 func (system Snowflake) exec(query string) (err error) {
 	_, err = system.Connection.Exec(query)
 	if err != nil {
@@ -62,6 +70,47 @@ func (system Snowflake) dropTableIfExistsOverride(schema, table string) (overrid
 	return false, nil
 }
 
+func (system Snowflake) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Snowflake) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	// an unqualified new name would move the table to the session's current schema
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+	escapedSchemaPeriodNewTable := getSchemaPeriodTable(schema, newTable, system, true)
+
+	err = system.exec(fmt.Sprintf("alter table %v rename to %v", escapedSchemaPeriodTable, escapedSchemaPeriodNewTable))
+	if err != nil {
+		return true, fmt.Errorf("error renaming table %v to %v :: %v", escapedSchemaPeriodTable, escapedSchemaPeriodNewTable, err)
+	}
+
+	return true, nil
+}
+
+func (system Snowflake) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// swap with exchanges the tables atomically, leaving the old rows in the staging table
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+	escapedSchemaPeriodStagingTable := getSchemaPeriodTable(schema, stagingTable, system, true)
+
+	err = system.exec(fmt.Sprintf("alter table %v swap with %v", escapedSchemaPeriodTable, escapedSchemaPeriodStagingTable))
+	if err != nil {
+		return true, fmt.Errorf("error swapping tables :: %v", err)
+	}
+
+	err = dropTableIfExists(schema, stagingTable, system)
+	if err != nil {
+		return true, fmt.Errorf("error dropping old target table :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Snowflake) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+	return false, nil
+}
+
 func (system Snowflake) escape(objectName string) (escaped string) {
 	return fmt.Sprintf(`"%v"`, objectName)
 }
@@ -74,7 +123,7 @@ func (system Snowflake) isReservedKeyword(keyword string) bool {
 	return false
 }
 
-func (system Snowflake) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, incremental bool) (overridden bool, err error) {
+func (system Snowflake) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
 	return false, nil
 }
 
@@ -273,7 +322,7 @@ func (system Snowflake) getPipeFileFormatters() (
 
 func (system Snowflake) insertPipeFilesOverride(columnInfos []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
 
-	table := transfer.TargetTable
+	table := getLoadTable(transfer)
 
 	escapedSchemaName := escapeIfNeeded(transfer.TargetSchema, system)
 
@@ -287,14 +336,21 @@ func (system Snowflake) insertPipeFilesOverride(columnInfos []ColumnInfo, transf
 	}
 	infoLog.Printf("created %v.sqlpipe_csv file format if not exists in snowflake", escapedSchemaName)
 
-	createStageQuery := fmt.Sprintf(
-		`CREATE STAGE if not exists %v.sqlpipe_stage;`,
-		escapedSchemaName)
-	err = system.exec(createStageQuery)
-	if err != nil {
-		return true, fmt.Errorf("error creating sqlpipe_stage in snowflake :: %v", err)
+	if transfer.StagingUrl != "" {
+		err = system.createExternalStage(transfer)
+		if err != nil {
+			return true, err
+		}
+	} else {
+		createStageQuery := fmt.Sprintf(
+			`CREATE STAGE if not exists %v.sqlpipe_stage;`,
+			escapedSchemaName)
+		err = system.exec(createStageQuery)
+		if err != nil {
+			return true, fmt.Errorf("error creating sqlpipe_stage in snowflake :: %v", err)
+		}
+		infoLog.Printf("created %v.sqlpipe_stage if not exists in snowflake", escapedSchemaName)
 	}
-	infoLog.Printf("created %v.sqlpipe_stage if not exists in snowflake", escapedSchemaName)
 
 	finalCsvChannel := convertPipeFiles(pipeFileInfoChannel, columnInfos, transfer, system)
 
@@ -308,6 +364,74 @@ func (system Snowflake) insertPipeFilesOverride(columnInfos []ColumnInfo, transf
 	return true, nil
 }
 
+func getSnowflakeStageName(transfer Transfer) (stageName string) {
+	// transfers with a staging url load from an external stage on it, named after the bucket
+	// and prefix, so transfers staging in different places don't replace each other's stage
+
+	if transfer.StagingUrl == "" {
+		return "sqlpipe_stage"
+	}
+
+	location, _, err := newS3Location(transfer.StagingUrl)
+	if err != nil {
+		return "sqlpipe_stage"
+	}
+
+	hash := sha256.Sum256([]byte(location.Bucket + "/" + location.Prefix))
+
+	return fmt.Sprintf("sqlpipe_s3_stage_%x", hash[:8])
+}
+
+func (system Snowflake) createExternalStage(transfer Transfer) (err error) {
+
+	location, _, err := newS3Location(transfer.StagingUrl)
+	if err != nil {
+		return fmt.Errorf("error opening staging location :: %v", err)
+	}
+
+	escapedSchemaName := escapeIfNeeded(transfer.TargetSchema, system)
+	stageName := getSnowflakeStageName(transfer)
+
+	stagePath := location.Bucket + "/"
+	if location.Prefix != "" {
+		stagePath += location.Prefix + "/"
+	}
+
+	stageUrl := fmt.Sprintf("URL = 's3://%v'", singleQuoteReplacer.Replace(stagePath))
+
+	// s3 compatible services are reached through an endpoint, without its scheme
+	if location.Endpoint != "" {
+		endpoint := location.Endpoint
+		if endpointUrl, err := url.Parse(endpoint); err == nil && endpointUrl.Host != "" {
+			endpoint = endpointUrl.Host
+		}
+		stageUrl = fmt.Sprintf("URL = 's3compat://%v' ENDPOINT = '%v'", singleQuoteReplacer.Replace(stagePath), singleQuoteReplacer.Replace(endpoint))
+	}
+
+	access := fmt.Sprintf("CREDENTIALS = (AWS_KEY_ID = '%v' AWS_SECRET_KEY = '%v'",
+		singleQuoteReplacer.Replace(location.AccessKeyId), singleQuoteReplacer.Replace(location.SecretAccessKey))
+	if location.SessionToken != "" {
+		access += fmt.Sprintf(" AWS_TOKEN = '%v'", singleQuoteReplacer.Replace(location.SessionToken))
+	}
+	access += ")"
+	if location.StorageIntegration != "" {
+		access = fmt.Sprintf("STORAGE_INTEGRATION = %v", location.StorageIntegration)
+	}
+
+	// replacing the stage picks up keys that have changed since it was created
+	createStageQuery := fmt.Sprintf(`CREATE OR REPLACE STAGE %v.%v %v %v;`, escapedSchemaName, stageName, stageUrl, access)
+
+	// the query holds keys, so it's run without exec, which would put it in the error
+	_, err = system.Connection.Exec(createStageQuery)
+	if err != nil {
+		return fmt.Errorf("error creating %v in snowflake :: %v", stageName, err)
+	}
+
+	infoLog.Printf("created %v.%v on %v in snowflake", escapedSchemaName, stageName, location.getUrl())
+
+	return nil
+}
+
 func (system Snowflake) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
 	return false, nil
 }
@@ -325,6 +449,10 @@ func (system Snowflake) putCsvs(
 
 	finalCsvChannelOut := make(chan FinalCsvInfo)
 
+	if transfer.StagingUrl != "" {
+		return system.uploadCsvs(finalCsvChannelIn, transfer)
+	}
+
 	go func() {
 
 		defer close(finalCsvChannelOut)
@@ -354,6 +482,48 @@ func (system Snowflake) putCsvs(
 	return finalCsvChannelOut
 }
 
+func (system Snowflake) uploadCsvs(
+	finalCsvChannelIn <-chan FinalCsvInfo,
+	transfer Transfer,
+) <-chan FinalCsvInfo {
+	// uploads final csvs to the staging location, where the external stage reads them from
+
+	finalCsvChannelOut := make(chan FinalCsvInfo)
+
+	go func() {
+
+		defer close(finalCsvChannelOut)
+
+		location, _, err := newS3Location(transfer.StagingUrl)
+		if err != nil {
+			transfer.Error = fmt.Sprintf("error opening staging location :: %v", err)
+			transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+			errorLog.Println(transfer.Error)
+			return
+		}
+
+		for finalCsvInfo := range finalCsvChannelIn {
+
+			// relative to the stage, which starts at the staging location's prefix
+			finalCsvInfo.InsertInfo = fmt.Sprintf("%v/final-csvs/%v.csv", transfer.Id, uuid.New().String())
+
+			err := location.upload(transfer.Context, location.getKey(finalCsvInfo.InsertInfo), finalCsvInfo.FilePath)
+			if err != nil {
+				transfer.Error = fmt.Sprintf("error staging csv for snowflake :: %v", err)
+				transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
+				errorLog.Println(transfer.Error)
+				return
+			}
+
+			finalCsvChannelOut <- finalCsvInfo
+		}
+
+		infoLog.Printf("transfer %v finished staging snowflake csvs in %v", transfer.Id, location.getUrl(transfer.Id))
+	}()
+
+	return finalCsvChannelOut
+}
+
 func (system Snowflake) getFinalCsvFormatters() (
 	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error),
 ) {
@@ -449,14 +619,20 @@ func (system Snowflake) runInsertCmd(
 
 	copyQuery := fmt.Sprintf(`	
 		COPY INTO %v
-		FROM @%v.sqlpipe_stage/%v
+		FROM @%v.%v/%v
 		FILE_FORMAT = (FORMAT_NAME = %v.sqlpipe_csv)`,
 		escapedSchemaPeriodtable,
 		escapedSchema,
+		getSnowflakeStageName(transfer),
 		finalCsvInfo.InsertInfo,
 		escapedSchema,
 	)
 
+	// staged csvs are deleted from the bucket by snowflake once they're loaded
+	if transfer.StagingUrl != "" && !transfer.KeepFiles {
+		copyQuery += "\n\t\tPURGE = TRUE"
+	}
+
 	err = system.exec(copyQuery)
 	if err != nil {
 		return fmt.Errorf("error copying csv into snowflake :: %v", err)
@@ -582,6 +758,15 @@ func (system Snowflake) getIncrementalTimeOverride(schema, table, incrementalCol
 	return time.Time{}, false, initialLoad, nil
 }
 
+func (system Snowflake) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	return "", false
+}
+
+func (system Snowflake) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// each snowflake statement sees what was committed when it started, even in a transaction
+	return nil, errors.New("snowflake sources can't be read in a snapshot")
+}
+
 func (system Snowflake) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
 	return nil, errors.New("snowflake does not enforce primary keys")
 }
@@ -612,6 +797,44 @@ func (system Snowflake) getTableColumnInfosRows(schema, table string) (rows *sql
 	return rows, nil
 }
 
+func (system Snowflake) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			TABLE_NAME
+		FROM
+			INFORMATION_SCHEMA.TABLES
+		WHERE
+			upper(TABLE_SCHEMA) = upper('%v')
+			AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY
+			TABLE_NAME;`, schema)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Snowflake) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			ROW_COUNT
+		FROM
+			INFORMATION_SCHEMA.TABLES
+		WHERE
+			upper(TABLE_SCHEMA) = upper('%v')
+			AND upper(TABLE_NAME) = upper('%v');`, schema, table)
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
 var snowflakeDatetimeFormatter = "2006-01-02 15:04:05.999999999"
 var snowflakeDateFormatter = "2006-01-02"
 var snowflakeTimeFormatter = "15:04:05.999999999"
@@ -705,3 +928,7 @@ func (system Snowflake) getSqlFormatters() (
 		},
 	}
 }
+
+func (system Snowflake) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("change data capture is not supported for snowflake sources")
+}
@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Sqlite struct {
+	Name       string
+	Connection *sql.DB
+}
+
+func (system Sqlite) getSystemName() (name string) {
+	return system.Name
+}
+
+func newSqlite(connectionInfo ConnectionInfo) (sqlite Sqlite, err error) {
+	db, err := openConnectionPool(connectionInfo.Name, connectionInfo.ConnectionString, DriverSQLite)
+	if err != nil {
+		return sqlite, fmt.Errorf("error opening sqlite db :: %v", err)
+	}
+	sqlite.Connection = db
+	sqlite.Name = connectionInfo.Name
+	return sqlite, nil
+}
+
+func (system Sqlite) closeConnectionPool(printError bool) (err error) {
+	err = system.Connection.Close()
+	if err != nil && printError {
+		errorLog.Printf("error closing %v connection pool :: %v", system.Name, err)
+	}
+	return err
+}
+
+func (system Sqlite) query(query string) (rows *sql.Rows, err error) {
+	rows, err = system.Connection.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error running dql on %v :: %v :: %v", system.Name, query, err)
+	}
+	return rows, nil
+}
+
+func (system Sqlite) queryRow(query string) (row *sql.Row) {
+	row = system.Connection.QueryRow(query)
+	return row
+}
+
+func (system Sqlite) queryRowContext(ctx context.Context, query string) (row *sql.Row) {
+	row = system.Connection.QueryRowContext(ctx, query)
+	return row
+}
+
+func (system Sqlite) exec(query string) (err error) {
+	_, err = system.Connection.Exec(query)
+	if err != nil {
+		return fmt.Errorf("error running ddl/dml on %v :: %v :: %v", system.Name, query, err)
+	}
+	return nil
+}
+
+func (system Sqlite) dropTableIfExistsOverride(schema, table string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Sqlite) truncateTableOverride(schema, table string) (overridden bool, err error) {
+	// sqlite has no truncate, but a delete with no where clause empties the table just as fast
+
+	err = system.exec(fmt.Sprintf("delete from %v", escapeIfNeeded(table, system)))
+	if err != nil {
+		return true, fmt.Errorf("error deleting rows :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Sqlite) renameTableOverride(schema, table, newTable string) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Sqlite) swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error) {
+	// ddl is transactional in sqlite, so both renames commit together
+
+	tx, err := system.Connection.Begin()
+	if err != nil {
+		return true, fmt.Errorf("error starting swap transaction :: %v", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		fmt.Sprintf("alter table %v rename to %v", escapeIfNeeded(table, system), escapeIfNeeded(oldTable, system)),
+		fmt.Sprintf("alter table %v rename to %v", escapeIfNeeded(stagingTable, system), escapeIfNeeded(table, system)),
+		fmt.Sprintf("drop table %v", escapeIfNeeded(oldTable, system)),
+	}
+
+	for _, query := range queries {
+		_, err = tx.Exec(query)
+		if err != nil {
+			return true, fmt.Errorf("error swapping tables :: %v :: %v", query, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return true, fmt.Errorf("error committing swap transaction :: %v", err)
+	}
+
+	return true, nil
+}
+
+func (system Sqlite) upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error) {
+
+	escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns := getUpsertColumns(columnInfos, system)
+
+	conflict := "do nothing"
+	if len(escapedNonKeyColumns) > 0 {
+		set := []string{}
+		for _, column := range escapedNonKeyColumns {
+			set = append(set, fmt.Sprintf("%v = excluded.%v", column, column))
+		}
+		conflict = fmt.Sprintf("do update set %v", strings.Join(set, ", "))
+	}
+
+	// the where clause keeps sqlite from reading the on conflict clause as part of a join
+	query := fmt.Sprintf("insert into %v (%v) select %v from %v where true on conflict (%v) %v",
+		escapeIfNeeded(table, system),
+		strings.Join(escapedColumns, ", "),
+		strings.Join(escapedColumns, ", "),
+		escapeIfNeeded(stagingTable, system),
+		strings.Join(escapedPrimaryKeys, ", "),
+		conflict,
+	)
+
+	return true, system.exec(query)
+}
+
+func (system Sqlite) createTableIfNotExistsOverride(schema, table string, columnInfos []ColumnInfo, addPrimaryKey bool) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Sqlite) createSchemaIfNotExistsOverride(schema string) (overridden bool, err error) {
+	// sqlite has no schemas, tables go in the database file the connection string names
+	return true, nil
+}
+
+func (system Sqlite) dbTypeToPipeType(
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	// sqlite accepts any declared type, so types it doesn't name are mapped with the rules
+	// sqlite uses to pick a column's affinity. columns with no declared type are moved as text
+
+	declaredType := strings.ToLower(strings.TrimSpace(databaseTypeName))
+	if i := strings.Index(declaredType, "("); i >= 0 {
+		declaredType = strings.TrimSpace(declaredType[:i])
+	}
+
+	switch declaredType {
+	case "":
+		return "ntext", nil
+	case "boolean", "bool":
+		return "bool", nil
+	case "date":
+		return "date", nil
+	case "datetime":
+		return "datetime", nil
+	case "timestamp":
+		return "datetimetz", nil
+	case "time":
+		return "time", nil
+	case "decimal", "numeric":
+		return "decimal", nil
+	case "json":
+		return "json", nil
+	case "uuid":
+		return "uuid", nil
+	}
+
+	switch {
+	case strings.Contains(declaredType, "int"):
+		return "int64", nil
+	case strings.Contains(declaredType, "char"):
+		return "nvarchar", nil
+	case strings.Contains(declaredType, "clob"), strings.Contains(declaredType, "text"):
+		return "ntext", nil
+	case strings.Contains(declaredType, "blob"):
+		return "blob", nil
+	case strings.Contains(declaredType, "real"), strings.Contains(declaredType, "floa"), strings.Contains(declaredType, "doub"):
+		return "float64", nil
+	default:
+		return "decimal", nil
+	}
+}
+
+func (system Sqlite) driverTypeToPipeType(
+	columnType *sql.ColumnType,
+	databaseTypeName string,
+) (
+	pipeType string,
+	err error,
+) {
+	// the driver reports a column's declared type, which is empty for expressions
+	return system.dbTypeToPipeType(databaseTypeName)
+}
+
+func (system Sqlite) pipeTypeToCreateType(columnInfo ColumnInfo) (createType string, err error) {
+	switch columnInfo.PipeType {
+	case "nvarchar":
+		return "text", nil
+	case "varchar":
+		return "text", nil
+	case "ntext":
+		return "text", nil
+	case "text":
+		return "text", nil
+	case "int64":
+		return "integer", nil
+	case "int32":
+		return "integer", nil
+	case "int16":
+		return "integer", nil
+	case "float64":
+		return "real", nil
+	case "float32":
+		return "real", nil
+	case "decimal":
+		if columnInfo.DecimalOk && columnInfo.Precision > 0 && columnInfo.Scale >= 0 {
+			return fmt.Sprintf("decimal(%v,%v)", columnInfo.Precision, columnInfo.Scale), nil
+		}
+		return "decimal", nil
+	case "money":
+		return "decimal", nil
+	case "datetime":
+		return "datetime", nil
+	case "datetimetz":
+		return "timestamp", nil
+	case "date":
+		return "date", nil
+	case "time":
+		return "time", nil
+	case "varbinary":
+		return "blob", nil
+	case "blob":
+		return "blob", nil
+	case "uuid":
+		return "uuid", nil
+	case "bool":
+		return "boolean", nil
+	case "json":
+		return "json", nil
+	case "xml":
+		return "text", nil
+	case "varbit":
+		return "text", nil
+	default:
+		return "", fmt.Errorf("unsupported pipeType for sqlite: %v", columnInfo.PipeType)
+	}
+}
+
+func (system Sqlite) createPipeFilesOverride(pipeFileChannelIn chan PipeFileInfo, columnInfo []ColumnInfo, transfer Transfer, rows *sql.Rows,
+) (pipeFileInfoChannel chan PipeFileInfo, overridden bool) {
+	return pipeFileChannelIn, false
+}
+
+// a sqlite column can hold a value of any type in any row, so the formatters accept every
+// type the driver returns rather than the one the column was declared with
+
+func sqliteTextValue(v interface{}) (value string, err error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("unsupported sqlite value type %T", v)
+	}
+}
+
+func sqliteIntValue(v interface{}) (value string, err error) {
+	switch value := v.(type) {
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		if value != math.Trunc(value) {
+			return "", fmt.Errorf("non integer value %v in sqlite integer column", value)
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case bool:
+		if value {
+			return "1", nil
+		}
+		return "0", nil
+	case string, []byte:
+		text := strings.TrimSpace(fmt.Sprintf("%s", value))
+		_, err = strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("non integer value %v in sqlite integer column", text)
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("unsupported sqlite value type %T in integer column", v)
+	}
+}
+
+func sqliteNumberValue(v interface{}) (value string, err error) {
+	switch value := v.(type) {
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case string, []byte:
+		text := strings.TrimSpace(fmt.Sprintf("%s", value))
+		_, err = strconv.ParseFloat(text, 64)
+		if err != nil {
+			return "", fmt.Errorf("non numeric value %v in sqlite numeric column", text)
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("unsupported sqlite value type %T in numeric column", v)
+	}
+}
+
+func parseSqliteTime(v interface{}) (valTime time.Time, err error) {
+	// the driver parses values in date and datetime columns, but not in columns declared
+	// with other types or in expressions
+
+	switch value := v.(type) {
+	case time.Time:
+		return value, nil
+	case int64:
+		return time.Unix(value, 0).UTC(), nil
+	case string, []byte:
+		text := strings.TrimSuffix(strings.TrimSpace(fmt.Sprintf("%s", value)), "Z")
+		for _, format := range sqliteTimestampFormats {
+			valTime, err = time.ParseInLocation(format, text, time.UTC)
+			if err == nil {
+				return valTime, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("error parsing sqlite time value %v", text)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported sqlite value type %T in time column", v)
+	}
+}
+
+// the formats sqlite's own date and time functions accept, most specific first
+var sqliteTimestampFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var sqliteDatetimeFormat = "2006-01-02 15:04:05.999999999"
+var sqliteDatetimeTzFormat = "2006-01-02 15:04:05.999999999-07:00"
+var sqliteDateFormat = "2006-01-02"
+var sqliteTimeFormat = "15:04:05.999999999"
+
+func (system Sqlite) getPipeFileFormatters() (
+	pipeFileFormatters map[string]func(interface{}) (pipeFileValue string, err error),
+) {
+	return map[string]func(interface{}) (pipeFileValue string, err error){
+		"nvarchar": sqliteTextValue,
+		"varchar":  sqliteTextValue,
+		"ntext":    sqliteTextValue,
+		"text":     sqliteTextValue,
+		"int64":    sqliteIntValue,
+		"int32":    sqliteIntValue,
+		"int16":    sqliteIntValue,
+		"float64": func(v interface{}) (pipeFileValue string, err error) {
+			if valFloat, ok := v.(float64); ok {
+				return strconv.FormatFloat(valFloat, 'g', -1, 64), nil
+			}
+			return sqliteNumberValue(v)
+		},
+		"float32": func(v interface{}) (pipeFileValue string, err error) {
+			if valFloat, ok := v.(float64); ok {
+				return strconv.FormatFloat(valFloat, 'g', -1, 32), nil
+			}
+			return sqliteNumberValue(v)
+		},
+		"decimal": sqliteNumberValue,
+		"money":   sqliteNumberValue,
+		"datetime": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, err := parseSqliteTime(v)
+			if err != nil {
+				return "", err
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"datetimetz": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, err := parseSqliteTime(v)
+			if err != nil {
+				return "", err
+			}
+			return valTime.UTC().Format(time.RFC3339Nano), nil
+		},
+		"date": func(v interface{}) (pipeFileValue string, err error) {
+			valTime, err := parseSqliteTime(v)
+			if err != nil {
+				return "", err
+			}
+			return valTime.Format(time.RFC3339Nano), nil
+		},
+		"time": func(v interface{}) (pipeFileValue string, err error) {
+			if valTime, ok := v.(time.Time); ok {
+				return valTime.Format(time.RFC3339Nano), nil
+			}
+
+			text, err := sqliteTextValue(v)
+			if err != nil {
+				return "", err
+			}
+
+			for _, format := range []string{sqliteTimeFormat, "15:04"} {
+				valTime, err := time.Parse(format, strings.TrimSpace(text))
+				if err == nil {
+					return valTime.Format(time.RFC3339Nano), nil
+				}
+			}
+
+			return "", fmt.Errorf("error parsing sqlite time value %v", text)
+		},
+		"varbinary": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"blob": func(v interface{}) (pipeFileValue string, err error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+		"uuid": func(v interface{}) (pipeFileValue string, err error) {
+			if valBytes, ok := v.([]byte); ok && len(valBytes) == 16 {
+				valUuid, err := uuid.FromBytes(valBytes)
+				if err != nil {
+					return "", fmt.Errorf("error parsing sqlite uuid value :: %v", err)
+				}
+				return valUuid.String(), nil
+			}
+			return sqliteTextValue(v)
+		},
+		"bool": func(v interface{}) (pipeFileValue string, err error) {
+			switch value := v.(type) {
+			case bool:
+				return strconv.FormatBool(value), nil
+			case int64:
+				return strconv.FormatBool(value != 0), nil
+			default:
+				text, err := sqliteTextValue(v)
+				if err != nil {
+					return "", err
+				}
+				valBool, err := strconv.ParseBool(strings.TrimSpace(text))
+				if err != nil {
+					return "", fmt.Errorf("error parsing sqlite bool value %v", text)
+				}
+				return strconv.FormatBool(valBool), nil
+			}
+		},
+		"json":   sqliteTextValue,
+		"xml":    sqliteTextValue,
+		"varbit": sqliteTextValue,
+	}
+}
+
+func (system Sqlite) insertPipeFilesOverride(columnInfos []ColumnInfo, transfer Transfer, pipeFileInfoChannel <-chan PipeFileInfo, vacuumTable string) (overridden bool, err error) {
+	// sqlite has no bulk loader, so pipe files are inserted in process, each in a single
+	// transaction, which is far faster than committing every row
+
+	for pipeFileInfo := range pipeFileInfoChannel {
+
+		select {
+		case <-transfer.Context.Done():
+			return true, errors.New("context cancelled")
+		default:
+		}
+
+		loadStart := time.Now()
+
+		err = system.insertPipeFile(pipeFileInfo, transfer, columnInfos)
+		if err != nil {
+			return true, fmt.Errorf("error inserting pipe file :: %v", err)
+		}
+
+		recordStageDuration(transfer, StageLoad, loadStart)
+		recordFinalCsvInserted(transfer)
+		transfer.Progress.addLoadedFile(pipeFileInfo.Rows)
+
+		if pipeFileInfo.PkFilePath != "" {
+			os.Remove(pipeFileInfo.PkFilePath)
+		}
+
+		if !transfer.KeepFiles {
+			err = os.Remove(pipeFileInfo.FilePath)
+			if err != nil {
+				return true, fmt.Errorf("error removing pipe file :: %v", err)
+			}
+		}
+	}
+
+	infoLog.Printf("transfer %v finished inserting pipe files", transfer.Id)
+
+	return true, nil
+}
+
+func (system Sqlite) insertPipeFile(pipeFileInfo PipeFileInfo, transfer Transfer, columnInfos []ColumnInfo) (err error) {
+
+	pipeFile, err := os.Open(pipeFileInfo.FilePath)
+	if err != nil {
+		return fmt.Errorf("error opening pipe file :: %v", err)
+	}
+	defer pipeFile.Close()
+
+	escapedColumns := make([]string, len(columnInfos))
+	placeholders := make([]string, len(columnInfos))
+	for i := range columnInfos {
+		escapedColumns[i] = escapeIfNeeded(columnInfos[i].Name, system)
+		placeholders[i] = "?"
+	}
+
+	query := fmt.Sprintf("insert into %v (%v) values (%v)",
+		escapeIfNeeded(getLoadTable(transfer), system),
+		strings.Join(escapedColumns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+
+	tx, err := system.Connection.BeginTx(transfer.Context, nil)
+	if err != nil {
+		return fmt.Errorf("error starting insert transaction :: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(transfer.Context, query)
+	if err != nil {
+		return fmt.Errorf("error preparing insert :: %v", err)
+	}
+	defer stmt.Close()
+
+	finalCsvFormatters := system.getFinalCsvFormatters()
+
+	csvReader := csv.NewReader(pipeFile)
+	values := make([]interface{}, len(columnInfos))
+
+	for {
+		row, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error reading pipe file :: %v", err)
+		}
+
+		for i := range row {
+			if row[i] == transfer.Null {
+				values[i] = nil
+				continue
+			}
+
+			value, err := finalCsvFormatters[columnInfos[i].PipeType](row[i])
+			if err != nil {
+				return fmt.Errorf("error formatting value for column %v :: %v", columnInfos[i].Name, err)
+			}
+
+			// binary values are bound as blobs, everything else as text that sqlite converts
+			// to the column's affinity
+			switch columnInfos[i].PipeType {
+			case "varbinary", "blob":
+				values[i], err = hex.DecodeString(value)
+				if err != nil {
+					return fmt.Errorf("error decoding value for column %v :: %v", columnInfos[i].Name, err)
+				}
+			default:
+				values[i] = value
+			}
+		}
+
+		_, err = stmt.ExecContext(transfer.Context, values...)
+		if err != nil {
+			return fmt.Errorf("error inserting row :: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing insert transaction :: %v", err)
+	}
+
+	return nil
+}
+
+func (system Sqlite) convertPipeFilesOverride(pipeFilePath <-chan PipeFileInfo, finalCsvInfoChannelIn chan FinalCsvInfo, transfer Transfer, columnInfos []ColumnInfo,
+) (finalCsvInfoChannel chan FinalCsvInfo, overridden bool) {
+	return finalCsvInfoChannelIn, false
+}
+
+func (system Sqlite) getFinalCsvFormatters() (
+	finalCsvFormatters map[string]func(string) (finalCsvValue string, err error)) {
+	// sqlite has no final csvs, these turn pipe file values into the text sqlite stores
+	return map[string]func(string) (finalCsvValue string, err error){
+		"nvarchar": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"varchar": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"ntext": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"text": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"int64": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"int32": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"int16": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"float64": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"float32": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"decimal": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"money": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"datetime": func(v string) (finalCsvValue string, err error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error parsing datetime value in sqlite formatter :: %v", err)
+			}
+			return valTime.Format(sqliteDatetimeFormat), nil
+		},
+		"datetimetz": func(v string) (finalCsvValue string, err error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error parsing datetimetz value in sqlite formatter :: %v", err)
+			}
+			return valTime.UTC().Format(sqliteDatetimeTzFormat), nil
+		},
+		"date": func(v string) (finalCsvValue string, err error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error parsing date value in sqlite formatter :: %v", err)
+			}
+			return valTime.Format(sqliteDateFormat), nil
+		},
+		"time": func(v string) (finalCsvValue string, err error) {
+			valTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("error parsing time value in sqlite formatter :: %v", err)
+			}
+			return valTime.Format(sqliteTimeFormat), nil
+		},
+		"varbinary": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"blob": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"uuid": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"bool": func(v string) (finalCsvValue string, err error) {
+			valBool, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("error parsing bool value in sqlite formatter :: %v", err)
+			}
+			if valBool {
+				return "1", nil
+			}
+			return "0", nil
+		},
+		"json": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"xml": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+		"varbit": func(v string) (finalCsvValue string, err error) {
+			return v, nil
+		},
+	}
+}
+
+func (system Sqlite) insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error) {
+	return false, nil
+}
+
+func (system Sqlite) runInsertCmd(
+	finalCsvInfo FinalCsvInfo,
+	transfer Transfer,
+	schema, table string,
+) (
+	err error,
+) {
+	return errors.New("sqlite inserts pipe files directly and does not use final csvs")
+}
+
+func (system Sqlite) getSqlFormatters() (
+	sqlFormatters map[string]func(string) (sqlValue string, err error),
+) {
+	finalCsvFormatters := system.getFinalCsvFormatters()
+
+	quoted := func(pipeType string) func(string) (string, error) {
+		return func(v string) (sqlValue string, err error) {
+			value, err := finalCsvFormatters[pipeType](v)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("'%v'", singleQuoteReplacer.Replace(value)), nil
+		}
+	}
+
+	return map[string]func(string) (sqlValue string, err error){
+		"nvarchar":   quoted("nvarchar"),
+		"varchar":    quoted("varchar"),
+		"ntext":      quoted("ntext"),
+		"text":       quoted("text"),
+		"int64":      finalCsvFormatters["int64"],
+		"int32":      finalCsvFormatters["int32"],
+		"int16":      finalCsvFormatters["int16"],
+		"float64":    finalCsvFormatters["float64"],
+		"float32":    finalCsvFormatters["float32"],
+		"decimal":    finalCsvFormatters["decimal"],
+		"money":      finalCsvFormatters["money"],
+		"datetime":   quoted("datetime"),
+		"datetimetz": quoted("datetimetz"),
+		"date":       quoted("date"),
+		"time":       quoted("time"),
+		"varbinary": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("X'%v'", v), nil
+		},
+		"blob": func(v string) (sqlValue string, err error) {
+			return fmt.Sprintf("X'%v'", v), nil
+		},
+		"uuid":   quoted("uuid"),
+		"bool":   finalCsvFormatters["bool"],
+		"json":   quoted("json"),
+		"xml":    quoted("xml"),
+		"varbit": quoted("varbit"),
+	}
+}
+
+func (system Sqlite) escape(objectName string) (escaped string) {
+	return fmt.Sprintf(`"%v"`, strings.ReplaceAll(objectName, `"`, `""`))
+}
+
+func (system Sqlite) isReservedKeyword(objectName string) bool {
+	if _, ok := sqliteReservedKeywords[strings.ToUpper(objectName)]; ok {
+		return true
+	}
+	return false
+}
+
+func (system Sqlite) schemaRequired() bool {
+	return false
+}
+
+func (system Sqlite) IsTableNotFoundError(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
+}
+
+func (system Sqlite) getIncrementalTimeOverride(schema, table, incrementalColumn string, initialLoad bool) (time.Time, bool, bool, error) {
+	// max over a column has no declared type, so the driver returns times as text
+
+	escapedTable := escapeIfNeeded(table, system)
+
+	var maxValue interface{}
+
+	err := system.queryRow(fmt.Sprintf("select max(%v) from %v", escapeIfNeeded(incrementalColumn, system), escapedTable)).Scan(&maxValue)
+	if err != nil {
+		if system.IsTableNotFoundError(err) {
+			return time.Time{}, true, true, nil
+		}
+		return time.Time{}, true, false, fmt.Errorf("error getting max %v from %v :: %v", incrementalColumn, escapedTable, err)
+	}
+
+	if maxValue == nil {
+		return time.Time{}, true, true, nil
+	}
+
+	maxTime, err := parseSqliteTime(maxValue)
+	if err != nil {
+		return time.Time{}, true, false, fmt.Errorf("error parsing max %v from %v :: %v", incrementalColumn, escapedTable, err)
+	}
+
+	return maxTime, true, false, nil
+}
+
+func (system Sqlite) checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool) {
+	return "", false
+}
+
+func (system Sqlite) beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error) {
+	// a read transaction holds the database's shared lock, or its wal snapshot, until it ends
+	return system.Connection.BeginTx(ctx, nil)
+}
+
+func (system Sqlite) newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error) {
+	return nil, errors.New("change data capture is not supported for sqlite sources")
+}
+
+func (system Sqlite) getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error) {
+	query := fmt.Sprintf(`
+		SELECT
+			name
+		FROM
+			pragma_table_info('%v')
+		WHERE
+			pk > 0
+		ORDER BY
+			pk`, singleQuoteReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting primary keys rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Sqlite) getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error) {
+	// sqlite doesn't keep precision, scale, or length apart from the declared type
+	query := fmt.Sprintf(`
+		SELECT
+			name AS col_name,
+			type AS col_type,
+			-1 AS col_precision,
+			-1 AS col_scale,
+			-1 AS col_length,
+			pk > 0 AS col_is_primary
+		FROM
+			pragma_table_info('%v')
+		ORDER BY
+			cid`, singleQuoteReplacer.Replace(table))
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting table column infos rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Sqlite) getTablesRows(schema string) (rows *sql.Rows, err error) {
+	query := `
+		SELECT
+			name
+		FROM
+			sqlite_master
+		WHERE
+			type = 'table'
+			AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		ORDER BY
+			name`
+
+	rows, err = system.query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+func (system Sqlite) getRowEstimateRows(schema, table string) (rows *sql.Rows, err error) {
+	// sqlite only keeps row counts when the table has been analyzed, so rows are counted instead
+	rows, err = system.query("SELECT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+
+	return rows, nil
+}
+
+var sqliteReservedKeywords = map[string]bool{
+	"ABORT":             true,
+	"ACTION":            true,
+	"ADD":               true,
+	"AFTER":             true,
+	"ALL":               true,
+	"ALTER":             true,
+	"ALWAYS":            true,
+	"ANALYZE":           true,
+	"AND":               true,
+	"AS":                true,
+	"ASC":               true,
+	"ATTACH":            true,
+	"AUTOINCREMENT":     true,
+	"BEFORE":            true,
+	"BEGIN":             true,
+	"BETWEEN":           true,
+	"BY":                true,
+	"CASCADE":           true,
+	"CASE":              true,
+	"CAST":              true,
+	"CHECK":             true,
+	"COLLATE":           true,
+	"COLUMN":            true,
+	"COMMIT":            true,
+	"CONFLICT":          true,
+	"CONSTRAINT":        true,
+	"CREATE":            true,
+	"CROSS":             true,
+	"CURRENT":           true,
+	"CURRENT_DATE":      true,
+	"CURRENT_TIME":      true,
+	"CURRENT_TIMESTAMP": true,
+	"DATABASE":          true,
+	"DEFAULT":           true,
+	"DEFERRABLE":        true,
+	"DEFERRED":          true,
+	"DELETE":            true,
+	"DESC":              true,
+	"DETACH":            true,
+	"DISTINCT":          true,
+	"DO":                true,
+	"DROP":              true,
+	"EACH":              true,
+	"ELSE":              true,
+	"END":               true,
+	"ESCAPE":            true,
+	"EXCEPT":            true,
+	"EXCLUDE":           true,
+	"EXCLUSIVE":         true,
+	"EXISTS":            true,
+	"EXPLAIN":           true,
+	"FAIL":              true,
+	"FILTER":            true,
+	"FIRST":             true,
+	"FOLLOWING":         true,
+	"FOR":               true,
+	"FOREIGN":           true,
+	"FROM":              true,
+	"FULL":              true,
+	"GENERATED":         true,
+	"GLOB":              true,
+	"GROUP":             true,
+	"GROUPS":            true,
+	"HAVING":            true,
+	"IF":                true,
+	"IGNORE":            true,
+	"IMMEDIATE":         true,
+	"IN":                true,
+	"INDEX":             true,
+	"INDEXED":           true,
+	"INITIALLY":         true,
+	"INNER":             true,
+	"INSERT":            true,
+	"INSTEAD":           true,
+	"INTERSECT":         true,
+	"INTO":              true,
+	"IS":                true,
+	"ISNULL":            true,
+	"JOIN":              true,
+	"KEY":               true,
+	"LAST":              true,
+	"LEFT":              true,
+	"LIKE":              true,
+	"LIMIT":             true,
+	"MATCH":             true,
+	"MATERIALIZED":      true,
+	"NATURAL":           true,
+	"NO":                true,
+	"NOT":               true,
+	"NOTHING":           true,
+	"NOTNULL":           true,
+	"NULL":              true,
+	"NULLS":             true,
+	"OF":                true,
+	"OFFSET":            true,
+	"ON":                true,
+	"OR":                true,
+	"ORDER":             true,
+	"OTHERS":            true,
+	"OUTER":             true,
+	"OVER":              true,
+	"PARTITION":         true,
+	"PLAN":              true,
+	"PRAGMA":            true,
+	"PRECEDING":         true,
+	"PRIMARY":           true,
+	"QUERY":             true,
+	"RAISE":             true,
+	"RANGE":             true,
+	"RECURSIVE":         true,
+	"REFERENCES":        true,
+	"REGEXP":            true,
+	"REINDEX":           true,
+	"RELEASE":           true,
+	"RENAME":            true,
+	"REPLACE":           true,
+	"RESTRICT":          true,
+	"RETURNING":         true,
+	"RIGHT":             true,
+	"ROLLBACK":          true,
+	"ROW":               true,
+	"ROWS":              true,
+	"SAVEPOINT":         true,
+	"SELECT":            true,
+	"SET":               true,
+	"TABLE":             true,
+	"TEMP":              true,
+	"TEMPORARY":         true,
+	"THEN":              true,
+	"TIES":              true,
+	"TO":                true,
+	"TRANSACTION":       true,
+	"TRIGGER":           true,
+	"UNBOUNDED":         true,
+	"UNION":             true,
+	"UNIQUE":            true,
+	"UPDATE":            true,
+	"USING":             true,
+	"VACUUM":            true,
+	"VALUES":            true,
+	"VIEW":              true,
+	"VIRTUAL":           true,
+	"WHEN":              true,
+	"WHERE":             true,
+	"WINDOW":            true,
+	"WITH":              true,
+	"WITHOUT":           true,
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSqliteToSqliteTransfer(t *testing.T) {
+	dir := t.TempDir()
+	globalTmpDir = dir
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+
+	source, err := sql.Open(DriverSQLite, sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	_, err = source.Exec(`create table orders (
+		id integer primary key,
+		customer text,
+		quantity integer,
+		price real,
+		total numeric,
+		shipped boolean,
+		ordered_at datetime,
+		ordered_on date,
+		notes text,
+		receipt blob
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = source.Exec(`insert into orders values
+		(1, 'alice', 3, 2.5, 7.5, 1, '2023-11-02 10:15:30', '2023-11-02', 'leave at the door, "please"', x'0102ff'),
+		(2, 'bob', 1, 10, 10, 0, '2023-11-03 08:00:00.123', '2023-11-03', 'line one
+line two', null),
+		(3, null, null, null, null, null, null, null, null, null)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs("sqlite-to-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transfer := Transfer{
+		Id:                           "sqlite-to-sqlite",
+		CreatedAt:                    time.Now(),
+		Progress:                     newTransferProgress(),
+		Status:                       StatusQueued,
+		TmpDir:                       tmpDir,
+		PipeFileDir:                  pipeFileDir,
+		FinalCsvDir:                  finalCsvDir,
+		Delimiter:                    "{dlm}",
+		Newline:                      "{nwln}",
+		Null:                         "{nll}",
+		Context:                      ctx,
+		Cancel:                       cancel,
+		SourceConnectionInfo:         ConnectionInfo{Name: "source", Type: TypeSQLite, ConnectionString: sourcePath},
+		TargetConnectionInfo:         ConnectionInfo{Name: "target", Type: TypeSQLite, ConnectionString: targetPath},
+		SourceTable:                  "orders",
+		TargetTable:                  "orders",
+		CreateTargetTableIfNotExists: true,
+		Loader:                       LoaderNative,
+		WriteMode:                    WriteModeAppend,
+		Verification:                 VerificationChecksum,
+	}
+
+	v := newValidator()
+	validateTransfer(v, transfer)
+	if !v.valid() {
+		t.Fatalf("transfer failed validation :: %v", v.errors)
+	}
+
+	transferMap.Set(transfer.Id, transfer)
+
+	err = runTransfer(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer, _ = transferMap.Get(transfer.Id)
+	if transfer.Status != StatusComplete {
+		t.Fatalf("transfer status is %v, not %v :: %v", transfer.Status, StatusComplete, transfer.Error)
+	}
+	if transfer.VerificationResult == nil || !transfer.VerificationResult.Passed {
+		t.Fatalf("transfer verification did not pass :: %+v", transfer.VerificationResult)
+	}
+
+	target, err := sql.Open(DriverSQLite, targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	query := `select id, customer, quantity, price, total, shipped, ordered_at, ordered_on, notes, receipt from orders order by id`
+	want := readSqliteRows(t, source, query)
+	got := readSqliteRows(t, target, query)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("target rows do not match source rows\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func readSqliteRows(t *testing.T, db *sql.DB, query string) (values [][]interface{}) {
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range row {
+			pointers[i] = &row[i]
+		}
+		err = rows.Scan(pointers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, row)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
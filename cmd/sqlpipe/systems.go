@@ -4,13 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -26,6 +30,7 @@ type System interface {
 
 	query(query string) (rows *sql.Rows, err error)
 	queryRow(query string) (row *sql.Row)
+	queryRowContext(ctx context.Context, query string) (row *sql.Row)
 	exec(query string) (err error)
 
 	closeConnectionPool(printError bool) (err error)
@@ -37,6 +42,8 @@ type System interface {
 	escape(objectName string) (escaped string)
 	getPrimaryKeysRows(schema, table string) (rows *sql.Rows, err error)
 	getTableColumnInfosRows(schema, table string) (rows *sql.Rows, err error)
+	getTablesRows(schema string) (rows *sql.Rows, err error)
+	getRowEstimateRows(schema, table string) (rows *sql.Rows, err error)
 	IsTableNotFoundError(err error) (isTableNotFound bool)
 
 	// -----------------
@@ -56,8 +63,12 @@ type System interface {
 	// -------------------
 
 	createSchemaIfNotExistsOverride(schema string) (overridden bool, err error)
-	createTableIfNotExistsOverride(schema, table string, columnInfo []ColumnInfo, incremental bool) (overridden bool, err error)
+	createTableIfNotExistsOverride(schema, table string, columnInfo []ColumnInfo, addPrimaryKey bool) (overridden bool, err error)
 	dropTableIfExistsOverride(schema, table string) (overridden bool, err error)
+	truncateTableOverride(schema, table string) (overridden bool, err error)
+	renameTableOverride(schema, table, newTable string) (overridden bool, err error)
+	swapTablesOverride(schema, stagingTable, table, oldTable string) (overridden bool, err error)
+	upsertFromTableOverride(schema, stagingTable, table string, columnInfos []ColumnInfo) (overridden bool, err error)
 
 	// *******************
 	// ** Data movement **
@@ -69,6 +80,9 @@ type System interface {
 	insertFinalCsvsOverride(transfer Transfer) (overridden bool, err error)
 	runInsertCmd(finalCsvInfo FinalCsvInfo, transfer Transfer, schema, table string) (err error)
 	getIncrementalTimeOverride(schema, table, incrementalColumn string, intialLoad bool) (incrementalTime time.Time, overridden bool, initialLoad bool, err error)
+	checksumAggregateOverride(columnInfo ColumnInfo, kind string) (aggregate string, overridden bool)
+	beginSnapshot(ctx context.Context) (snapshot *sql.Tx, err error)
+	newChangeCapture(transfer Transfer, columnInfos []ColumnInfo) (capture ChangeCapture, err error)
 }
 
 func newSystem(connectionInfo ConnectionInfo) (system System, err error) {
@@ -85,6 +99,18 @@ func newSystem(connectionInfo ConnectionInfo) (system System, err error) {
 		return newOracle(connectionInfo)
 	case TypeSnowflake:
 		return newSnowflake(connectionInfo)
+	case TypeSQLite:
+		return newSqlite(connectionInfo)
+	case TypeDuckDB:
+		return newDuckdb(connectionInfo)
+	case TypeClickHouse:
+		return newClickhouse(connectionInfo)
+	case TypeParquet:
+		return newParquet(connectionInfo)
+	case TypeCsv, TypeJsonl:
+		return newFlatFile(connectionInfo)
+	case TypeS3:
+		return newS3(connectionInfo)
 	default:
 		return system, fmt.Errorf("unsupported system type %v", connectionInfo.Type)
 	}
@@ -198,12 +224,12 @@ func createTableIfNotExists(
 	schema, table string,
 	columnInfos []ColumnInfo,
 	target System,
-	incremental bool,
+	addPrimaryKey bool,
 ) (
 	err error,
 ) {
 
-	overridden, err := target.createTableIfNotExistsOverride(schema, table, columnInfos, incremental)
+	overridden, err := target.createTableIfNotExistsOverride(schema, table, columnInfos, addPrimaryKey)
 	if overridden {
 		return err
 	}
@@ -242,7 +268,7 @@ func createTableIfNotExists(
 		queryBuilder.WriteString(createType)
 	}
 
-	if incremental && len(escapedPrimaryKeys) > 0 {
+	if addPrimaryKey && len(escapedPrimaryKeys) > 0 {
 		queryBuilder.WriteString(", primary key (")
 		queryBuilder.WriteString(strings.Join(escapedPrimaryKeys, ","))
 		queryBuilder.WriteString(")")
@@ -324,6 +350,7 @@ func createTableIfNotExists(
 type PipeFileInfo struct {
 	FilePath   string
 	PkFilePath string
+	Rows       int
 }
 
 func createPipeFiles(
@@ -333,6 +360,7 @@ func createPipeFiles(
 	source System,
 	target System,
 	incremental bool,
+	pipeFileNums *atomic.Int64,
 ) <-chan PipeFileInfo {
 
 	pipeFileInfoChannel := make(chan PipeFileInfo)
@@ -345,10 +373,12 @@ func createPipeFiles(
 	go func() {
 
 		defer close(pipeFileInfoChannel)
+		defer recordStageDuration(transfer, StagePipeFileWrite, time.Now())
 
 		pipeFileFormatters := source.getPipeFileFormatters()
 
-		pipeFileNum := 0
+		// pipe file numbers are shared by every partition of a transfer, so file names don't collide
+		pipeFileNum := pipeFileNums.Add(1) - 1
 
 		pipeFile, err := os.Create(
 			filepath.Join(transfer.PipeFileDir, fmt.Sprintf("%032b.pipe", pipeFileNum)))
@@ -395,6 +425,7 @@ func createPipeFiles(
 		}
 
 		dataInRam := false
+		rowsInRam := 0
 		csvRow := make([]string, numCols)
 		pkRow := make([]string, numPks)
 
@@ -497,6 +528,7 @@ func createPipeFiles(
 			}
 
 			dataInRam = true
+			rowsInRam++
 
 			if csvLength > 10_000_000 {
 
@@ -553,11 +585,14 @@ func createPipeFiles(
 				pipeFileInfo := PipeFileInfo{
 					FilePath:   pipeFile.Name(),
 					PkFilePath: pkFilePath,
+					Rows:       rowsInRam,
 				}
 
 				pipeFileInfoChannel <- pipeFileInfo
+				recordPipeFileWritten(transfer, rowsInRam, csvLength)
+				transfer.Progress.addPipeFile(rowsInRam, csvLength)
 
-				pipeFileNum++
+				pipeFileNum = pipeFileNums.Add(1) - 1
 
 				eg.Go(func() error {
 					pipeFileName := filepath.Join(
@@ -577,6 +612,7 @@ func createPipeFiles(
 				})
 				defer pipeFile.Close()
 				dataInRam = false
+				rowsInRam = 0
 				csvLength = 0
 
 				eg.Go(func() error {
@@ -666,9 +702,12 @@ func createPipeFiles(
 			pipeFileInfo := PipeFileInfo{
 				FilePath:   pipeFile.Name(),
 				PkFilePath: pkFilePath,
+				Rows:       rowsInRam,
 			}
 
 			pipeFileInfoChannel <- pipeFileInfo
+			recordPipeFileWritten(transfer, rowsInRam, csvLength)
+			transfer.Progress.addPipeFile(rowsInRam, csvLength)
 		}
 
 		infoLog.Printf("transfer %v finished writing pipe files", transfer.Id)
@@ -677,6 +716,47 @@ func createPipeFiles(
 	return pipeFileInfoChannel
 }
 
+func createPartitionedPipeFiles(
+	columnInfos []ColumnInfo,
+	transfer Transfer,
+	partitionRows []*sql.Rows,
+	source System,
+	target System,
+	incremental bool,
+) <-chan PipeFileInfo {
+	// reads each partition on its own goroutine and merges their pipe files into one channel,
+	// so the rest of the pipeline doesn't know the source was read in partitions
+
+	pipeFileNums := &atomic.Int64{}
+
+	pipeFileInfoChannel := make(chan PipeFileInfo)
+
+	var wg sync.WaitGroup
+
+	for i := range partitionRows {
+		partitionPipeFiles := createPipeFiles(columnInfos, transfer, partitionRows[i], source, target, incremental, pipeFileNums)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pipeFileInfo := range partitionPipeFiles {
+				pipeFileInfoChannel <- pipeFileInfo
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		transfer.Progress.setStage(StageLoad)
+		close(pipeFileInfoChannel)
+		if len(partitionRows) > 1 {
+			infoLog.Printf("transfer %v finished reading %v partitions", transfer.Id, len(partitionRows))
+		}
+	}()
+
+	return pipeFileInfoChannel
+}
+
 func insertPipeFiles(pipeFileChannel <-chan PipeFileInfo, transfer Transfer, columnInfos []ColumnInfo, target System, vacuumTable string) (err error) {
 
 	overridden, err := target.insertPipeFilesOverride(columnInfos, transfer, pipeFileChannel, vacuumTable)
@@ -686,7 +766,7 @@ func insertPipeFiles(pipeFileChannel <-chan PipeFileInfo, transfer Transfer, col
 
 	finalCsvChannel := convertPipeFiles(pipeFileChannel, columnInfos, transfer, target)
 
-	table := transfer.TargetTable
+	table := getLoadTable(transfer)
 
 	err = insertFinalCsvs(finalCsvChannel, transfer, target, transfer.TargetSchema, table)
 	if err != nil {
@@ -696,6 +776,44 @@ func insertPipeFiles(pipeFileChannel <-chan PipeFileInfo, transfer Transfer, col
 	return nil
 }
 
+func getLoadTable(transfer Transfer) (table string) {
+	if transfer.stagingTable != "" {
+		return transfer.stagingTable
+	}
+	return transfer.TargetTable
+}
+
+func pipeFileValueToGo(pipeType, pipeFileValue string) (value interface{}, err error) {
+	// converts a pipe file value to a go value that can be handed to a driver
+
+	switch pipeType {
+	case "nvarchar", "varchar", "ntext", "text", "decimal", "money", "uuid", "json", "xml", "varbit":
+		return pipeFileValue, nil
+	case "int64", "int32", "int16":
+		value, err = strconv.ParseInt(pipeFileValue, 10, 64)
+	case "float64", "float32":
+		value, err = strconv.ParseFloat(pipeFileValue, 64)
+	case "datetime", "date", "time":
+		value, err = time.Parse(time.RFC3339Nano, pipeFileValue)
+	case "datetimetz":
+		var valTime time.Time
+		valTime, err = time.Parse(time.RFC3339Nano, pipeFileValue)
+		value = valTime.UTC()
+	case "varbinary", "blob":
+		value, err = hex.DecodeString(pipeFileValue)
+	case "bool":
+		value, err = strconv.ParseBool(pipeFileValue)
+	default:
+		return nil, fmt.Errorf("unsupported pipe type %v", pipeType)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error converting %v value :: %v", pipeType, err)
+	}
+
+	return value, nil
+}
+
 func convertPipeFiles(
 	pipeFileInfoChannel <-chan PipeFileInfo,
 	columnInfos []ColumnInfo,
@@ -718,6 +836,8 @@ func convertPipeFiles(
 
 		for pipeFileInfo := range pipeFileInfoChannel {
 
+			conversionStart := time.Now()
+
 			pkFilePath := pipeFileInfo.PkFilePath
 			defer os.Remove(pkFilePath)
 
@@ -808,12 +928,16 @@ func convertPipeFiles(
 			default:
 			}
 
+			recordStageDuration(transfer, StageConversion, conversionStart)
+
 			finalCsvInfo := FinalCsvInfo{
 				FilePath:   csvFile.Name(),
 				InsertInfo: csvFile.Name(),
+				Rows:       pipeFileInfo.Rows,
 			}
 
 			finalCsvInfoChannel <- finalCsvInfo
+			transfer.Progress.addFinalCsv()
 
 			if !transfer.KeepFiles {
 				err = os.Remove(pipeFilePath)
@@ -851,11 +975,17 @@ func insertFinalCsvs(
 		default:
 		}
 
+		loadStart := time.Now()
+
 		err = target.runInsertCmd(finalCsvinfo, transfer, schema, table)
 		if err != nil {
 			return fmt.Errorf("error inserting final csv :: %v", err)
 		}
 
+		recordStageDuration(transfer, StageLoad, loadStart)
+		recordFinalCsvInserted(transfer)
+		transfer.Progress.addLoadedFile(finalCsvinfo.Rows)
+
 		if !transfer.KeepFiles {
 			err = os.Remove(finalCsvinfo.FilePath)
 			if err != nil {
@@ -973,6 +1103,210 @@ func dropTableIfExists(schema, table string, system System) (err error) {
 	return nil
 }
 
+func truncateTable(schema, table string, system System) (err error) {
+	overridden, err := system.truncateTableOverride(schema, table)
+	if overridden {
+		return err
+	}
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	query := fmt.Sprintf("truncate table %v", escapedSchemaPeriodTable)
+	err = system.exec(query)
+	if err != nil {
+		return fmt.Errorf("error truncating table %v :: %v", escapedSchemaPeriodTable, err)
+	}
+
+	infoLog.Printf("truncated %v in %v", escapedSchemaPeriodTable, system.getSystemName())
+
+	return nil
+}
+
+func tableExists(schema, table string, system System) (exists bool, err error) {
+	// some systems uppercase unescaped names, so names are compared without regard to case
+
+	tables, err := getTables(schema, system)
+	if err != nil {
+		return false, fmt.Errorf("error getting tables :: %v", err)
+	}
+
+	for i := range tables {
+		if strings.EqualFold(tables[i], table) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func renameTable(schema, table, newTable string, system System) (err error) {
+	overridden, err := system.renameTableOverride(schema, table, newTable)
+	if overridden {
+		return err
+	}
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	query := fmt.Sprintf("alter table %v rename to %v", escapedSchemaPeriodTable, escapeIfNeeded(newTable, system))
+	err = system.exec(query)
+	if err != nil {
+		return fmt.Errorf("error renaming table %v to %v :: %v", escapedSchemaPeriodTable, newTable, err)
+	}
+
+	return nil
+}
+
+func swapTables(schema, stagingTable, table, oldTable string, system System) (err error) {
+	// replaces the target table with a fully loaded staging table. systems that can rename
+	// tables atomically override this, so readers never see the target missing or part loaded.
+	// the fallback renames one table at a time, which leaves the target missing for a moment
+
+	exists, err := tableExists(schema, table, system)
+	if err != nil {
+		return fmt.Errorf("error checking if target table exists :: %v", err)
+	}
+
+	if !exists {
+		err = renameTable(schema, stagingTable, table, system)
+		if err != nil {
+			return fmt.Errorf("error renaming staging table :: %v", err)
+		}
+		infoLog.Printf("renamed staging table %v to %v in %v", stagingTable, table, system.getSystemName())
+		return nil
+	}
+
+	overridden, err := system.swapTablesOverride(schema, stagingTable, table, oldTable)
+	if overridden {
+		if err == nil {
+			infoLog.Printf("swapped staging table %v into %v in %v", stagingTable, table, system.getSystemName())
+		}
+		return err
+	}
+
+	err = renameTable(schema, table, oldTable, system)
+	if err != nil {
+		return fmt.Errorf("error renaming target table :: %v", err)
+	}
+
+	err = renameTable(schema, stagingTable, table, system)
+	if err != nil {
+		return fmt.Errorf("error renaming staging table :: %v", err)
+	}
+
+	err = dropTableIfExists(schema, oldTable, system)
+	if err != nil {
+		return fmt.Errorf("error dropping old target table :: %v", err)
+	}
+
+	infoLog.Printf("swapped staging table %v into %v in %v", stagingTable, table, system.getSystemName())
+
+	return nil
+}
+
+func upsertFromTable(schema, stagingTable, table string, columnInfos []ColumnInfo, system System) (err error) {
+	// inserts rows from the staging table into the target table, updating rows whose primary
+	// keys are already there. it's a single statement, so the target never shows part of a load
+
+	overridden, err := system.upsertFromTableOverride(schema, stagingTable, table, columnInfos)
+	if !overridden {
+		err = system.exec(getMergeQuery(schema, stagingTable, table, columnInfos, system))
+	}
+	if err != nil {
+		return fmt.Errorf("error upserting from staging table %v into %v :: %v", stagingTable, table, err)
+	}
+
+	infoLog.Printf("upserted staging table %v into %v in %v", stagingTable, table, system.getSystemName())
+
+	return nil
+}
+
+func getMergeQuery(schema, stagingTable, table string, columnInfos []ColumnInfo, system System) (query string) {
+
+	escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns := getUpsertColumns(columnInfos, system)
+
+	on := []string{}
+	for _, pk := range escapedPrimaryKeys {
+		on = append(on, fmt.Sprintf("t.%v = s.%v", pk, pk))
+	}
+
+	set := []string{}
+	for _, column := range escapedNonKeyColumns {
+		set = append(set, fmt.Sprintf("t.%v = s.%v", column, column))
+	}
+
+	values := []string{}
+	for _, column := range escapedColumns {
+		values = append(values, fmt.Sprintf("s.%v", column))
+	}
+
+	queryBuilder := strings.Builder{}
+	queryBuilder.WriteString(fmt.Sprintf("merge into %v t using %v s on (%v)",
+		getSchemaPeriodTable(schema, table, system, true),
+		getSchemaPeriodTable(schema, stagingTable, system, true),
+		strings.Join(on, " and "),
+	))
+	if len(set) > 0 {
+		queryBuilder.WriteString(fmt.Sprintf(" when matched then update set %v", strings.Join(set, ", ")))
+	}
+	queryBuilder.WriteString(fmt.Sprintf(" when not matched then insert (%v) values (%v)", strings.Join(escapedColumns, ", "), strings.Join(values, ", ")))
+
+	return queryBuilder.String()
+}
+
+func hasPrimaryKey(columnInfos []ColumnInfo) bool {
+	for i := range columnInfos {
+		if columnInfos[i].IsPrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+func getUpsertColumns(columnInfos []ColumnInfo, system System) (escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns []string) {
+
+	for i := range columnInfos {
+		escapedColumn := escapeIfNeeded(columnInfos[i].Name, system)
+		escapedColumns = append(escapedColumns, escapedColumn)
+		if columnInfos[i].IsPrimaryKey {
+			escapedPrimaryKeys = append(escapedPrimaryKeys, escapedColumn)
+		} else {
+			escapedNonKeyColumns = append(escapedNonKeyColumns, escapedColumn)
+		}
+	}
+
+	return escapedPrimaryKeys, escapedColumns, escapedNonKeyColumns
+}
+
+func getIncrementalTime(schema, table, incrementalColumn string, system System) (incrementalTime time.Time, initialLoad bool, err error) {
+	// gets the max value of the incremental column in the target. if the table doesn't
+	// exist yet or has no rows, the transfer is treated as an initial load
+
+	incrementalTime, overridden, initialLoad, err := system.getIncrementalTimeOverride(schema, table, incrementalColumn, true)
+	if overridden {
+		return incrementalTime, initialLoad, err
+	}
+
+	escapedSchemaPeriodTable := getSchemaPeriodTable(schema, table, system, true)
+
+	query := fmt.Sprintf("select max(%v) from %v", escapeIfNeeded(incrementalColumn, system), escapedSchemaPeriodTable)
+
+	var maxTime sql.NullTime
+
+	err = system.queryRow(query).Scan(&maxTime)
+	if err != nil {
+		if system.IsTableNotFoundError(err) {
+			return time.Time{}, true, nil
+		}
+		return time.Time{}, false, fmt.Errorf("error getting max %v from %v :: %v", incrementalColumn, escapedSchemaPeriodTable, err)
+	}
+
+	if !maxTime.Valid {
+		return time.Time{}, true, nil
+	}
+
+	return maxTime.Time, false, nil
+}
+
 func deletePks(pipeFilesIn <-chan PipeFileInfo, columnInfos []ColumnInfo, transfer Transfer, target System, incremental, initialLoad bool) <-chan PipeFileInfo {
 	if initialLoad || !incremental {
 		return pipeFilesIn
@@ -1189,3 +1523,61 @@ func getTableColumnInfos(schema, table string, system System) (columnInfos []Col
 
 	return columnInfos, nil
 }
+
+func getTables(schema string, system System) (tables []string, err error) {
+
+	rows, err := system.getTablesRows(schema)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tables rows :: %v", err)
+	}
+	defer rows.Close()
+
+	tables = []string{}
+
+	var table string
+
+	for rows.Next() {
+		err := rows.Scan(&table)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning tables rows :: %v", err)
+		}
+		tables = append(tables, table)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tables rows :: %v", err)
+	}
+
+	return tables, nil
+}
+
+func getRowEstimate(schema, table string, system System) (rowEstimate int64, ok bool, err error) {
+	// gets the number of rows in a table from the catalog's statistics, which may be stale.
+	// ok is false if the catalog has no estimate, like for a postgresql table that was never analyzed,
+	// or if the estimate is zero, since counting an empty table is cheap
+
+	rows, err := system.getRowEstimateRows(schema, table)
+	if err != nil {
+		return 0, false, fmt.Errorf("error getting row estimate rows :: %v", err)
+	}
+	defer rows.Close()
+
+	var estimate sql.NullFloat64
+
+	if rows.Next() {
+		err = rows.Scan(&estimate)
+		if err != nil {
+			return 0, false, fmt.Errorf("error scanning row estimate :: %v", err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, false, fmt.Errorf("error iterating row estimate rows :: %v", err)
+	}
+
+	if !estimate.Valid || estimate.Float64 <= 0 {
+		return 0, false, nil
+	}
+
+	return int64(estimate.Float64), true, nil
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,70 +28,280 @@ type ConnectionInfo struct {
 	Database         string `json:"database"`
 	Username         string `json:"username"`
 	Password         string `json:"-"`
+	TableEngine      string `json:"table-engine,omitempty"`
+	OrderBy          string `json:"order-by,omitempty"`
+	RowGroupSize     int    `json:"row-group-size,omitempty"`
 }
 
 type Transfer struct {
-	Id                            string             `json:"id"`
-	CreatedAt                     time.Time          `json:"created-at"`
-	StoppedAt                     string             `json:"stopped-at,omitempty"`
-	Status                        string             `json:"status"`
-	Error                         string             `json:"error,omitempty"`
-	KeepFiles                     bool               `json:"keep-files"`
-	TmpDir                        string             `json:"tmp-dir"`
-	PipeFileDir                   string             `json:"pipe-file-dir"`
-	FinalCsvDir                   string             `json:"final-csv-dir"`
-	Context                       context.Context    `json:"-"`
-	Cancel                        context.CancelFunc `json:"-"`
-	SourceConnectionInfo          ConnectionInfo     `json:"source-connection-info"`
-	TargetConnectionInfo          ConnectionInfo     `json:"target-connection-info"`
-	DropTargetTableIfExists       bool               `json:"drop-target-table-if-exists"`
-	CreateTargetSchemaIfNotExists bool               `json:"create-target-schema-if-not-exists"`
-	CreateTargetTableIfNotExists  bool               `json:"create-target-table-if-not-exists"`
-	SourceSchema                  string             `json:"source-schema,omitempty"`
-	SourceTable                   string             `json:"source-table,omitempty"`
-	TargetSchema                  string             `json:"target-schema,omitempty"`
-	TargetTable                   string             `json:"target-name"`
-	Query                         string             `json:"query,omitempty"`
-	Delimiter                     string             `json:"delimiter"`
-	Newline                       string             `json:"newline"`
-	Null                          string             `json:"null"`
+	Id                            string              `json:"id"`
+	CreatedAt                     time.Time           `json:"created-at"`
+	StoppedAt                     string              `json:"stopped-at,omitempty"`
+	Status                        string              `json:"status"`
+	Error                         string              `json:"error,omitempty"`
+	KeepFiles                     bool                `json:"keep-files"`
+	StagingUrl                    string              `json:"-"`
+	TmpDir                        string              `json:"tmp-dir"`
+	PipeFileDir                   string              `json:"pipe-file-dir"`
+	FinalCsvDir                   string              `json:"final-csv-dir"`
+	Context                       context.Context     `json:"-"`
+	Cancel                        context.CancelFunc  `json:"-"`
+	SourceConnection              string              `json:"source-connection,omitempty"`
+	SourceConnectionInfo          ConnectionInfo      `json:"source-connection-info"`
+	TargetConnection              string              `json:"target-connection,omitempty"`
+	TargetConnectionInfo          ConnectionInfo      `json:"target-connection-info"`
+	DropTargetTableIfExists       bool                `json:"drop-target-table-if-exists"`
+	CreateTargetSchemaIfNotExists bool                `json:"create-target-schema-if-not-exists"`
+	CreateTargetTableIfNotExists  bool                `json:"create-target-table-if-not-exists"`
+	SourceSchema                  string              `json:"source-schema,omitempty"`
+	SourceTable                   string              `json:"source-table,omitempty"`
+	TargetSchema                  string              `json:"target-schema,omitempty"`
+	TargetTable                   string              `json:"target-name"`
+	Query                         string              `json:"query,omitempty"`
+	IncrementalColumn             string              `json:"incremental-column,omitempty"`
+	Loader                        string              `json:"loader"`
+	WriteMode                     string              `json:"write-mode"`
+	Verification                  string              `json:"verification"`
+	Cdc                           bool                `json:"cdc,omitempty"`
+	CdcBatchSeconds               int                 `json:"cdc-batch-seconds,omitempty"`
+	CdcPosition                   string              `json:"cdc-position,omitempty"`
+	CdcAppliedAt                  string              `json:"cdc-applied-at,omitempty"`
+	Partitions                    int                 `json:"partitions,omitempty"`
+	PartitionColumn               string              `json:"partition-column,omitempty"`
+	IncludeTables                 []string            `json:"include-tables,omitempty"`
+	ExcludeTables                 []string            `json:"exclude-tables,omitempty"`
+	ConcurrentTables              int                 `json:"concurrent-tables,omitempty"`
+	ScheduleName                  string              `json:"schedule-name,omitempty"`
+	ParentId                      string              `json:"parent-id,omitempty"`
+	ChildIds                      []string            `json:"child-ids,omitempty"`
+	ChildStatusCounts             map[string]int      `json:"child-status-counts,omitempty"`
+	Progress                      *TransferProgress   `json:"progress,omitempty"`
+	VerificationResult            *VerificationResult `json:"verification-result,omitempty"`
+	Delimiter                     string              `json:"delimiter"`
+	Newline                       string              `json:"newline"`
+	Null                          string              `json:"null"`
+	Priority                      int                 `json:"priority"`
+
+	// swap and upsert transfers load into a staging table instead of the target table
+	stagingTable string
 }
 
 var transferMap = NewSafeTransferMap()
 
 type SafeTransferMap struct {
-	mu sync.Mutex
-	m  map[string]Transfer
+	mu     sync.Mutex
+	m      map[string]Transfer
+	store  TransferStore
+	closed bool
+
+	// store writes are made by a single goroutine, so a slow store doesn't hold up the map.
+	// only the latest state of a transfer needs saving, so pending transfers are coalesced by
+	// id, while every status change is kept, in order
+	pendingTransfers     map[string]Transfer
+	pendingStatusChanges []StatusChange
+	pending              chan struct{}
+	written              chan struct{}
 }
 
 func NewSafeTransferMap() *SafeTransferMap {
-	return &SafeTransferMap{
-		m: make(map[string]Transfer),
+	sm := &SafeTransferMap{
+		m:                make(map[string]Transfer),
+		store:            newMemoryTransferStore(),
+		pendingTransfers: make(map[string]Transfer),
+		pending:          make(chan struct{}, 1),
+		written:          make(chan struct{}),
 	}
+	go sm.writeToStore()
+	return sm
 }
 
 func (sm *SafeTransferMap) Set(key string, value Transfer) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	_, exists := sm.m[key]
 	sm.m[key] = value
+	sm.persist(value, !exists)
+}
+
+func (sm *SafeTransferMap) Update(key string, update func(transfer *Transfer)) (Transfer, bool) {
+	// changes the stored transfer in place, for long running transfers that record their state
+	// as they go without overwriting a status set by someone else, like a cancel
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	transfer, ok := sm.m[key]
+	if !ok {
+		return transfer, false
+	}
+	update(&transfer)
+	sm.m[key] = transfer
+	sm.persist(transfer, false)
+	return transfer, true
 }
 
 func (sm *SafeTransferMap) SetStatus(key, status string, transfer Transfer) Transfer {
 	transfer.Status = status
+	transfer.Error = redactor.redact(transfer.Error)
+	switch status {
+	case StatusComplete, StatusError, StatusCancelled:
+		transfer.StoppedAt = time.Now().Format(time.RFC3339Nano)
+		transfer.Progress.finish()
+		defer deleteTransferMetrics(transfer)
+	}
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	sm.m[key] = transfer
+	sm.persist(transfer, true)
 	infoLog.Printf("transfer %v status set to %v", transfer.Id, status)
 	return transfer
 }
 
 func (sm *SafeTransferMap) CancelAndSetStatus(key string, transfer Transfer, newStatus string) Transfer {
-	transfer.Cancel()
+	if transfer.Cancel != nil {
+		transfer.Cancel()
+	}
 	infoLog.Printf("transfer %v cancelled", transfer.Id)
 	transfer = sm.SetStatus(key, newStatus, transfer)
 	return transfer
 }
 
+func (sm *SafeTransferMap) SetErrorUnlessStopped(key, message string) (transfer Transfer, set bool) {
+	// records why a transfer failed, unless it already stopped, or was cancelled by someone who
+	// sets its status themselves, like a cancel request or a shutdown. it's checked and set under
+	// one lock, so a cancelled status isn't overwritten
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	transfer, ok := sm.m[key]
+	if !ok || (transfer.Status != StatusQueued && transfer.Status != StatusRunning) ||
+		(transfer.Context != nil && transfer.Context.Err() != nil) {
+		return transfer, false
+	}
+
+	if transfer.Cancel != nil {
+		transfer.Cancel()
+	}
+	transfer.Status = StatusError
+	transfer.Error = redactor.redact(message)
+	transfer.StoppedAt = time.Now().Format(time.RFC3339Nano)
+	transfer.Progress.finish()
+	defer deleteTransferMetrics(transfer)
+
+	sm.m[key] = transfer
+	sm.persist(transfer, true)
+	infoLog.Printf("transfer %v status set to %v", transfer.Id, StatusError)
+	return transfer, true
+}
+
+func (sm *SafeTransferMap) persist(transfer Transfer, statusChanged bool) {
+	// queues the transfer to be saved. it's called with sm.mu held, so it must not block, and
+	// the writer is only woken if it isn't already due to run
+
+	if sm.closed {
+		return
+	}
+
+	sm.pendingTransfers[transfer.Id] = transfer
+
+	if statusChanged {
+		statusChange := StatusChange{
+			TransferId: transfer.Id,
+			Status:     transfer.Status,
+			Error:      transfer.Error,
+			ChangedAt:  time.Now(),
+		}
+		sm.pendingStatusChanges = append(sm.pendingStatusChanges, statusChange)
+		transferEvents.publish(TransferEvent{Name: "status", TransferId: transfer.Id, Data: statusChange})
+	}
+
+	select {
+	case sm.pending <- struct{}{}:
+	default:
+	}
+}
+
+func (sm *SafeTransferMap) writeToStore() {
+	// persisting is best effort, a failing store shouldn't fail the transfer itself. pending
+	// writes are taken in one go, so the map is free again while they're saved
+
+	defer close(sm.written)
+
+	for open := true; open; {
+		_, open = <-sm.pending
+
+		sm.mu.Lock()
+		store := sm.store
+		transfers := sm.pendingTransfers
+		statusChanges := sm.pendingStatusChanges
+		sm.pendingTransfers = make(map[string]Transfer)
+		sm.pendingStatusChanges = nil
+		sm.mu.Unlock()
+
+		for _, transfer := range transfers {
+			err := store.saveTransfer(transfer)
+			if err != nil {
+				errorLog.Printf("error saving transfer %v to store :: %v", transfer.Id, err)
+			}
+		}
+
+		for _, statusChange := range statusChanges {
+			err := store.saveStatusChange(statusChange)
+			if err != nil {
+				errorLog.Printf("error saving status change for transfer %v to store :: %v", statusChange.TransferId, err)
+			}
+		}
+	}
+}
+
+func (sm *SafeTransferMap) LoadStore(store TransferStore) (err error) {
+	// loads transfers from a store. transfers that were queued or running when sqlpipe
+	// stopped can never finish, so they are marked as errored
+
+	transfers, err := store.getTransfers()
+	if err != nil {
+		return fmt.Errorf("error getting transfers from store :: %v", err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.store = store
+
+	for id, transfer := range transfers {
+		if transfer.Status == StatusQueued || transfer.Status == StatusRunning {
+			transfer.Error = fmt.Sprintf("sqlpipe stopped while transfer %v was %v", id, transfer.Status)
+			transfer.Status = StatusError
+			transfer.StoppedAt = time.Now().Format(time.RFC3339Nano)
+			transfer.Progress.finish()
+			sm.persist(transfer, true)
+			warningLog.Println(transfer.Error)
+		}
+		sm.m[id] = transfer
+	}
+
+	infoLog.Printf("loaded %v transfers from store", len(transfers))
+
+	return nil
+}
+
+func (sm *SafeTransferMap) GetStatusChanges(key string) ([]StatusChange, error) {
+	sm.mu.Lock()
+	store := sm.store
+	sm.mu.Unlock()
+	return store.getStatusChanges(key)
+}
+
+func (sm *SafeTransferMap) Close() error {
+	// waits for queued writes to be saved before closing the store. changes made after this
+	// stay in memory only
+	sm.mu.Lock()
+	sm.closed = true
+	close(sm.pending)
+	store := sm.store
+	sm.mu.Unlock()
+	<-sm.written
+	return store.close()
+}
+
 func (sm *SafeTransferMap) Get(key string) (Transfer, bool) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -95,9 +310,36 @@ func (sm *SafeTransferMap) Get(key string) (Transfer, bool) {
 }
 
 func (sm *SafeTransferMap) GetEntireMap() map[string]Transfer {
+	// returns a copy, so it can be read while transfers are changed
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	transfers := make(map[string]Transfer, len(sm.m))
+	for id, transfer := range sm.m {
+		transfers[id] = transfer
+	}
+	return transfers
+}
+
+func (sm *SafeTransferMap) GetAll() []Transfer {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	return sm.m
+	transfers := make([]Transfer, 0, len(sm.m))
+	for _, transfer := range sm.m {
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
+
+func (sm *SafeTransferMap) GetUnstopped() []Transfer {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	transfers := []Transfer{}
+	for _, transfer := range sm.m {
+		if transfer.Status == StatusQueued || transfer.Status == StatusRunning {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
 }
 
 func (sm *SafeTransferMap) Delete(key string) {
@@ -106,32 +348,54 @@ func (sm *SafeTransferMap) Delete(key string) {
 	delete(sm.m, key)
 }
 
+// transfer inputs are the bodies of transfer create requests, and the templates of schedules
+type TransferInput struct {
+	KeepFiles                     bool     `json:"keep-files"`
+	StagingUrl                    string   `json:"staging-url"`
+	SourceName                    string   `json:"source-name"`
+	SourceConnection              string   `json:"source-connection"`
+	SourceType                    string   `json:"source-type"`
+	SourceConnectionString        string   `json:"source-connection-string"`
+	TargetName                    string   `json:"target-name"`
+	TargetConnection              string   `json:"target-connection"`
+	TargetType                    string   `json:"target-type"`
+	TargetConnectionString        string   `json:"target-connection-string"`
+	TargetHostname                string   `json:"target-hostname"`
+	TargetPort                    int      `json:"target-port"`
+	TargetDatabase                string   `json:"target-database"`
+	TargetUsername                string   `json:"target-username"`
+	TargetPassword                string   `json:"target-password"`
+	TargetTableEngine             string   `json:"target-table-engine"`
+	TargetOrderBy                 string   `json:"target-order-by"`
+	TargetRowGroupSize            int      `json:"target-row-group-size"`
+	DropTargetTableIfExists       bool     `json:"drop-target-table-if-exists"`
+	CreateTargetSchemaIfNotExists bool     `json:"create-target-schema-if-not-exists"`
+	CreateTargetTableIfNotExists  bool     `json:"create-target-table-if-not-exists"`
+	SourceSchema                  string   `json:"source-schema"`
+	SourceTable                   string   `json:"source-table"`
+	TargetSchema                  string   `json:"target-schema"`
+	TargetTable                   string   `json:"target-table"`
+	Query                         string   `json:"query"`
+	IncrementalColumn             string   `json:"incremental-column"`
+	Loader                        string   `json:"loader"`
+	WriteMode                     string   `json:"write-mode"`
+	Verification                  string   `json:"verification"`
+	Cdc                           bool     `json:"cdc"`
+	CdcBatchSeconds               int      `json:"cdc-batch-seconds"`
+	CdcPosition                   string   `json:"cdc-position"`
+	Partitions                    int      `json:"partitions"`
+	PartitionColumn               string   `json:"partition-column"`
+	IncludeTables                 []string `json:"include-tables"`
+	ExcludeTables                 []string `json:"exclude-tables"`
+	ConcurrentTables              int      `json:"concurrent-tables"`
+	Delimiter                     string   `json:"delimiter"`
+	Newline                       string   `json:"newline"`
+	Null                          string   `json:"null"`
+	Priority                      int      `json:"priority"`
+}
+
 func createTransferHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		KeepFiles                     bool   `json:"keep-files"`
-		SourceName                    string `json:"source-name"`
-		SourceType                    string `json:"source-type"`
-		SourceConnectionString        string `json:"source-connection-string"`
-		TargetName                    string `json:"target-name"`
-		TargetType                    string `json:"target-type"`
-		TargetConnectionString        string `json:"target-connection-string"`
-		TargetHostname                string `json:"target-hostname"`
-		TargetPort                    int    `json:"target-port"`
-		TargetDatabase                string `json:"target-database"`
-		TargetUsername                string `json:"target-username"`
-		TargetPassword                string `json:"target-password"`
-		DropTargetTableIfExists       bool   `json:"drop-target-table-if-exists"`
-		CreateTargetSchemaIfNotExists bool   `json:"create-target-schema-if-not-exists"`
-		CreateTargetTableIfNotExists  bool   `json:"create-target-table-if-not-exists"`
-		SourceSchema                  string `json:"source-schema"`
-		SourceTable                   string `json:"source-table"`
-		TargetSchema                  string `json:"target-schema"`
-		TargetTable                   string `json:"target-table"`
-		Query                         string `json:"query"`
-		Delimiter                     string `json:"delimiter"`
-		Newline                       string `json:"newline"`
-		Null                          string `json:"null"`
-	}
+	var input TransferInput
 
 	err := readJSON(w, r, &input)
 	if err != nil {
@@ -139,13 +403,42 @@ func createTransferHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id := uuid.New().String()
+	v := newValidator()
+	transfer := newTransfer(v, input)
 
-	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs(id)
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	transfer, err = createTransfer(transfer)
+	if errors.Is(err, errShuttingDown) {
+		clientErrorResponse(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	infoLog.Printf(`ip %v created transfer %v from %v to %v`,
+		r.RemoteAddr, transfer.Id, transfer.SourceConnectionInfo.Name, transfer.TargetConnectionInfo.Name)
+
+	go executeTransfer(transfer)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/transfers/%s", transfer.Id))
+
+	err = writeJSON(w, http.StatusCreated, envelope{"transfer": transfer}, headers)
 	if err != nil {
 		serverErrorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
+}
+
+func newTransfer(v validator, input TransferInput) (transfer Transfer) {
+	// fills in defaults and stored connections, and validates the transfer. it isn't created
+	// until createTransfer is called
 
 	if input.Delimiter == "" {
 		input.Delimiter = "{dlm}"
@@ -153,13 +446,18 @@ func createTransferHandler(w http.ResponseWriter, r *http.Request) {
 	if input.Newline == "" {
 		input.Newline = "{nwln}"
 	}
-	if input.Null == "" {
-		input.Null = "{nll}"
-		if input.TargetType == TypeMySQL {
-			input.Null = `NULL`
-		}
+	if input.Loader == "" {
+		input.Loader = LoaderNative
+	}
+	if input.WriteMode == "" {
+		input.WriteMode = WriteModeAppend
+	}
+	if input.Verification == "" {
+		input.Verification = VerificationNone
+	}
+	if input.Cdc && input.CdcBatchSeconds == 0 {
+		input.CdcBatchSeconds = defaultCdcBatchSeconds
 	}
-
 	sourceConnectionInfo := ConnectionInfo{
 		Name:             input.SourceName,
 		Type:             input.SourceType,
@@ -175,24 +473,34 @@ func createTransferHandler(w http.ResponseWriter, r *http.Request) {
 		Database:         input.TargetDatabase,
 		Username:         input.TargetUsername,
 		Password:         input.TargetPassword,
+		TableEngine:      input.TargetTableEngine,
+		OrderBy:          input.TargetOrderBy,
+		RowGroupSize:     input.TargetRowGroupSize,
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	sourceConnectionInfo = getStoredConnectionInfo(v, "source", input.SourceConnection, sourceConnectionInfo)
+	targetConnectionInfo = getStoredConnectionInfo(v, "target", input.TargetConnection, targetConnectionInfo)
 
-	transfer := Transfer{
-		Id:                            id,
+	if input.Null == "" {
+		input.Null = "{nll}"
+		if targetConnectionInfo.Type == TypeMySQL {
+			input.Null = `NULL`
+		}
+	}
+
+	transfer = Transfer{
+		Id:                            uuid.New().String(),
 		CreatedAt:                     time.Now(),
+		Progress:                      newTransferProgress(),
 		Status:                        StatusQueued,
 		KeepFiles:                     input.KeepFiles,
-		TmpDir:                        tmpDir,
-		PipeFileDir:                   pipeFileDir,
-		FinalCsvDir:                   finalCsvDir,
+		StagingUrl:                    input.StagingUrl,
 		Delimiter:                     input.Delimiter,
 		Newline:                       input.Newline,
 		Null:                          input.Null,
-		Context:                       ctx,
-		Cancel:                        cancel,
+		SourceConnection:              input.SourceConnection,
 		SourceConnectionInfo:          sourceConnectionInfo,
+		TargetConnection:              input.TargetConnection,
 		TargetConnectionInfo:          targetConnectionInfo,
 		DropTargetTableIfExists:       input.DropTargetTableIfExists,
 		CreateTargetSchemaIfNotExists: input.CreateTargetSchemaIfNotExists,
@@ -202,274 +510,869 @@ func createTransferHandler(w http.ResponseWriter, r *http.Request) {
 		TargetSchema:                  input.TargetSchema,
 		TargetTable:                   input.TargetTable,
 		Query:                         input.Query,
+		IncrementalColumn:             input.IncrementalColumn,
+		Loader:                        input.Loader,
+		WriteMode:                     input.WriteMode,
+		Verification:                  input.Verification,
+		Cdc:                           input.Cdc,
+		CdcBatchSeconds:               input.CdcBatchSeconds,
+		CdcPosition:                   input.CdcPosition,
+		Partitions:                    input.Partitions,
+		PartitionColumn:               input.PartitionColumn,
+		IncludeTables:                 input.IncludeTables,
+		ExcludeTables:                 input.ExcludeTables,
+		ConcurrentTables:              input.ConcurrentTables,
+		Priority:                      input.Priority,
+	}
+
+	validateTransfer(v, transfer)
+
+	return transfer
+}
+
+func createTransfer(transfer Transfer) (Transfer, error) {
+	// gives a valid transfer its temp dirs and context, and adds it to the transfer map. it
+	// must then be run with executeTransfer
+
+	err := activeTransfers.add()
+	if err != nil {
+		return transfer, err
+	}
+
+	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs(transfer.Id)
+	if err != nil {
+		activeTransfers.done()
+		return transfer, err
+	}
+
+	transfer.TmpDir = tmpDir
+	transfer.PipeFileDir = pipeFileDir
+	transfer.FinalCsvDir = finalCsvDir
+	transfer.Context, transfer.Cancel = context.WithCancel(context.Background())
+
+	redactTransferSecrets(transfer)
+	transferMap.Set(transfer.Id, transfer)
+
+	return transfer, nil
+}
+
+func executeTransfer(transfer Transfer) {
+	// waits for a slot in the queue, runs the transfer until it stops, then removes its temp dir.
+	// its secrets are redacted until everything it logs has been logged
+
+	defer redactor.remove("transfers/" + transfer.Id)
+	defer activeTransfers.done()
+
+	if !transfer.KeepFiles {
+		defer func() {
+			err := os.RemoveAll(transfer.TmpDir)
+			if err != nil {
+				errorLog.Printf("error removing temp dir %v :: %v", transfer.TmpDir, err)
+				return
+			}
+			infoLog.Printf("temp dir %v removed", transfer.TmpDir)
+		}()
+	}
+
+	// a schema transfer's children wait in the queue instead, so the parent doesn't take a
+	// slot they could be waiting for
+	if len(transfer.IncludeTables) == 0 {
+		release, err := transferQueue.acquire(transfer)
+		if err != nil {
+			// cancelled while queued, and its status has already been set
+			return
+		}
+		defer release()
+	}
+
+	var err error
+	switch {
+	case len(transfer.IncludeTables) > 0:
+		err = runSchemaTransfer(transfer)
+	case transfer.Cdc:
+		err = runCdcTransfer(transfer)
+	default:
+		err = runTransfer(transfer)
+	}
+	if err != nil {
+		stopped, set := transferMap.SetErrorUnlessStopped(transfer.Id, fmt.Sprintf("error running transfer %v :: %v", transfer.Id, err))
+		if !set {
+			// whoever stopped it set its status, and the error is usually just the interruption
+			infoLog.Printf("transfer %v was already stopped or cancelled :: %v", transfer.Id, redactor.redact(err.Error()))
+			return
+		}
+		errorLog.Println(stopped.Error)
+	}
+}
+
+func showTransferHandler(w http.ResponseWriter, r *http.Request) {
+
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	transfer, ok := transferMap.Get(id)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	statusChanges, err := transferMap.GetStatusChanges(id)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, envelope{"transfer": transfer, "status-changes": statusChanges}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func listTransfersHandler(w http.ResponseWriter, r *http.Request) {
+
+	// get query params
+	q := r.URL.Query()
+	status := q.Get("status")
+
+	transfers := transferMap.GetEntireMap()
+
+	v := newValidator()
+	v.check(
+		permittedValue(status, Statuses...),
+		"status", fmt.Sprintf("must be empty or one of: %v", strings.Join(Statuses, ", ")),
+	)
+
+	if !v.valid() {
+		failedValidationResponse(w, r, v.errors)
+		return
+	}
+
+	if status != "" {
+		// filter by status
+		filteredTransfers := make(map[string]Transfer)
+		for id, transfer := range transfers {
+			if transfer.Status == status {
+				filteredTransfers[id] = transfer
+			}
+		}
+		transfers = filteredTransfers
+	}
+
+	err := writeJSON(w, http.StatusOK, envelope{"transfers": transfers}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func cancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+
+	params := httprouter.ParamsFromContext(r.Context())
+	id := params.ByName("id")
+
+	transfer, ok := transferMap.Get(id)
+	if !ok {
+		notFoundResponse(w, r)
+		return
+	}
+
+	if transfer.Status != StatusRunning && transfer.Status != StatusQueued {
+		clientErrorResponse(w, r, http.StatusBadRequest,
+			fmt.Errorf("cannot cancel transfer with status of %v", transfer.Status),
+		)
+		return
+	}
+
+	transfer = transferMap.CancelAndSetStatus(id, transfer, StatusCancelled)
+
+	err := writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
+	if err != nil {
+		serverErrorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func validateTransfer(v validator, transfer Transfer) {
+	// checks a transfer created by the api or the cli
+
+	v.check(transfer.SourceConnectionInfo.Name != "", "source-name", "must be provided")
+	v.check(transfer.SourceConnectionInfo.Type != "", "source-type", "must be provided")
+	v.check(transfer.SourceConnectionInfo.ConnectionString != "", "source-connection-string", "must be provided")
+	v.check(permittedValue(transfer.SourceConnectionInfo.Type, permittedTransferSources...),
+		"source-type", fmt.Sprintf("must be one of %v", permittedTransferSources))
+
+	v.check(transfer.TargetConnectionInfo.Name != "", "target-name", "must be provided")
+	v.check(transfer.TargetConnectionInfo.Type != "", "target-type", "must be provided")
+	v.check(transfer.TargetConnectionInfo.ConnectionString != "", "target-connection-string", "must be provided")
+	v.check(permittedValue(transfer.TargetConnectionInfo.Type, permittedTransferTargets...),
+		"target-type", fmt.Sprintf("must be one of %v", permittedTransferTargets))
+
+	if len(transfer.IncludeTables) > 0 {
+		if schemaRequired[transfer.SourceConnectionInfo.Type] {
+			v.check(transfer.SourceSchema != "", "source-schema", fmt.Sprintf("if include-tables is provided, must be provided for source type %v", transfer.SourceConnectionInfo.Type))
+		}
+		v.check(transfer.SourceTable == "", "source-table", "must not be provided if include-tables is provided")
+		v.check(transfer.Query == "", "query", "must not be provided if include-tables is provided")
+		v.check(transfer.TargetTable == "", "target-table", "must not be provided if include-tables is provided, tables keep their source names")
+
+		for _, pattern := range append(transfer.IncludeTables, transfer.ExcludeTables...) {
+			_, err := path.Match(pattern, "")
+			v.check(err == nil, "include-tables", fmt.Sprintf("%v is not a valid pattern", pattern))
+		}
+
+		v.check(transfer.ConcurrentTables >= 0, "concurrent-tables", "must not be negative")
+	} else {
+		v.check(len(transfer.ExcludeTables) == 0, "exclude-tables", "must not be provided if include-tables is not provided")
+		v.check(transfer.ConcurrentTables == 0, "concurrent-tables", "must not be provided if include-tables is not provided")
+
+		if transfer.Query == "" {
+			if schemaRequired[transfer.SourceConnectionInfo.Type] {
+				v.check(transfer.SourceSchema != "", "source-schema", fmt.Sprintf("if query is not provided, must be provided for source type %v", transfer.TargetConnectionInfo.Type))
+			}
+			v.check(transfer.SourceTable != "", "source-table", "must be provided if query is not provided")
+		} else {
+			v.check(transfer.SourceSchema == "", "source-schema", "must not be provided if query is provided")
+			v.check(transfer.SourceTable == "", "source-table", "must not be provided if query is provided")
+		}
+
+		if transfer.SourceSchema == "" && transfer.SourceTable == "" {
+			v.check(transfer.Query != "", "query", "must be provided if source-schema and source-table are not provided")
+		}
+
+		if transfer.SourceSchema != "" || transfer.SourceTable != "" {
+			v.check(transfer.Query == "", "query", "must not be provided if source-schema or source-table are provided")
+		}
+
+		v.check(transfer.TargetTable != "", "target-table", "must be provided")
+	}
+	if schemaRequired[transfer.TargetConnectionInfo.Type] {
+		v.check(transfer.TargetSchema != "", "target-schema", fmt.Sprintf("must be provided for target type %v", transfer.TargetConnectionInfo.Type))
+	}
+
+	if transfer.IncrementalColumn != "" {
+		v.check(transfer.Query == "", "incremental-column", "must not be provided if query is provided")
+		v.check(!transfer.DropTargetTableIfExists, "drop-target-table-if-exists", "must not be true if incremental-column is provided")
+		if transfer.TargetConnectionInfo.Type == TypeMySQL {
+			v.check(strings.Contains(transfer.TargetConnectionInfo.ConnectionString, "parseTime=true"), "target-connection-string", "must contain parseTime=true to read the incremental column from mysql")
+		}
+	}
+
+	if transfer.StagingUrl != "" {
+		_, _, err := newS3Location(transfer.StagingUrl)
+		v.check(err == nil, "staging-url", fmt.Sprint(err))
+	}
+
+	switch transfer.SourceConnectionInfo.Type {
+	case TypeMySQL:
+		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "parseTime=true"), "source-connection-string", "must contain parseTime=true to move timestamp with time zone data from mysql")
+		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "loc="), "source-connection-string", `must contain loc=<URL_ENCODED_IANA_TIME_ZONE> to move timestamp with time zone data from mysql - example: loc=US%2FPacific`)
+	case TypeDuckDB:
+		v.check(duckdbAvailable, "source-type", "this build of sqlpipe can't move data from duckdb, since it was built without cgo")
+	case TypeParquet:
+		// the parquet driver reads whole files, it doesn't run queries
+		v.check(transfer.Query == "", "query", "must not be provided for source type parquet, parquet sources read whole files")
+		v.check(transfer.IncrementalColumn == "", "incremental-column", "must not be provided for source type parquet")
+		v.check(transfer.Partitions <= 1, "partitions", "must not be greater than 1 for source type parquet")
+	case TypeCsv, TypeJsonl:
+		sourceType := transfer.SourceConnectionInfo.Type
+		_, _, err := parseFlatFileConnectionString(sourceType, transfer.SourceConnectionInfo.ConnectionString)
+		v.check(err == nil, "source-connection-string", fmt.Sprint(err))
+		// the flat file driver reads whole files, it doesn't run queries
+		v.check(transfer.Query == "", "query", fmt.Sprintf("must not be provided for source type %v, %v sources read whole files", sourceType, sourceType))
+		v.check(transfer.IncrementalColumn == "", "incremental-column", fmt.Sprintf("must not be provided for source type %v", sourceType))
+		v.check(transfer.Partitions <= 1, "partitions", fmt.Sprintf("must not be greater than 1 for source type %v", sourceType))
+	}
+
+	v.check(permittedValue(transfer.Loader, Loaders...), "loader", fmt.Sprintf("must be one of %v", Loaders))
+
+	v.check(permittedValue(transfer.WriteMode, WriteModes...), "write-mode", fmt.Sprintf("must be one of %v", WriteModes))
+	if transfer.WriteMode != WriteModeAppend {
+		v.check(!transfer.DropTargetTableIfExists, "drop-target-table-if-exists", fmt.Sprintf("must not be true if write-mode is %v", transfer.WriteMode))
+		v.check(transfer.IncrementalColumn == "", "incremental-column", fmt.Sprintf("must not be provided if write-mode is %v", transfer.WriteMode))
+	}
+	if transfer.WriteMode == WriteModeUpsert {
+		v.check(transfer.Query == "", "write-mode", "must not be upsert if query is provided, upserts match rows on the source table's primary key")
+	}
+
+	v.check(permittedValue(transfer.Verification, Verifications...), "verification", fmt.Sprintf("must be one of %v", Verifications))
+	if transfer.Verification != VerificationNone {
+		v.check(transfer.IncrementalColumn == "", "verification", "must be none if incremental-column is provided, incremental transfers delete target rows as they load")
+	}
+	if transfer.Verification == VerificationChecksum {
+		v.check(permittedValue(transfer.SourceConnectionInfo.Type, ChecksumSourceTypes...), "verification", fmt.Sprintf("must not be checksum unless source-type is one of %v", ChecksumSourceTypes))
+		v.check(transfer.Partitions <= 1, "verification", "must not be checksum if partitions is greater than 1, partitions are read on separate connections, outside of one snapshot")
+	}
+
+	if transfer.Cdc {
+		v.check(permittedValue(transfer.SourceConnectionInfo.Type, CdcSourceTypes...), "cdc", fmt.Sprintf("must not be true unless source-type is one of %v", CdcSourceTypes))
+		v.check(transfer.SourceTable != "", "cdc", "must not be true unless source-table is provided")
+		v.check(transfer.IncrementalColumn == "", "incremental-column", "must not be provided if cdc is true")
+		v.check(transfer.CdcBatchSeconds > 0, "cdc-batch-seconds", "must be greater than 0")
+		if transfer.SourceConnectionInfo.Type == TypeMySQL {
+			v.check(mysqlbinlogAvailable, "cdc", "you must install mysqlbinlog to capture changes from mysql")
+		}
+		if transfer.CdcPosition != "" {
+			v.check(permittedValue(transfer.SourceConnectionInfo.Type, CdcResumeSourceTypes...), "cdc-position", fmt.Sprintf("must not be provided unless source-type is one of %v", CdcResumeSourceTypes))
+			v.check(transfer.WriteMode == WriteModeAppend, "write-mode", "must be append if cdc-position is provided, the initial load is skipped")
+			v.check(transfer.Verification == VerificationNone, "verification", "must be none if cdc-position is provided, the initial load is skipped")
+			if transfer.SourceConnectionInfo.Type == TypeMySQL {
+				_, _, err := parseBinlogPosition(transfer.CdcPosition)
+				v.check(err == nil, "cdc-position", fmt.Sprint(err))
+			}
+		}
+	} else {
+		v.check(transfer.CdcBatchSeconds == 0, "cdc-batch-seconds", "must not be provided unless cdc is true")
+		v.check(transfer.CdcPosition == "", "cdc-position", "must not be provided unless cdc is true")
+	}
+
+	v.check(transfer.Partitions >= 0, "partitions", "must not be negative")
+	if transfer.Partitions > 1 {
+		v.check(transfer.Query == "", "partitions", "must not be greater than 1 if query is provided")
+	}
+	if transfer.PartitionColumn != "" {
+		v.check(transfer.Partitions > 1, "partition-column", "must not be provided unless partitions is greater than 1")
+	}
+
+	if transfer.TargetConnectionInfo.Type != TypeClickHouse {
+		v.check(transfer.TargetConnectionInfo.TableEngine == "", "target-table-engine", "must not be provided unless target-type is clickhouse")
+		v.check(transfer.TargetConnectionInfo.OrderBy == "", "target-order-by", "must not be provided unless target-type is clickhouse")
+	}
+
+	if transfer.TargetConnectionInfo.Type != TypeParquet && transfer.TargetConnectionInfo.Type != TypeS3 {
+		v.check(transfer.TargetConnectionInfo.RowGroupSize == 0, "target-row-group-size", "must not be provided unless target-type is parquet or s3")
+	}
+
+	switch transfer.TargetConnectionInfo.Type {
+	case TypePostgreSQL:
+		if transfer.Loader == LoaderExternal {
+			v.check(psqlAvailable, "loader", "you must install psql to use the external loader for postgresql")
+		}
+	case TypeMSSQL:
+		if transfer.Loader == LoaderExternal {
+			v.check(bcpAvailable, "loader", "you must install bcp to use the external loader for mssql")
+			bcp, err := getBcpConnection(transfer.TargetConnectionInfo)
+			v.check(err == nil, "target-connection-string", fmt.Sprint(err))
+			if err == nil {
+				message := "must be provided, or set in target-connection-string, for target type mssql with the external loader"
+				v.check(bcp.Server != "", "target-hostname", message)
+				v.check(bcp.Username != "", "target-username", message)
+				v.check(bcp.Password != "", "target-password", message)
+				v.check(bcp.Database != "", "target-database", message)
+			}
+		}
+	case TypeMySQL:
+	case TypeOracle:
+		v.check(transfer.WriteMode != WriteModeSwap, "write-mode", "must not be swap for target type oracle, oracle can't rename two tables at once, so the target would be missing for a moment")
+		if transfer.Loader == LoaderExternal {
+			v.check(sqlldrAvailable, "loader", "you must install SQL*Loader to use the external loader for oracle")
+			sqlldr, err := getSqlldrConnection(transfer.TargetConnectionInfo)
+			v.check(err == nil, "target-connection-string", fmt.Sprint(err))
+			if err == nil {
+				message := "must be provided, or set in target-connection-string, for target type oracle with the external loader"
+				v.check(sqlldr.Hostname != "", "target-hostname", message)
+				v.check(sqlldr.Username != "", "target-username", message)
+				v.check(sqlldr.Password != "", "target-password", message)
+				v.check(sqlldr.Service != "" || sqlldr.Sid != "", "target-database", message)
+			}
+		}
+	case TypeSnowflake:
+	case TypeSQLite:
+		v.check(transfer.Loader == LoaderNative, "loader", "must be native for target type sqlite, sqlite has no external loader")
+	case TypeClickHouse:
+		v.check(transfer.Loader == LoaderNative, "loader", "must be native for target type clickhouse, clickhouse loads csvs over http")
+	case TypeParquet:
+		v.check(transfer.Loader == LoaderNative, "loader", "must be native for target type parquet, sqlpipe writes parquet files itself")
+		v.check(transfer.TargetConnectionInfo.RowGroupSize >= 0, "target-row-group-size", "must not be negative")
+		// parquet files are written whole, so rows can't be changed or appended after a load
+		v.check(transfer.WriteMode != WriteModeUpsert, "write-mode", "must not be upsert for target type parquet")
+		v.check(transfer.IncrementalColumn == "", "incremental-column", "must not be provided for target type parquet")
+		v.check(!transfer.Cdc, "cdc", "must not be true for target type parquet")
+		v.check(transfer.Verification != VerificationChecksum, "verification", "must not be checksum for target type parquet")
+	case TypeS3:
+		_, format, settings, err := parseS3ConnectionString(transfer.TargetConnectionInfo.ConnectionString)
+		v.check(err == nil, "target-connection-string", fmt.Sprint(err))
+		if err == nil && format != TypeParquet {
+			_, _, err = parseFlatFileConnectionString(format, "s3?"+settings)
+			v.check(err == nil, "target-connection-string", fmt.Sprint(err))
+		}
+		if format != TypeParquet {
+			v.check(transfer.TargetConnectionInfo.RowGroupSize == 0, "target-row-group-size", "must not be provided unless the s3 format is parquet")
+		}
+		v.check(transfer.TargetConnectionInfo.RowGroupSize >= 0, "target-row-group-size", "must not be negative")
+		v.check(transfer.Loader == LoaderNative, "loader", "must be native for target type s3, sqlpipe writes s3 files itself")
+		// objects are written whole, so rows can only be added by writing the file again
+		v.check(transfer.WriteMode != WriteModeUpsert, "write-mode", "must not be upsert for target type s3")
+		v.check(transfer.IncrementalColumn == "", "incremental-column", "must not be provided for target type s3")
+		v.check(!transfer.Cdc, "cdc", "must not be true for target type s3")
+		v.check(transfer.Verification != VerificationChecksum, "verification", "must not be checksum for target type s3")
+	case TypeCsv, TypeJsonl:
+		targetType := transfer.TargetConnectionInfo.Type
+		_, _, err := parseFlatFileConnectionString(targetType, transfer.TargetConnectionInfo.ConnectionString)
+		v.check(err == nil, "target-connection-string", fmt.Sprint(err))
+		v.check(transfer.Loader == LoaderNative, "loader", fmt.Sprintf("must be native for target type %v, sqlpipe writes %v files itself", targetType, targetType))
+		// rows in flat files can only be appended, not changed
+		v.check(transfer.WriteMode != WriteModeUpsert, "write-mode", fmt.Sprintf("must not be upsert for target type %v", targetType))
+		v.check(transfer.IncrementalColumn == "", "incremental-column", fmt.Sprintf("must not be provided for target type %v", targetType))
+		v.check(!transfer.Cdc, "cdc", fmt.Sprintf("must not be true for target type %v", targetType))
+		v.check(transfer.Verification != VerificationChecksum, "verification", fmt.Sprintf("must not be checksum for target type %v", targetType))
+	case TypeDuckDB:
+		v.check(duckdbAvailable, "target-type", "this build of sqlpipe can't move data to duckdb, since it was built without cgo")
+		v.check(transfer.Loader == LoaderNative, "loader", "must be native for target type duckdb, duckdb loads csvs itself")
+		// a duckdb file takes one writer at a time, so it can't be read and written in the same transfer
+		if transfer.SourceConnectionInfo.Type == TypeDuckDB {
+			v.check(transfer.SourceConnectionInfo.ConnectionString != transfer.TargetConnectionInfo.ConnectionString, "target-connection-string", "must not be the same duckdb file as source-connection-string")
+		}
+	}
+}
+
+func runTransfer(transfer Transfer) (err error) {
+
+	transferMap.SetStatus(transfer.Id, StatusRunning, transfer)
+
+	verificationResult, err := loadTable(transfer)
+	if err != nil {
+		return err
+	}
+
+	// pipeline stages that fail cancel the transfer and set its status themselves
+	if transfer.Context.Err() != nil {
+		return nil
+	}
+
+	transfer.VerificationResult = verificationResult
+
+	transferMap.SetStatus(transfer.Id, StatusComplete, transfer)
+	infoLog.Printf("transfer %v complete", transfer.Id)
+
+	return nil
+}
+
+func loadTable(transfer Transfer) (verificationResult *VerificationResult, err error) {
+	// moves the source table or query into the target without setting the transfer's status,
+	// so change data capture can run it as the initial load
+
+	source, err := newSystem(transfer.SourceConnectionInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error creating source system :: %v", err)
+	}
+	defer source.closeConnectionPool(true)
+
+	target, err := newSystem(transfer.TargetConnectionInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error creating target system :: %v", err)
+	}
+	defer target.closeConnectionPool(true)
+
+	if target.schemaRequired() && transfer.CreateTargetSchemaIfNotExists {
+		err = createSchemaIfNotExists(transfer.TargetSchema, target)
+		if err != nil {
+			return nil, fmt.Errorf("error creating target schema :: %v", err)
+		}
+	}
+
+	if transfer.DropTargetTableIfExists {
+		err = dropTableIfExists(transfer.TargetSchema, transfer.TargetTable, target)
+		if err != nil {
+			return nil, fmt.Errorf("error dropping target table :: %v", err)
+		}
+	}
+
+	escapedSourceSchemaPeriodTable := getSchemaPeriodTable(transfer.SourceSchema, transfer.SourceTable, source, true)
+	query := transfer.Query
+	incremental := transfer.IncrementalColumn != ""
+	initialLoad := true
+	var columnInfos []ColumnInfo
+
+	if transfer.SourceTable != "" {
+
+		columnInfos, err = getTableColumnInfos(transfer.SourceSchema, transfer.SourceTable, source)
+		if err != nil {
+			return nil, fmt.Errorf("error getting source table column infos :: %v", err)
+		}
+		query = fmt.Sprintf(`SELECT * FROM %v`, escapedSourceSchemaPeriodTable)
+
+		if transfer.WriteMode == WriteModeUpsert && !hasPrimaryKey(columnInfos) {
+			return nil, errors.New("source table must have a primary key to run an upsert transfer")
+		}
+	}
+
+	if incremental {
+		query, initialLoad, err = getIncrementalQuery(query, columnInfos, transfer, source, target)
+		if err != nil {
+			return nil, fmt.Errorf("error getting incremental query :: %v", err)
+		}
+	}
+
+	queries := []string{query}
+
+	if transfer.Partitions > 1 {
+		queries, err = getPartitionQueries(query, incremental && !initialLoad, columnInfos, transfer, source)
+		if err != nil {
+			return nil, fmt.Errorf("error getting partition queries :: %v", err)
+		}
+	}
+
+	partitionRows := []*sql.Rows{}
+
+	transfer.Progress.setStage(StageQuery)
+
+	// the estimate is stopped and waited for before the source's pool is closed
+	estimateCtx, cancelEstimate := context.WithCancel(transfer.Context)
+	estimated := make(chan struct{})
+	go func() {
+		defer close(estimated)
+		estimateRows(estimateCtx, transfer, query, incremental && !initialLoad, source)
+	}()
+	defer func() {
+		cancelEstimate()
+		<-estimated
+	}()
+
+	// checksums aggregate the source in the same snapshot it's read in, so changes made to the
+	// source while the transfer runs don't show up as mismatches
+	var snapshot *sql.Tx
+	var sourceAggregates tableAggregates
+	if transfer.Verification == VerificationChecksum {
+		snapshot, err = source.beginSnapshot(transfer.Context)
+		if err != nil {
+			return nil, fmt.Errorf("error beginning source snapshot :: %v", err)
+		}
+		defer snapshot.Rollback()
+
+		sourceAggregates, err = getSourceAggregates(transfer, columnInfos, source, snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("error getting source aggregates :: %v", err)
+		}
+	}
+
+	queryStart := time.Now()
+
+	for i := range queries {
+		var rows *sql.Rows
+		if snapshot != nil {
+			rows, err = snapshot.QueryContext(transfer.Context, queries[i])
+		} else {
+			rows, err = source.query(queries[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error querying source :: %v", err)
+		}
+		defer rows.Close()
+		partitionRows = append(partitionRows, rows)
+	}
+
+	recordStageDuration(transfer, StageQuery, queryStart)
+	transfer.Progress.setStage(StagePipeFileWrite)
+
+	if transfer.Query != "" {
+		columnInfos, err = getQueryColumnInfos(partitionRows[0], source)
+		if err != nil {
+			return nil, fmt.Errorf("error getting query column infos :: %v", err)
+		}
 	}
 
-	v := newValidator()
-
-	v.check(transfer.SourceConnectionInfo.Name != "", "source-name", "must be provided")
-	v.check(transfer.SourceConnectionInfo.Type != "", "source-type", "must be provided")
-	v.check(transfer.SourceConnectionInfo.ConnectionString != "", "source-connection-string", "must be provided")
-	v.check(permittedValue(transfer.SourceConnectionInfo.Type, permittedTransferSources...),
-		"source-type", fmt.Sprintf("must be one of %v", permittedTransferSources))
-
-	v.check(transfer.TargetConnectionInfo.Name != "", "target-name", "must be provided")
-	v.check(transfer.TargetConnectionInfo.Type != "", "target-type", "must be provided")
-	v.check(transfer.TargetConnectionInfo.ConnectionString != "", "target-connection-string", "must be provided")
-	v.check(permittedValue(transfer.TargetConnectionInfo.Type, permittedTransferTargets...),
-		"target-type", fmt.Sprintf("must be one of %v", permittedTransferTargets))
+	upsert := transfer.WriteMode == WriteModeUpsert
 
-	if transfer.Query == "" {
-		if schemaRequired[transfer.SourceConnectionInfo.Type] {
-			v.check(transfer.SourceSchema != "", "source-schema", fmt.Sprintf("if query is not provided, must be provided for source type %v", transfer.TargetConnectionInfo.Type))
+	// swap transfers replace the target table with the staging table, so it isn't created here
+	if transfer.CreateTargetTableIfNotExists && transfer.WriteMode != WriteModeSwap {
+		err = createTableIfNotExists(transfer.TargetSchema, transfer.TargetTable, columnInfos, target, incremental || upsert || transfer.Cdc)
+		if err != nil {
+			return nil, fmt.Errorf("error creating target table :: %v", err)
 		}
-		v.check(transfer.SourceTable != "", "source-table", "must be provided if query is not provided")
-	} else {
-		v.check(transfer.SourceSchema == "", "source-schema", "must not be provided if query is provided")
-		v.check(transfer.SourceTable == "", "source-table", "must not be provided if query is provided")
 	}
 
-	if transfer.SourceSchema == "" && transfer.SourceTable == "" {
-		v.check(transfer.Query != "", "query", "must be provided if source-schema and source-table are not provided")
+	if transfer.WriteMode == WriteModeTruncate {
+		err = truncateTable(transfer.TargetSchema, transfer.TargetTable, target)
+		if err != nil {
+			return nil, fmt.Errorf("error truncating target table :: %v", err)
+		}
 	}
 
-	if transfer.SourceSchema != "" || transfer.SourceTable != "" {
-		v.check(transfer.Query == "", "query", "must not be provided if source-schema or source-table are provided")
+	// swap and upsert transfers load a staging table, so the target never shows a partial load
+	oldTable := fmt.Sprintf("sqlpipe_old_%v", transfer.Id[:8])
+	if transfer.WriteMode == WriteModeSwap || upsert {
+		transfer.stagingTable = fmt.Sprintf("sqlpipe_staging_%v", transfer.Id[:8])
+
+		// a swapped in staging table becomes the target, so it needs the source's primary key
+		err = createTableIfNotExists(transfer.TargetSchema, transfer.stagingTable, columnInfos, target, true)
+		if err != nil {
+			return nil, fmt.Errorf("error creating staging table :: %v", err)
+		}
+
+		// after a swap the staging table is gone, and dropping it does nothing
+		defer func() {
+			err := dropTableIfExists(transfer.TargetSchema, transfer.stagingTable, target)
+			if err != nil {
+				errorLog.Printf("transfer %v error dropping staging table :: %v", transfer.Id, err)
+			}
+		}()
 	}
 
-	v.check(transfer.TargetTable != "", "target-table", "must be provided")
-	if schemaRequired[transfer.TargetConnectionInfo.Type] {
-		v.check(transfer.TargetSchema != "", "target-schema", fmt.Sprintf("must be provided for target type %v", transfer.TargetConnectionInfo.Type))
+	// what the load table holds before loading, so appended rows can be told apart from existing rows.
+	// cdc transfers can load the table more than once, so rows read are counted from here too
+	var baseline tableAggregates
+	var rowsReadBefore int64
+	if transfer.Verification != VerificationNone {
+		rowsReadBefore = transfer.Progress.snapshot().RowsRead
+		baseline, err = getTargetAggregates(transfer, columnInfos, target)
+		if err != nil {
+			return nil, fmt.Errorf("error getting target aggregates before loading :: %v", err)
+		}
 	}
 
-	v.check(transfer.TmpDir != "", "tmp-dir", "was not set - this is a bug")
-	v.check(transfer.PipeFileDir != "", "pipe-file-dir", "was not set - this is a bug")
-	v.check(transfer.FinalCsvDir != "", "final-csv-dir", "was not set - this is a bug")
+	newPipeFiles := createPartitionedPipeFiles(columnInfos, transfer, partitionRows, source, target, incremental)
 
-	switch transfer.SourceConnectionInfo.Type {
-	case TypeMySQL:
-		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "parseTime=true"), "source-connection-string", "must contain parseTime=true to move timestamp with time zone data from mysql")
-		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "loc="), "source-connection-string", `must contain loc=<URL_ENCODED_IANA_TIME_ZONE> to move timestamp with time zone data from mysql - example: loc=US%2FPacific`)
-	}
+	stagedPipeFiles := stagePipeFiles(newPipeFiles, transfer)
 
-	switch transfer.TargetConnectionInfo.Type {
-	case TypePostgreSQL:
-		v.check(psqlAvailable, "target-type", "you must install psql to transfer data to postgresql")
-	case TypeMSSQL:
-		v.check(bcpAvailable, "target-type", "you must install bcp to transfer data to mssql")
-		v.check(transfer.TargetConnectionInfo.Port == 0, "target-port", "to change the target port for mssql, enter it after a comma in the -target-hostname flag like 127.0.0.1,1433")
-		v.check(transfer.TargetConnectionInfo.Hostname != "", "target-hostname", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Username != "", "target-username", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Password != "", "target-password", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Database != "", "target-database", "must be provided for target type mssql")
-	case TypeMySQL:
-	case TypeOracle:
-		v.check(sqlldrAvailable, "target-type", "you must install SQL*Loader to transfer data to oracle")
-		v.check(transfer.TargetConnectionInfo.Hostname != "", "target-hostname", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Username != "", "target-username", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Password != "", "target-password", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Database != "", "target-database", "must be provided for target type oracle")
-	case TypeSnowflake:
+	pksProcessedPipeFiles := deletePks(stagedPipeFiles, columnInfos, transfer, target, incremental, initialLoad)
+
+	err = insertPipeFiles(pksProcessedPipeFiles, transfer, columnInfos, target, "")
+	if err != nil {
+		return nil, fmt.Errorf("error inserting pipe files :: %v", err)
 	}
 
-	if !v.valid() {
-		failedValidationResponse(w, r, v.errors)
-		return
+	// pipeline stages that fail cancel the transfer and set its status themselves
+	if transfer.Context.Err() != nil {
+		return nil, nil
 	}
 
-	transferMap.Set(transfer.Id, transfer)
+	// swap and upsert transfers verify the staging table, so a failed check leaves the target as it was
+	if transfer.Verification != VerificationNone {
+		result, err := verifyLoad(transfer, columnInfos, baseline, sourceAggregates, rowsReadBefore, target)
+		if err != nil {
+			return nil, fmt.Errorf("error verifying load :: %v", err)
+		}
 
-	infoLog.Printf(`ip %v created transfer %v from %v to %v`,
-		r.RemoteAddr, transfer.Id, input.SourceName, input.TargetName)
+		verificationResult = &result
 
-	go func() {
-		if !transfer.KeepFiles {
-			defer func() {
-				err = os.RemoveAll(transfer.TmpDir)
-				if err != nil {
-					errorLog.Printf("error removing temp dir %v :: %v", transfer.TmpDir, err)
-					return
-				}
-				infoLog.Printf("temp dir %v removed", transfer.TmpDir)
-			}()
+		if !result.Passed {
+			current, _ := transferMap.Get(transfer.Id)
+			current.VerificationResult = &result
+			transferMap.Set(transfer.Id, current)
+			return nil, fmt.Errorf("verification failed :: %v", result.summary())
 		}
 
-		err = runTransfer(transfer)
+		infoLog.Printf("transfer %v verified :: %v", transfer.Id, result.summary())
+	}
+
+	switch transfer.WriteMode {
+	case WriteModeSwap:
+		err = swapTables(transfer.TargetSchema, transfer.stagingTable, transfer.TargetTable, oldTable, target)
 		if err != nil {
-			transfer.Error = fmt.Sprintf("error running transfer %v :: %v", transfer.Id, err)
-			transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
-			errorLog.Println(transfer.Error)
+			return nil, fmt.Errorf("error swapping staging table into target table :: %v", err)
+		}
+	case WriteModeUpsert:
+		err = upsertFromTable(transfer.TargetSchema, transfer.stagingTable, transfer.TargetTable, columnInfos, target)
+		if err != nil {
+			return nil, fmt.Errorf("error upserting staging table into target table :: %v", err)
 		}
-	}()
-
-	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/transfers/%s", transfer.Id))
-
-	err = writeJSON(w, http.StatusCreated, envelope{"transfer": transfer}, headers)
-	if err != nil {
-		serverErrorResponse(w, r, http.StatusInternalServerError, err)
-		return
 	}
+
+	return verificationResult, nil
 }
 
-func showTransferHandler(w http.ResponseWriter, r *http.Request) {
+func getIncrementalQuery(query string, columnInfos []ColumnInfo, transfer Transfer, source, target System) (incrementalQuery string, initialLoad bool, err error) {
+	// finds the incremental column and primary keys in the source table, then limits the
+	// source query to rows at or after the max incremental value already in the target
 
-	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	var incrementalColumnInfo ColumnInfo
+	incrementalColumnFound := false
+	pkFound := false
 
-	transfer, ok := transferMap.Get(id)
-	if !ok {
-		notFoundResponse(w, r)
-		return
+	for i := range columnInfos {
+		if strings.EqualFold(columnInfos[i].Name, transfer.IncrementalColumn) {
+			incrementalColumnInfo = columnInfos[i]
+			incrementalColumnFound = true
+		}
+		if columnInfos[i].IsPrimaryKey {
+			pkFound = true
+		}
 	}
 
-	err := writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
-	if err != nil {
-		serverErrorResponse(w, r, http.StatusInternalServerError, err)
-		return
+	if !incrementalColumnFound {
+		return query, false, fmt.Errorf("incremental column %v not found in source table", transfer.IncrementalColumn)
 	}
-}
 
-func listTransfersHandler(w http.ResponseWriter, r *http.Request) {
-
-	// get query params
-	q := r.URL.Query()
-	status := q.Get("status")
+	if !pkFound {
+		return query, false, errors.New("source table must have a primary key to run an incremental transfer")
+	}
 
-	transfers := transferMap.GetEntireMap()
+	switch incrementalColumnInfo.PipeType {
+	case "datetime", "datetimetz", "date":
+	default:
+		return query, false, fmt.Errorf("incremental column %v must be a date or timestamp, but has a pipe type of %v", incrementalColumnInfo.Name, incrementalColumnInfo.PipeType)
+	}
 
-	v := newValidator()
-	v.check(
-		permittedValue(status, Statuses...),
-		"status", fmt.Sprintf("must be empty or one of: %v", strings.Join(Statuses, ", ")),
-	)
+	incrementalTime, initialLoad, err := getIncrementalTime(transfer.TargetSchema, transfer.TargetTable, incrementalColumnInfo.Name, target)
+	if err != nil {
+		return query, false, fmt.Errorf("error getting incremental time :: %v", err)
+	}
 
-	if !v.valid() {
-		failedValidationResponse(w, r, v.errors)
-		return
+	if initialLoad {
+		infoLog.Printf("transfer %v found no rows in target, running initial load", transfer.Id)
+		return query, true, nil
 	}
 
-	if status != "" {
-		// filter by status
-		filteredTransfers := make(map[string]Transfer)
-		for id, transfer := range transfers {
-			if transfer.Status == status {
-				filteredTransfers[id] = transfer
-			}
-		}
-		transfers = filteredTransfers
+	// datetimetz values are written to the target in utc, so the instant is converted to utc
+	if incrementalColumnInfo.PipeType == "datetimetz" {
+		incrementalTime = incrementalTime.UTC()
 	}
 
-	err := writeJSON(w, http.StatusOK, envelope{"transfers": transfers}, nil)
+	sqlFormatters := source.getSqlFormatters()
+
+	incrementalValue, err := sqlFormatters[incrementalColumnInfo.PipeType](incrementalTime.Format(time.RFC3339Nano))
 	if err != nil {
-		serverErrorResponse(w, r, http.StatusInternalServerError, err)
-		return
+		return query, false, fmt.Errorf("error formatting incremental time :: %v", err)
 	}
+
+	infoLog.Printf("transfer %v pulling rows where %v >= %v", transfer.Id, incrementalColumnInfo.Name, incrementalValue)
+
+	// rows equal to the watermark are pulled again, their pks are deleted from the target before inserting
+	incrementalQuery = fmt.Sprintf("%v WHERE %v >= %v", query, escapeIfNeeded(incrementalColumnInfo.Name, source), incrementalValue)
+
+	return incrementalQuery, false, nil
 }
 
-func cancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+func getPartitionQueries(query string, hasWhere bool, columnInfos []ColumnInfo, transfer Transfer, source System) (partitionQueries []string, err error) {
+	// splits a table scan into ranges on the partition column, so they can be read concurrently.
+	// the first and last ranges are open ended, so every row lands in exactly one partition
+	// even if the bounds are rounded when they are formatted
 
-	params := httprouter.ParamsFromContext(r.Context())
-	id := params.ByName("id")
+	var partitionColumnInfo ColumnInfo
+	partitionColumnFound := false
 
-	transfer, ok := transferMap.Get(id)
-	if !ok {
-		notFoundResponse(w, r)
-		return
+	for i := range columnInfos {
+		if transfer.PartitionColumn != "" {
+			if strings.EqualFold(columnInfos[i].Name, transfer.PartitionColumn) {
+				partitionColumnInfo = columnInfos[i]
+				partitionColumnFound = true
+				break
+			}
+		} else if columnInfos[i].IsPrimaryKey && permittedValue(columnInfos[i].PipeType, partitionPipeTypes...) {
+			partitionColumnInfo = columnInfos[i]
+			partitionColumnFound = true
+			break
+		}
 	}
 
-	if transfer.Status != StatusRunning {
-		clientErrorResponse(w, r, http.StatusBadRequest,
-			fmt.Errorf("cannot cancel transfer with status of %v", transfer.Status),
-		)
-		return
+	if !partitionColumnFound {
+		if transfer.PartitionColumn != "" {
+			return nil, fmt.Errorf("partition column %v not found in source table", transfer.PartitionColumn)
+		}
+		return nil, fmt.Errorf("source table has no numeric or date primary key column to partition on, please provide a partition-column")
 	}
 
-	transfer = transferMap.CancelAndSetStatus(id, transfer, StatusCancelled)
+	if !permittedValue(partitionColumnInfo.PipeType, partitionPipeTypes...) {
+		return nil, fmt.Errorf("partition column %v must have a pipe type of one of %v, but has a pipe type of %v", partitionColumnInfo.Name, partitionPipeTypes, partitionColumnInfo.PipeType)
+	}
 
-	err := writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
+	escapedColumn := escapeIfNeeded(partitionColumnInfo.Name, source)
+
+	bounds, err := getPartitionBounds(query, escapedColumn, partitionColumnInfo.PipeType, transfer.Partitions, source)
 	if err != nil {
-		serverErrorResponse(w, r, http.StatusInternalServerError, err)
-		return
+		return nil, fmt.Errorf("error getting partition bounds :: %v", err)
 	}
-}
-
-func runTransfer(transfer Transfer) (err error) {
 
-	transferMap.SetStatus(transfer.Id, StatusRunning, transfer)
+	if len(bounds) == 0 {
+		infoLog.Printf("transfer %v found nothing to partition on %v, reading source in one query", transfer.Id, partitionColumnInfo.Name)
+		return []string{query}, nil
+	}
 
-	source, err := newSystem(transfer.SourceConnectionInfo)
-	if err != nil {
-		return fmt.Errorf("error creating source system :: %v", err)
+	keyword := "WHERE"
+	if hasWhere {
+		keyword = "AND"
 	}
-	defer source.closeConnectionPool(true)
 
-	target, err := newSystem(transfer.TargetConnectionInfo)
-	if err != nil {
-		return fmt.Errorf("error creating target system :: %v", err)
+	partitionQueries = append(partitionQueries,
+		fmt.Sprintf("%v %v (%v < %v OR %v IS NULL)", query, keyword, escapedColumn, bounds[0], escapedColumn))
+
+	for i := 1; i < len(bounds); i++ {
+		partitionQueries = append(partitionQueries,
+			fmt.Sprintf("%v %v %v >= %v AND %v < %v", query, keyword, escapedColumn, bounds[i-1], escapedColumn, bounds[i]))
 	}
-	defer target.closeConnectionPool(true)
 
-	if target.schemaRequired() && transfer.CreateTargetSchemaIfNotExists {
-		err = createSchemaIfNotExists(transfer.TargetSchema, target)
-		if err != nil {
-			return fmt.Errorf("error creating target schema :: %v", err)
+	partitionQueries = append(partitionQueries,
+		fmt.Sprintf("%v %v %v >= %v", query, keyword, escapedColumn, bounds[len(bounds)-1]))
+
+	infoLog.Printf("transfer %v reading source in %v partitions on %v", transfer.Id, len(partitionQueries), partitionColumnInfo.Name)
+
+	return partitionQueries, nil
+}
+
+func getPartitionBounds(query, escapedColumn, pipeType string, partitions int, source System) (bounds []string, err error) {
+	// gets the min and max of the partition column and splits the range between them evenly.
+	// returns no bounds if the table is empty or the column only has one value
+
+	minMaxQuery := strings.Replace(query, "SELECT *", fmt.Sprintf("SELECT MIN(%v), MAX(%v)", escapedColumn, escapedColumn), 1)
+
+	addBound := func(bound string) {
+		// bounds can repeat when the range has fewer values than there are partitions
+		if len(bounds) == 0 || bounds[len(bounds)-1] != bound {
+			bounds = append(bounds, bound)
 		}
 	}
 
-	if transfer.DropTargetTableIfExists {
-		err = dropTableIfExists(transfer.TargetSchema, transfer.TargetTable, target)
+	switch pipeType {
+	case "date", "datetime", "datetimetz":
+		var minTime, maxTime sql.NullTime
+		err = source.queryRow(minMaxQuery).Scan(&minTime, &maxTime)
 		if err != nil {
-			return fmt.Errorf("error dropping target table :: %v", err)
+			return nil, fmt.Errorf("error scanning min and max of partition column :: %v", err)
 		}
-	}
 
-	escapedSourceSchemaPeriodTable := getSchemaPeriodTable(transfer.SourceSchema, transfer.SourceTable, source, true)
-	query := transfer.Query
-	initialLoad := true
-	var columnInfos []ColumnInfo
+		if !minTime.Valid || !maxTime.Valid || !maxTime.Time.After(minTime.Time) {
+			return nil, nil
+		}
 
-	if transfer.SourceTable != "" {
+		sqlFormatters := source.getSqlFormatters()
+		step := maxTime.Time.Sub(minTime.Time) / time.Duration(partitions)
 
-		columnInfos, err = getTableColumnInfos(transfer.SourceSchema, transfer.SourceTable, source)
+		for i := 1; i < partitions; i++ {
+			bound, err := sqlFormatters[pipeType](minTime.Time.Add(step * time.Duration(i)).Format(time.RFC3339Nano))
+			if err != nil {
+				return nil, fmt.Errorf("error formatting partition bound :: %v", err)
+			}
+			addBound(bound)
+		}
+	default:
+		var minString, maxString sql.NullString
+		err = source.queryRow(minMaxQuery).Scan(&minString, &maxString)
 		if err != nil {
-			return fmt.Errorf("error getting source table column infos :: %v", err)
+			return nil, fmt.Errorf("error scanning min and max of partition column :: %v", err)
 		}
-		query = fmt.Sprintf(`SELECT * FROM %v`, escapedSourceSchemaPeriodTable)
-
-	}
 
-	rows, err := source.query(query)
-	if err != nil {
-		return fmt.Errorf("error querying source :: %v", err)
-	}
-	defer rows.Close()
+		if !minString.Valid || !maxString.Valid {
+			return nil, nil
+		}
 
-	if transfer.Query != "" {
-		columnInfos, err = getQueryColumnInfos(rows, source)
+		minValue, err := strconv.ParseFloat(strings.TrimSpace(minString.String), 64)
 		if err != nil {
-			return fmt.Errorf("error getting query column infos :: %v", err)
+			return nil, fmt.Errorf("error parsing min of partition column :: %v", err)
 		}
-	}
 
-	if transfer.CreateTargetTableIfNotExists {
-		err = createTableIfNotExists(transfer.TargetSchema, transfer.TargetTable, columnInfos, target, false)
+		maxValue, err := strconv.ParseFloat(strings.TrimSpace(maxString.String), 64)
 		if err != nil {
-			return fmt.Errorf("error creating target table :: %v", err)
+			return nil, fmt.Errorf("error parsing max of partition column :: %v", err)
 		}
-	}
 
-	newPipeFiles := createPipeFiles(columnInfos, transfer, rows, source, target, false)
+		if maxValue <= minValue {
+			return nil, nil
+		}
 
-	pksProcessedPipeFiles := deletePks(newPipeFiles, columnInfos, transfer, target, false, initialLoad)
+		step := (maxValue - minValue) / float64(partitions)
 
-	err = insertPipeFiles(pksProcessedPipeFiles, transfer, columnInfos, target, "")
-	if err != nil {
-		return fmt.Errorf("error inserting pipe files :: %v", err)
+		for i := 1; i < partitions; i++ {
+			boundValue := minValue + step*float64(i)
+			switch pipeType {
+			case "int64", "int32", "int16":
+				addBound(strconv.FormatInt(int64(math.Floor(boundValue)), 10))
+			default:
+				addBound(strconv.FormatFloat(boundValue, 'f', -1, 64))
+			}
+		}
 	}
 
-	transferMap.SetStatus(transfer.Id, StatusComplete, transfer)
-	infoLog.Printf("transfer %v complete", transfer.Id)
-
-	return nil
+	return bounds, nil
 }
 
 func createTransferTmpDirs(transferId string) (tmpDir, pipeFileDir, finalCsvDir string, err error) {
@@ -519,10 +1422,13 @@ func createTransferTmpDirs(transferId string) (tmpDir, pipeFileDir, finalCsvDir
 
 type CliTransferInput struct {
 	KeepFiles                     bool
+	StagingUrl                    string
 	SourceName                    string
+	SourceConnection              string
 	SourceType                    string
 	SourceConnectionString        string
 	TargetName                    string
+	TargetConnection              string
 	TargetType                    string
 	TargetConnectionString        string
 	TargetHostname                string
@@ -530,6 +1436,9 @@ type CliTransferInput struct {
 	TargetDatabase                string
 	TargetUsername                string
 	TargetPassword                string
+	TargetTableEngine             string
+	TargetOrderBy                 string
+	TargetRowGroupSize            int
 	DropTargetTableIfExists       bool
 	CreateTargetSchemaIfNotExists bool
 	CreateTargetTableIfNotExists  bool
@@ -538,6 +1447,18 @@ type CliTransferInput struct {
 	TargetSchema                  string
 	TargetTable                   string
 	Query                         string
+	IncrementalColumn             string
+	Loader                        string
+	WriteMode                     string
+	Verification                  string
+	Cdc                           bool
+	CdcBatchSeconds               int
+	CdcPosition                   string
+	Partitions                    int
+	PartitionColumn               string
+	IncludeTables                 []string
+	ExcludeTables                 []string
+	ConcurrentTables              int
 	Delimiter                     string
 	Newline                       string
 	Null                          string
@@ -545,6 +1466,13 @@ type CliTransferInput struct {
 
 func handleCliTransfer(cliTransferInput CliTransferInput) {
 
+	if cliTransferInput.SourceConnection != "" || cliTransferInput.TargetConnection != "" {
+		err := loadCliConnections()
+		if err != nil {
+			errorLog.Fatalf("error loading stored connections :: %v", err)
+		}
+	}
+
 	id := uuid.New().String()
 
 	tmpDir, pipeFileDir, finalCsvDir, err := createTransferTmpDirs(id)
@@ -558,13 +1486,18 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 	if cliTransferInput.Newline == "" {
 		cliTransferInput.Newline = "{nwln}"
 	}
-	if cliTransferInput.Null == "" {
-		cliTransferInput.Null = "{nll}"
-		if cliTransferInput.TargetType == TypeMySQL {
-			cliTransferInput.Null = `NULL`
-		}
+	if cliTransferInput.Loader == "" {
+		cliTransferInput.Loader = LoaderNative
+	}
+	if cliTransferInput.WriteMode == "" {
+		cliTransferInput.WriteMode = WriteModeAppend
+	}
+	if cliTransferInput.Verification == "" {
+		cliTransferInput.Verification = VerificationNone
+	}
+	if cliTransferInput.Cdc && cliTransferInput.CdcBatchSeconds == 0 {
+		cliTransferInput.CdcBatchSeconds = defaultCdcBatchSeconds
 	}
-
 	sourceConnectionInfo := ConnectionInfo{
 		Name:             cliTransferInput.SourceName,
 		Type:             cliTransferInput.SourceType,
@@ -580,6 +1513,20 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 		Database:         cliTransferInput.TargetDatabase,
 		Username:         cliTransferInput.TargetUsername,
 		Password:         cliTransferInput.TargetPassword,
+		TableEngine:      cliTransferInput.TargetTableEngine,
+		OrderBy:          cliTransferInput.TargetOrderBy,
+		RowGroupSize:     cliTransferInput.TargetRowGroupSize,
+	}
+
+	v := newValidator()
+	sourceConnectionInfo = getStoredConnectionInfo(v, "source", cliTransferInput.SourceConnection, sourceConnectionInfo)
+	targetConnectionInfo = getStoredConnectionInfo(v, "target", cliTransferInput.TargetConnection, targetConnectionInfo)
+
+	if cliTransferInput.Null == "" {
+		cliTransferInput.Null = "{nll}"
+		if targetConnectionInfo.Type == TypeMySQL {
+			cliTransferInput.Null = `NULL`
+		}
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -587,8 +1534,10 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 	transfer := Transfer{
 		Id:                            id,
 		CreatedAt:                     time.Now(),
+		Progress:                      newTransferProgress(),
 		Status:                        StatusQueued,
 		KeepFiles:                     cliTransferInput.KeepFiles,
+		StagingUrl:                    cliTransferInput.StagingUrl,
 		TmpDir:                        tmpDir,
 		PipeFileDir:                   pipeFileDir,
 		FinalCsvDir:                   finalCsvDir,
@@ -597,7 +1546,9 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 		Null:                          cliTransferInput.Null,
 		Context:                       ctx,
 		Cancel:                        cancel,
+		SourceConnection:              cliTransferInput.SourceConnection,
 		SourceConnectionInfo:          sourceConnectionInfo,
+		TargetConnection:              cliTransferInput.TargetConnection,
 		TargetConnectionInfo:          targetConnectionInfo,
 		DropTargetTableIfExists:       cliTransferInput.DropTargetTableIfExists,
 		CreateTargetSchemaIfNotExists: cliTransferInput.CreateTargetSchemaIfNotExists,
@@ -607,83 +1558,32 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 		TargetSchema:                  cliTransferInput.TargetSchema,
 		TargetTable:                   cliTransferInput.TargetTable,
 		Query:                         cliTransferInput.Query,
+		IncrementalColumn:             cliTransferInput.IncrementalColumn,
+		Loader:                        cliTransferInput.Loader,
+		WriteMode:                     cliTransferInput.WriteMode,
+		Verification:                  cliTransferInput.Verification,
+		Cdc:                           cliTransferInput.Cdc,
+		CdcBatchSeconds:               cliTransferInput.CdcBatchSeconds,
+		CdcPosition:                   cliTransferInput.CdcPosition,
+		Partitions:                    cliTransferInput.Partitions,
+		PartitionColumn:               cliTransferInput.PartitionColumn,
+		IncludeTables:                 cliTransferInput.IncludeTables,
+		ExcludeTables:                 cliTransferInput.ExcludeTables,
+		ConcurrentTables:              cliTransferInput.ConcurrentTables,
 	}
 
-	v := newValidator()
-
-	v.check(transfer.SourceConnectionInfo.Name != "", "source-name", "must be provided")
-	v.check(transfer.SourceConnectionInfo.Type != "", "source-type", "must be provided")
-	v.check(transfer.SourceConnectionInfo.ConnectionString != "", "source-connection-string", "must be provided")
-	v.check(permittedValue(transfer.SourceConnectionInfo.Type, permittedTransferSources...),
-		"source-type", fmt.Sprintf("must be one of %v", permittedTransferSources))
-
-	v.check(transfer.TargetConnectionInfo.Name != "", "target-name", "must be provided")
-	v.check(transfer.TargetConnectionInfo.Type != "", "target-type", "must be provided")
-	v.check(transfer.TargetConnectionInfo.ConnectionString != "", "target-connection-string", "must be provided")
-	v.check(permittedValue(transfer.TargetConnectionInfo.Type, permittedTransferTargets...),
-		"target-type", fmt.Sprintf("must be one of %v", permittedTransferTargets))
-
-	if transfer.Query == "" {
-		if schemaRequired[transfer.SourceConnectionInfo.Type] {
-			v.check(transfer.SourceSchema != "", "source-schema", fmt.Sprintf("if query is not provided, must be provided for source type %v", transfer.TargetConnectionInfo.Type))
-		}
-		v.check(transfer.SourceTable != "", "source-table", "must be provided if query is not provided")
-	} else {
-		v.check(transfer.SourceSchema == "", "source-schema", "must not be provided if query is provided")
-		v.check(transfer.SourceTable == "", "source-table", "must not be provided if query is provided")
-	}
-
-	if transfer.SourceSchema == "" && transfer.SourceTable == "" {
-		v.check(transfer.Query != "", "query", "must be provided if source-schema and source-table are not provided")
-	}
-
-	if transfer.SourceSchema != "" || transfer.SourceTable != "" {
-		v.check(transfer.Query == "", "query", "must not be provided if source-schema or source-table are provided")
-	}
-
-	v.check(transfer.TargetTable != "", "target-table", "must be provided")
-	if schemaRequired[transfer.TargetConnectionInfo.Type] {
-		v.check(transfer.TargetSchema != "", "target-schema", fmt.Sprintf("must be provided for target type %v", transfer.TargetConnectionInfo.Type))
-	}
-
-	v.check(transfer.TmpDir != "", "tmp-dir", "was not set - this is a bug")
-	v.check(transfer.PipeFileDir != "", "pipe-file-dir", "was not set - this is a bug")
-	v.check(transfer.FinalCsvDir != "", "final-csv-dir", "was not set - this is a bug")
-
-	switch transfer.SourceConnectionInfo.Type {
-	case TypeMySQL:
-		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "parseTime=true"), "source-connection-string", "must contain parseTime=true to move timestamp with time zone data from mysql")
-		v.check(strings.Contains(transfer.SourceConnectionInfo.ConnectionString, "loc="), "source-connection-string", `must contain loc=<URL_ENCODED_IANA_TIME_ZONE> to move timestamp with time zone data from mysql - example: loc=US%2FPacific`)
-	}
-
-	switch transfer.TargetConnectionInfo.Type {
-	case TypePostgreSQL:
-		v.check(psqlAvailable, "target-type", "you must install psql to transfer data to postgresql")
-	case TypeMSSQL:
-		v.check(bcpAvailable, "target-type", "you must install bcp to transfer data to mssql")
-		v.check(transfer.TargetConnectionInfo.Port == 0, "target-port", "to change the target port for mssql, enter it after a comma in the -target-hostname flag like 127.0.0.1,1433")
-		v.check(transfer.TargetConnectionInfo.Hostname != "", "target-hostname", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Username != "", "target-username", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Password != "", "target-password", "must be provided for target type mssql")
-		v.check(transfer.TargetConnectionInfo.Database != "", "target-database", "must be provided for target type mssql")
-	case TypeMySQL:
-	case TypeOracle:
-		v.check(sqlldrAvailable, "target-type", "you must install SQL*Loader to transfer data to oracle")
-		v.check(transfer.TargetConnectionInfo.Hostname != "", "target-hostname", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Username != "", "target-username", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Password != "", "target-password", "must be provided for target type oracle")
-		v.check(transfer.TargetConnectionInfo.Database != "", "target-database", "must be provided for target type oracle")
-	case TypeSnowflake:
-	}
+	validateTransfer(v, transfer)
 
 	if !v.valid() {
 		errorLog.Fatalf("error validating transfer :: %v", v.errors)
 	}
 
+	redactTransferSecrets(transfer)
+
 	transferMap.Set(transfer.Id, transfer)
 
 	infoLog.Printf(`created transfer %v from %v to %v`,
-		transfer.Id, cliTransferInput.SourceName, cliTransferInput.TargetName)
+		transfer.Id, transfer.SourceConnectionInfo.Name, transfer.TargetConnectionInfo.Name)
 
 	if !transfer.KeepFiles {
 		defer func() {
@@ -696,10 +1596,33 @@ func handleCliTransfer(cliTransferInput CliTransferInput) {
 		}()
 	}
 
-	err = runTransfer(transfer)
+	stopLoggingProgress := logProgress(cliProgressInterval)
+
+	switch {
+	case len(transfer.IncludeTables) > 0:
+		err = runSchemaTransfer(transfer)
+	case transfer.Cdc:
+		// cdc transfers run until they are interrupted
+		stopCancellingOnInterrupt := cancelOnInterrupt(transfer)
+		err = runCdcTransfer(transfer)
+		stopCancellingOnInterrupt()
+	default:
+		err = runTransfer(transfer)
+	}
+	stopLoggingProgress()
 	if err != nil {
-		transfer.Error = fmt.Sprintf("error running transfer %v :: %v", transfer.Id, err)
-		transferMap.CancelAndSetStatus(transfer.Id, transfer, StatusError)
-		errorLog.Fatalf(transfer.Error)
+		stopped, set := transferMap.SetErrorUnlessStopped(transfer.Id, fmt.Sprintf("error running transfer %v :: %v", transfer.Id, err))
+		if set {
+			errorLog.Fatalf(stopped.Error)
+		}
+	}
+
+	transfer, _ = transferMap.Get(transfer.Id)
+	if transfer.Cdc && transfer.Status == StatusCancelled {
+		return
+	}
+
+	if transfer.Status != StatusComplete {
+		errorLog.Fatalf("transfer %v finished with status %v :: %v", transfer.Id, transfer.Status, transfer.Error)
 	}
 }
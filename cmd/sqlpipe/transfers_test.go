@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newPartitionTestSource(t *testing.T, values []string) System {
+	// a sqlite table with an integer primary key, and a real column holding the given values
+
+	path := filepath.Join(t.TempDir(), "partitions.db")
+
+	db, err := sql.Open(DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`create table numbers (id integer primary key, value real)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range values {
+		_, err = db.Exec(fmt.Sprintf(`insert into numbers (id, value) values (%v, %v)`, value, value))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source, err := newSystem(ConnectionInfo{Name: "source", Type: TypeSQLite, ConnectionString: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { source.closeConnectionPool(true) })
+
+	return source
+}
+
+func TestGetPartitionBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []string
+		column     string
+		pipeType   string
+		partitions int
+		want       []string
+	}{
+		{"uneven integer range", []string{"1", "2", "3", "10", "100"}, "id", "int64", 4, []string{"25", "50", "75"}},
+		{"integer bounds are floored", []string{"0", "10"}, "id", "int64", 3, []string{"3", "6"}},
+		{"fewer values than partitions", []string{"1", "2", "3"}, "id", "int64", 8, []string{"1", "2"}},
+		{"float range", []string{"0", "1"}, "value", "float64", 4, []string{"0.25", "0.5", "0.75"}},
+		{"negative range", []string{"-10", "10"}, "id", "int64", 2, []string{"0"}},
+		{"single row", []string{"5"}, "id", "int64", 4, nil},
+		{"empty table", nil, "id", "int64", 4, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := newPartitionTestSource(t, test.values)
+
+			got, err := getPartitionBounds("SELECT * FROM numbers", test.column, test.pipeType, test.partitions, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetPartitionBoundsMinEqualsMax(t *testing.T) {
+	source := newPartitionTestSource(t, []string{"1", "2", "3"})
+
+	err := source.exec("update numbers set value = 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := getPartitionBounds("SELECT * FROM numbers", "value", "float64", 4, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Fatalf("got %v, want no bounds", got)
+	}
+}
+
+func TestGetPartitionQueries(t *testing.T) {
+	// every row must be read by exactly one partition, whatever the spread of the column
+
+	tests := []struct {
+		name        string
+		values      []string
+		partitions  int
+		wantQueries int
+	}{
+		{"uneven range", []string{"1", "2", "3", "10", "100", "101", "1000"}, 4, 4},
+		{"fewer values than partitions", []string{"1", "2", "3"}, 8, 3},
+		{"single row", []string{"5"}, 4, 1},
+		{"empty table", nil, 4, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := newPartitionTestSource(t, test.values)
+
+			columnInfos, err := getTableColumnInfos("", "numbers", source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transfer := Transfer{Id: "partitions", Partitions: test.partitions}
+
+			queries, err := getPartitionQueries("SELECT * FROM numbers", false, columnInfos, transfer, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(queries) != test.wantQueries {
+				t.Fatalf("got %v queries, want %v :: %v", len(queries), test.wantQueries, queries)
+			}
+
+			got := []string{}
+			for _, query := range queries {
+				got = append(got, readPartitionIds(t, source, query)...)
+			}
+			sort.Strings(got)
+
+			want := append([]string{}, test.values...)
+			sort.Strings(want)
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("partitions read %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetPartitionQueriesMinEqualsMax(t *testing.T) {
+	source := newPartitionTestSource(t, []string{"1", "2", "3"})
+
+	err := source.exec("update numbers set value = 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columnInfos, err := getTableColumnInfos("", "numbers", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := Transfer{Id: "partitions", Partitions: 4, PartitionColumn: "value"}
+
+	queries, err := getPartitionQueries("SELECT * FROM numbers", false, columnInfos, transfer, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(queries, []string{"SELECT * FROM numbers"}) {
+		t.Fatalf("got %v, want the query unpartitioned", queries)
+	}
+}
+
+func readPartitionIds(t *testing.T, source System, query string) (ids []string) {
+	rows, err := source.query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var value sql.NullFloat64
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func TestSetErrorUnlessStopped(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		cancelled  bool
+		wantSet    bool
+		wantStatus string
+	}{
+		{"queued", StatusQueued, false, true, StatusError},
+		{"running", StatusRunning, false, true, StatusError},
+		{"running but cancelled", StatusRunning, true, false, StatusRunning},
+		{"cancelled", StatusCancelled, true, false, StatusCancelled},
+		{"already errored", StatusError, true, false, StatusError},
+		{"complete", StatusComplete, false, false, StatusComplete},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.cancelled {
+				cancel()
+			}
+
+			id := "set-error-" + test.status
+			transferMap.Set(id, Transfer{Id: id, Status: test.status, Error: "earlier error", Progress: newTransferProgress(), Context: ctx, Cancel: cancel})
+			defer transferMap.Delete(id)
+
+			_, set := transferMap.SetErrorUnlessStopped(id, "error running transfer")
+			if set != test.wantSet {
+				t.Fatalf("got set %v, want %v", set, test.wantSet)
+			}
+
+			got, _ := transferMap.Get(id)
+			if got.Status != test.wantStatus {
+				t.Fatalf("got status %v, want %v", got.Status, test.wantStatus)
+			}
+
+			wantError := "earlier error"
+			if test.wantSet {
+				wantError = "error running transfer"
+				if ctx.Err() == nil {
+					t.Fatal("transfer wasn't cancelled when its error was set")
+				}
+			}
+			if got.Error != wantError {
+				t.Fatalf("got error %q, want %q", got.Error, wantError)
+			}
+		})
+	}
+}
+
+func TestSetErrorUnlessStoppedUnknownTransfer(t *testing.T) {
+	_, set := transferMap.SetErrorUnlessStopped("no-such-transfer", "error running transfer")
+	if set {
+		t.Fatal("set an error on a transfer that doesn't exist")
+	}
+}
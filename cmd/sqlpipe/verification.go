@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type VerificationResult struct {
+	Passed     bool             `json:"passed"`
+	RowsRead   int64            `json:"rows-read"`
+	TargetRows int64            `json:"target-rows"`
+	Checksums  []ColumnChecksum `json:"checksums,omitempty"`
+	VerifiedAt string           `json:"verified-at"`
+}
+
+type ColumnChecksum struct {
+	Column    string `json:"column"`
+	Aggregate string `json:"aggregate"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Match     bool   `json:"match"`
+}
+
+var (
+	ChecksumSum        = "sum"
+	ChecksumSumLengths = "sum-of-lengths"
+	ChecksumCount      = "count"
+)
+
+type tableAggregates struct {
+	rows   int64
+	values []*big.Float
+}
+
+func getChecksumKind(columnInfo ColumnInfo) (kind string) {
+	// every aggregate adds up over rows, so rows appended to a table that already has
+	// rows can be checked by subtracting what the table held before the load
+
+	switch columnInfo.PipeType {
+	case "int16", "int32", "int64", "float32", "float64", "decimal":
+		return ChecksumSum
+	case "nvarchar", "varchar":
+		return ChecksumSumLengths
+	default:
+		return ChecksumCount
+	}
+}
+
+func getChecksumAggregate(columnInfo ColumnInfo, system System) (aggregate string) {
+	kind := getChecksumKind(columnInfo)
+
+	aggregate, overridden := system.checksumAggregateOverride(columnInfo, kind)
+	if overridden {
+		return aggregate
+	}
+
+	escapedColumn := escapeIfNeeded(columnInfo.Name, system)
+
+	switch kind {
+	case ChecksumSum:
+		return fmt.Sprintf("sum(%v)", escapedColumn)
+	case ChecksumSumLengths:
+		return fmt.Sprintf("sum(length(%v))", escapedColumn)
+	default:
+		return fmt.Sprintf("count(%v)", escapedColumn)
+	}
+}
+
+func getAggregates(from string, columnInfos []ColumnInfo, checksum bool, system System, snapshot *sql.Tx) (aggregates tableAggregates, err error) {
+	// the source is aggregated in the snapshot it's read in, the target outside of one
+
+	selects := []string{"count(*)"}
+	if checksum {
+		for i := range columnInfos {
+			selects = append(selects, getChecksumAggregate(columnInfos[i], system))
+		}
+	}
+
+	query := fmt.Sprintf("select %v from %v", strings.Join(selects, ", "), from)
+
+	values := make([]sql.NullString, len(selects)-1)
+	dest := []interface{}{&aggregates.rows}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	var row *sql.Row
+	if snapshot != nil {
+		row = snapshot.QueryRow(query)
+	} else {
+		row = system.queryRow(query)
+	}
+
+	err = row.Scan(dest...)
+	if err != nil {
+		return aggregates, fmt.Errorf("error running %v :: %v", query, err)
+	}
+
+	// sums over no rows are null
+	for i := range values {
+		value := new(big.Float).SetPrec(256)
+		if values[i].Valid {
+			_, ok := value.SetString(strings.TrimSpace(values[i].String))
+			if !ok {
+				return aggregates, fmt.Errorf("error parsing aggregate %v of %v as a number", values[i].String, selects[i+1])
+			}
+		}
+		aggregates.values = append(aggregates.values, value)
+	}
+
+	return aggregates, nil
+}
+
+func getTargetAggregates(transfer Transfer, columnInfos []ColumnInfo, target System) (aggregates tableAggregates, err error) {
+	from := getSchemaPeriodTable(transfer.TargetSchema, getLoadTable(transfer), target, true)
+	return getAggregates(from, columnInfos, transfer.Verification == VerificationChecksum, target, nil)
+}
+
+func getSourceAggregates(transfer Transfer, columnInfos []ColumnInfo, source System, snapshot *sql.Tx) (aggregates tableAggregates, err error) {
+	// aggregates what the source query will read, before it's run in the same snapshot. a
+	// query's columns aren't known until it runs, so they're found with a query for no rows
+
+	from := fmt.Sprintf("(%v) sqlpipe_verify", transfer.Query)
+	if transfer.SourceTable != "" {
+		from = getSchemaPeriodTable(transfer.SourceSchema, transfer.SourceTable, source, true)
+	}
+
+	if transfer.Query != "" {
+		rows, err := snapshot.Query(fmt.Sprintf("select * from %v where 1 = 0", from))
+		if err != nil {
+			return aggregates, fmt.Errorf("error getting query columns :: %v", err)
+		}
+		defer rows.Close()
+
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return aggregates, fmt.Errorf("error getting query column types :: %v", err)
+		}
+
+		// only names and pipe types are needed to aggregate
+		columnInfos = []ColumnInfo{}
+		for _, columnType := range columnTypes {
+			dbTypeName, err := safeGetDbTypeName(columnType)
+			if err != nil {
+				return aggregates, fmt.Errorf("error getting dbTypeName :: %v", err)
+			}
+
+			pipeType, err := source.driverTypeToPipeType(columnType, dbTypeName)
+			if err != nil {
+				return aggregates, fmt.Errorf("error getting pipeTypes :: %v", err)
+			}
+
+			columnInfos = append(columnInfos, ColumnInfo{Name: columnType.Name(), PipeType: pipeType})
+		}
+
+		err = rows.Close()
+		if err != nil {
+			return aggregates, fmt.Errorf("error closing query columns rows :: %v", err)
+		}
+	}
+
+	return getAggregates(from, columnInfos, true, source, snapshot)
+}
+
+func verifyLoad(transfer Transfer, columnInfos []ColumnInfo, baseline, sourceAggregates tableAggregates, rowsReadBefore int64, target System) (result VerificationResult, err error) {
+	// compares the rows read from the source with the rows the load added to the target.
+	// checksums aggregate each column in both systems, the source's in the snapshot it was read in
+
+	after, err := getTargetAggregates(transfer, columnInfos, target)
+	if err != nil {
+		return result, fmt.Errorf("error getting target aggregates :: %v", err)
+	}
+
+	result.RowsRead = transfer.Progress.snapshot().RowsRead - rowsReadBefore
+	result.TargetRows = after.rows - baseline.rows
+	result.Passed = result.RowsRead == result.TargetRows
+
+	if transfer.Verification == VerificationChecksum {
+
+		if len(sourceAggregates.values) != len(columnInfos) {
+			return result, fmt.Errorf("the source was aggregated over %v columns, but %v were loaded", len(sourceAggregates.values), len(columnInfos))
+		}
+
+		for i := range columnInfos {
+			targetValue := new(big.Float).SetPrec(256).Sub(after.values[i], baseline.values[i])
+			match := checksumsMatch(sourceAggregates.values[i], targetValue, columnInfos[i].PipeType)
+
+			result.Checksums = append(result.Checksums, ColumnChecksum{
+				Column:    columnInfos[i].Name,
+				Aggregate: getChecksumKind(columnInfos[i]),
+				Source:    sourceAggregates.values[i].Text('g', 30),
+				Target:    targetValue.Text('g', 30),
+				Match:     match,
+			})
+
+			if !match {
+				result.Passed = false
+			}
+		}
+	}
+
+	result.VerifiedAt = time.Now().Format(time.RFC3339Nano)
+
+	return result, nil
+}
+
+func checksumsMatch(sourceValue, targetValue *big.Float, pipeType string) bool {
+	// floating point sums depend on the order rows are added in, so they only need to be close.
+	// other values only differ by what's lost parsing decimals into binary, far below their precision
+
+	tolerance := 1e-40
+	switch pipeType {
+	case "float32":
+		tolerance = 1e-4
+	case "float64":
+		tolerance = 1e-9
+	}
+
+	difference := new(big.Float).Sub(sourceValue, targetValue)
+	difference.Abs(difference)
+
+	largest := new(big.Float).Abs(sourceValue)
+	if new(big.Float).Abs(targetValue).Cmp(largest) > 0 {
+		largest.Abs(targetValue)
+	}
+
+	allowed := new(big.Float).Mul(largest, big.NewFloat(tolerance))
+
+	return difference.Cmp(allowed) <= 0
+}
+
+func (result VerificationResult) summary() string {
+
+	parts := []string{fmt.Sprintf("%v rows read, %v rows loaded", result.RowsRead, result.TargetRows)}
+
+	for _, checksum := range result.Checksums {
+		if !checksum.Match {
+			parts = append(parts, fmt.Sprintf("%v of %v is %v in the source and %v in the target", checksum.Aggregate, checksum.Column, checksum.Source, checksum.Target))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}